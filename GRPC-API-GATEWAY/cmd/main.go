@@ -1,9 +1,16 @@
 package main
 
 import (
+	"context"
 	"grpc-user-api-gateway/pkg/config"
 	"grpc-user-api-gateway/pkg/di"
+	"grpc-user-api-gateway/pkg/logging"
+	"grpc-user-api-gateway/pkg/tracing"
 	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
@@ -11,12 +18,44 @@ func main() {
 	if configErr != nil {
 		log.Fatal("cannot load config: ", configErr)
 	}
+	slog.SetDefault(logging.New(config.LogLevel, config.LogRedactFields))
+
+	shutdownTracing, tracingErr := tracing.Init(config)
+	if tracingErr != nil {
+		slog.Error("cannot initialize tracing", "error", tracingErr)
+		os.Exit(1)
+	}
 
 	server, diErr := di.InitializeAPI(config)
 
 	if diErr != nil {
-		log.Fatal("cannot start server: ", diErr)
-	} else {
-		server.Start()
+		slog.Error("cannot start server", "error", diErr)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Start()
+	}()
+
+	select {
+	case err := <-serveErr:
+		if err != nil {
+			slog.Error("server stopped", "error", err)
+		}
+	case <-ctx.Done():
+		slog.Info("shutting down server")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownDrainDelay+config.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("error during shutdown", "error", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("error flushing traces", "error", err)
 	}
 }
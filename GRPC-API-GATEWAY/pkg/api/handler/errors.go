@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"grpc-user-api-gateway/pkg/logging"
+	"grpc-user-api-gateway/pkg/utils/response"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorResponse writes an error response tagged with the request id so that
+// clients can quote it when reporting a problem.
+func errorResponse(c *gin.Context, statusCode int, message string, err interface{}) {
+	errs := response.ClientResponse(statusCode, message, nil, err)
+	errs.RequestID = logging.RequestID(c.Request.Context())
+	c.JSON(statusCode, errs)
+}
+
+// FieldViolation is one invalid field reported by the user service.
+type FieldViolation struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
+
+// ValidationError is the error body of a request rejected by the user
+// service's validation rules.
+type ValidationError struct {
+	Message    string           `json:"message"`
+	Violations []FieldViolation `json:"violations"`
+}
+
+// grpcErrorResponse writes an error returned by the user service. Well known
+// gRPC codes are mapped to the matching HTTP status and field violations are
+// passed through; any other error is reported with the fallback status.
+func grpcErrorResponse(c *gin.Context, fallback int, message string, err error) {
+	st := status.Convert(err)
+	statusCode, ok := httpStatus[st.Code()]
+	if !ok {
+		statusCode = fallback
+	}
+
+	var body interface{} = st.Message()
+	for _, detail := range st.Details() {
+		badRequest, ok := detail.(*errdetails.BadRequest)
+		if !ok {
+			continue
+		}
+		validation := ValidationError{Message: st.Message()}
+		for _, v := range badRequest.FieldViolations {
+			validation.Violations = append(validation.Violations, FieldViolation{Field: v.Field, Description: v.Description})
+		}
+		body = validation
+	}
+	errorResponse(c, statusCode, message, body)
+}
+
+var httpStatus = map[codes.Code]int{
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.Aborted:            http.StatusConflict,
+	codes.FailedPrecondition: http.StatusPreconditionFailed,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+}
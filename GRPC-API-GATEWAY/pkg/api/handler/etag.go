@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"grpc-user-api-gateway/pkg/utils/models"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// cacheControlHeader returns the Cache-Control value of user read routes. Responses
+// are private to the caller; with a max age of zero clients revalidate with
+// If-None-Match on every request and get an empty 304 while the record is
+// unchanged.
+func cacheControlHeader(maxAge time.Duration) string {
+	if maxAge <= 0 {
+		return "private, no-cache"
+	}
+	return fmt.Sprintf("private, max-age=%d", int(maxAge.Seconds()))
+}
+
+// etagOf returns a strong ETag for a JSON representation.
+func etagOf(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// conditionalResponse writes a successful read response tagged with etag,
+// or an empty 304 Not Modified when the request's If-None-Match lists it.
+func (u *UserHandler) conditionalResponse(c *gin.Context, statusCode int, etag string, body interface{}) {
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", u.cacheControl)
+	if etagListed(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.JSON(statusCode, body)
+}
+
+// userETag is the strong ETag of a single user. Every write increments the
+// version, so the tag changes with the record and an If-Match can be checked
+// by the user service atomically with the write.
+func userETag(user models.Users) string {
+	return fmt.Sprintf(`"%d.%d"`, user.ID, user.Version)
+}
+
+// expectedVersion returns the version an update or delete of user id must
+// apply to: the one named by If-Match or, without the header, the version
+// sent in the request. If-Match: * names the current version. ok is false
+// when a response was already written: 412 Precondition Failed when If-Match
+// names no version of this user and 428 Precondition Required when the
+// request names no version at all.
+func (u *UserHandler) expectedVersion(c *gin.Context, id, requested int64) (version int64, ok bool) {
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		if requested <= 0 {
+			errorResponse(c, http.StatusPreconditionRequired, "Precondition required", "send the version of the user in If-Match or in the request")
+			return 0, false
+		}
+		return requested, true
+	}
+	if strings.TrimSpace(ifMatch) == "*" {
+		user, err := u.GRPC_Client.GetUserByID(c.Request.Context(), id)
+		if status.Code(err) == codes.NotFound {
+			errorResponse(c, http.StatusPreconditionFailed, "Precondition failed", "the user does not exist")
+			return 0, false
+		}
+		if err != nil {
+			grpcErrorResponse(c, http.StatusInternalServerError, "Internal server error", err)
+			return 0, false
+		}
+		return user.Version, true
+	}
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		var tagID, tagVersion int64
+		if _, err := fmt.Sscanf(strings.TrimSpace(candidate), `"%d.%d"`, &tagID, &tagVersion); err == nil && tagID == id {
+			return tagVersion, true
+		}
+	}
+	errorResponse(c, http.StatusPreconditionFailed, "Precondition failed", "If-Match does not name a version of this user")
+	return 0, false
+}
+
+// writeError reports a failed update or delete. A version conflict is a
+// failed precondition when the client named the version through If-Match
+// and a conflict otherwise.
+func writeError(c *gin.Context, err error) {
+	if status.Code(err) == codes.Aborted && c.GetHeader("If-Match") != "" {
+		errorResponse(c, http.StatusPreconditionFailed, "Precondition failed", status.Convert(err).Message())
+		return
+	}
+	grpcErrorResponse(c, http.StatusInternalServerError, "User not changed", err)
+}
+
+// etagListed reports whether an If-None-Match header lists etag. It uses
+// the weak comparison, so W/ tags match too, and "*" matches anything.
+func etagListed(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"grpc-user-api-gateway/pkg/client/mock"
+	"grpc-user-api-gateway/pkg/utils/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func Test_DeleteUserPreconditions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name       string
+		query      string
+		ifMatch    string
+		buildStubs func(client *mock.MockUserClient)
+		status     int
+	}{
+		{
+			name:  "version in query",
+			query: "user_id=7&version=3",
+			buildStubs: func(client *mock.MockUserClient) {
+				client.EXPECT().DeleteUser(gomock.Any(), int64(7), int64(3)).Return(nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:       "no version",
+			query:      "user_id=7",
+			buildStubs: func(client *mock.MockUserClient) {},
+			status:     http.StatusPreconditionRequired,
+		},
+		{
+			name:    "If-Match",
+			query:   "user_id=7",
+			ifMatch: `"6.1", "7.4"`,
+			buildStubs: func(client *mock.MockUserClient) {
+				client.EXPECT().DeleteUser(gomock.Any(), int64(7), int64(4)).Return(nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:       "If-Match names another user",
+			query:      "user_id=7",
+			ifMatch:    `"6.1"`,
+			buildStubs: func(client *mock.MockUserClient) {},
+			status:     http.StatusPreconditionFailed,
+		},
+		{
+			name:       "If-Match is not an ETag",
+			query:      "user_id=7",
+			ifMatch:    "7.4",
+			buildStubs: func(client *mock.MockUserClient) {},
+			status:     http.StatusPreconditionFailed,
+		},
+		{
+			name:    "If-Match any",
+			query:   "user_id=7",
+			ifMatch: "*",
+			buildStubs: func(client *mock.MockUserClient) {
+				client.EXPECT().GetUserByID(gomock.Any(), int64(7)).Return(models.Users{ID: 7, Version: 5}, nil)
+				client.EXPECT().DeleteUser(gomock.Any(), int64(7), int64(5)).Return(nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:    "If-Match any on a missing user",
+			query:   "user_id=7",
+			ifMatch: "*",
+			buildStubs: func(client *mock.MockUserClient) {
+				client.EXPECT().GetUserByID(gomock.Any(), int64(7)).Return(models.Users{}, status.Error(codes.NotFound, "user not found"))
+			},
+			status: http.StatusPreconditionFailed,
+		},
+		{
+			name:    "If-Match any when the lookup fails",
+			query:   "user_id=7",
+			ifMatch: "*",
+			buildStubs: func(client *mock.MockUserClient) {
+				client.EXPECT().GetUserByID(gomock.Any(), int64(7)).Return(models.Users{}, status.Error(codes.Unavailable, "connection refused"))
+			},
+			status: http.StatusServiceUnavailable,
+		},
+		{
+			name:    "stale If-Match",
+			query:   "user_id=7",
+			ifMatch: `"7.4"`,
+			buildStubs: func(client *mock.MockUserClient) {
+				client.EXPECT().DeleteUser(gomock.Any(), int64(7), int64(4)).Return(status.Error(codes.Aborted, "user was modified"))
+			},
+			status: http.StatusPreconditionFailed,
+		},
+		{
+			name:  "stale version in query",
+			query: "user_id=7&version=4",
+			buildStubs: func(client *mock.MockUserClient) {
+				client.EXPECT().DeleteUser(gomock.Any(), int64(7), int64(4)).Return(status.Error(codes.Aborted, "user was modified"))
+			},
+			status: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			client := mock.NewMockUserClient(ctrl)
+			tt.buildStubs(client)
+
+			r := gin.New()
+			r.DELETE("/user", NewUserHandler(client, 0).DeleteUser)
+			req := httptest.NewRequest(http.MethodDelete, "/user?"+tt.query, nil)
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
+func Test_UpdateUserVersionConflict(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock.NewMockUserClient(ctrl)
+	client.EXPECT().UpdateUser(gomock.Any(), int64(7), gomock.Any(), int64(4)).Return(models.Users{}, status.Error(codes.Aborted, "user was modified"))
+	client.EXPECT().UpdateUser(gomock.Any(), int64(7), gomock.Any(), int64(5)).Return(models.Users{ID: 7, Version: 6}, nil)
+
+	r := gin.New()
+	r.PUT("/user", NewUserHandler(client, 0).UpdateUser)
+	update := func(body, ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/user?user_id=7", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := update(`{"fname":"Ann","city":"Oslo","phone":"+4712345678","height":170,"version":4}`, "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = update(`{"fname":"Ann","city":"Oslo","phone":"+4712345678","height":170}`, `"7.5"`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"7.6"`, w.Header().Get("ETag"))
+}
+
+func Test_etagListed(t *testing.T) {
+	const etag = `"7.4"`
+	tests := []struct {
+		name   string
+		header string
+		want   bool
+	}{
+		{name: "no header", header: "", want: false},
+		{name: "same tag", header: `"7.4"`, want: true},
+		{name: "other tag", header: `"7.3"`, want: false},
+		{name: "weak tag", header: `W/"7.4"`, want: true},
+		{name: "list", header: `"7.3", W/"7.4"`, want: true},
+		{name: "list without spaces", header: `"7.3","7.4"`, want: true},
+		{name: "list without match", header: `"7.3", "8.4"`, want: false},
+		{name: "any", header: "*", want: true},
+		{name: "unquoted", header: "7.4", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, etagListed(tt.header, etag))
+		})
+	}
+}
+
+func Test_GetUserByIDNotModified(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		status      int
+	}{
+		{name: "no header", status: http.StatusCreated},
+		{name: "current version", ifNoneMatch: `"7.4"`, status: http.StatusNotModified},
+		{name: "weak current version", ifNoneMatch: `W/"7.4"`, status: http.StatusNotModified},
+		{name: "old version", ifNoneMatch: `"7.3"`, status: http.StatusCreated},
+		{name: "any", ifNoneMatch: "*", status: http.StatusNotModified},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			client := mock.NewMockUserClient(ctrl)
+			client.EXPECT().GetUserByID(gomock.Any(), int64(7)).Return(models.Users{ID: 7, FName: "Ann", Version: 4}, nil)
+
+			r := gin.New()
+			r.GET("/user", NewUserHandler(client, time.Minute).GetUserByID)
+			req := httptest.NewRequest(http.MethodGet, "/user?user_id=7", nil)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, `"7.4"`, w.Header().Get("ETag"))
+			assert.Equal(t, "private, max-age=60", w.Header().Get("Cache-Control"))
+			if tt.status == http.StatusNotModified {
+				assert.Empty(t, w.Body.String())
+			} else {
+				assert.Contains(t, w.Body.String(), `"fname":"Ann"`)
+			}
+		})
+	}
+}
@@ -8,49 +8,55 @@ import (
 	"net/http"
 	"regexp"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 )
 
+// phonePattern only checks the shape of a phone number, the user service
+// parses the number for its region and stores it in E.164 form.
+var phonePattern = regexp.MustCompile(`^\+?[0-9 ()./-]{4,32}$`)
+
 type UserHandler struct {
-	GRPC_Client interfaces.UserClient
+	GRPC_Client  interfaces.UserClient
+	cacheControl string
+	stopWatches  chan struct{}
+	stopOnce     sync.Once
 }
 
-func NewUserHandler(userClient interfaces.UserClient) *UserHandler {
+// NewUserHandler returns the user handler. Read responses may be reused by
+// clients for cacheMaxAge before they have to revalidate.
+func NewUserHandler(userClient interfaces.UserClient, cacheMaxAge time.Duration) *UserHandler {
 	return &UserHandler{
-		GRPC_Client: userClient,
+		GRPC_Client:  userClient,
+		cacheControl: cacheControlHeader(cacheMaxAge),
+		stopWatches:  make(chan struct{}),
 	}
 }
 
 func (u *UserHandler) AddUser(c *gin.Context) {
 	var AddUser models.User
 	if err := c.ShouldBindJSON(&AddUser); err != nil {
-		errs := response.ClientResponse(http.StatusBadRequest, "Details not in correct format", nil, err.Error())
-		c.JSON(http.StatusBadRequest, errs)
+		errorResponse(c, http.StatusBadRequest, "Details not in correct format", err.Error())
 		return
 	}
 
-	pattern := `^\d{10}$`
-	regex := regexp.MustCompile(pattern)
-	value := regex.MatchString(AddUser.Phone)
-	if !value {
-		errs := response.ClientResponse(http.StatusBadRequest, "Details not in correct format", nil, "Invalid phone number")
-		c.JSON(http.StatusBadRequest, errs)
+	if !phonePattern.MatchString(AddUser.Phone) {
+		errorResponse(c, http.StatusBadRequest, "Details not in correct format", "Invalid phone number")
 		return
 	}
 
 	err := validator.New().Struct(AddUser)
 	if err != nil {
-		errs := response.ClientResponse(http.StatusBadRequest, "Constraints not satisfied", nil, err.Error())
-		c.JSON(http.StatusBadRequest, errs)
+		errorResponse(c, http.StatusBadRequest, "Constraints not satisfied", err.Error())
 		return
 	}
 
-	err = u.GRPC_Client.AddUser(AddUser)
+	err = u.GRPC_Client.AddUser(c.Request.Context(), AddUser)
 	if err != nil {
-		errs := response.ClientResponse(http.StatusInternalServerError, "Internal server error", nil, err.Error())
-		c.JSON(http.StatusInternalServerError, errs)
+		grpcErrorResponse(c, http.StatusInternalServerError, "Internal server error", err)
 		return
 	}
 	success := response.ClientResponse(http.StatusCreated, "User added successfully", nil, nil)
@@ -61,52 +67,161 @@ func (au *UserHandler) GetUserByID(c *gin.Context) {
 	userID := c.Query("user_id")
 	UserID, err := strconv.Atoi(userID)
 	if err != nil {
-		errs := response.ClientResponse(http.StatusBadRequest, "UserID not in right format", nil, err.Error())
-		c.JSON(http.StatusBadRequest, errs)
+		errorResponse(c, http.StatusBadRequest, "UserID not in right format", err.Error())
 		return
 	}
 
-	user, err := au.GRPC_Client.GetUserByID(int64(UserID))
+	user, err := au.GRPC_Client.GetUserByID(c.Request.Context(), int64(UserID))
 	if err != nil {
-		errs := response.ClientResponse(http.StatusBadRequest, "Details not in correct format", nil, err.Error())
-		c.JSON(http.StatusBadRequest, errs)
+		grpcErrorResponse(c, http.StatusBadRequest, "Details not in correct format", err)
 		return
 	}
 	success := response.ClientResponse(http.StatusCreated, "Successfully get Userdetails", user, nil)
-	c.JSON(http.StatusCreated, success)
+	au.conditionalResponse(c, http.StatusCreated, userETag(user), success)
 }
 
 func (au *UserHandler) GetUsersByIDs(c *gin.Context) {
 	user := c.PostFormArray("user_ids")
 	users, err := helper.ConvertStringToArray(user)
 	if err != nil {
-		errs := response.ClientResponse(http.StatusBadRequest, "Details not in correct format", nil, err.Error())
-		c.JSON(http.StatusBadRequest, errs)
+		errorResponse(c, http.StatusBadRequest, "Details not in correct format", err.Error())
 		return
 	}
-	Users, err := au.GRPC_Client.GetUsersByIDs(users)
+	strict := false
+	if value := c.PostForm("strict"); value != "" {
+		strict, err = strconv.ParseBool(value)
+		if err != nil {
+			errorResponse(c, http.StatusBadRequest, "Details not in correct format", err.Error())
+			return
+		}
+	}
+	Users, err := au.GRPC_Client.GetUsersByIDs(c.Request.Context(), users, strict)
 	if err != nil {
-		errs := response.ClientResponse(http.StatusBadRequest, "Details not in correct format", nil, err.Error())
-		c.JSON(http.StatusBadRequest, errs)
+		grpcErrorResponse(c, http.StatusBadRequest, "Details not in correct format", err)
+		return
+	}
+	etag, err := etagOf(Users)
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "Internal server error", err.Error())
 		return
 	}
 	success := response.ClientResponse(http.StatusCreated, "Successfully get Userdetails", Users, nil)
-	c.JSON(http.StatusCreated, success)
+	au.conditionalResponse(c, http.StatusCreated, etag, success)
 }
 
 func (au *UserHandler) SearchUsers(c *gin.Context) {
 	var Search models.SearchUser
 	if err := c.ShouldBindJSON(&Search); err != nil {
-		errs := response.ClientResponse(http.StatusBadRequest, "Details not in correct format", nil, err.Error())
-		c.JSON(http.StatusBadRequest, errs)
+		errorResponse(c, http.StatusBadRequest, "Details not in correct format", err.Error())
 		return
 	}
-	user, err := au.GRPC_Client.SearchUsers(Search)
+	user, err := au.GRPC_Client.SearchUsers(c.Request.Context(), Search)
 	if err != nil {
-		errs := response.ClientResponse(http.StatusBadRequest, "Details not in correct format", nil, err.Error())
-		c.JSON(http.StatusBadRequest, errs)
+		grpcErrorResponse(c, http.StatusBadRequest, "Details not in correct format", err)
+		return
+	}
+	etag, err := etagOf(user)
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "Internal server error", err.Error())
 		return
 	}
 	success := response.ClientResponse(http.StatusCreated, "Successfully get Userdetails", user, nil)
-	c.JSON(http.StatusCreated, success)
+	au.conditionalResponse(c, http.StatusCreated, etag, success)
+}
+
+// GetUserHistory lists the changes of a user, newest first. page_size
+// limits the page (at most 100, 50 by default) and page_token, the
+// next_page_token of a page, continues after it.
+func (u *UserHandler) GetUserHistory(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "UserID not in right format", err.Error())
+		return
+	}
+	pageSize := 0
+	if value := c.Query("page_size"); value != "" {
+		pageSize, err = strconv.Atoi(value)
+		if err != nil {
+			errorResponse(c, http.StatusBadRequest, "Details not in correct format", err.Error())
+			return
+		}
+	}
+	history, err := u.GRPC_Client.GetUserHistory(c.Request.Context(), id, pageSize, c.Query("page_token"))
+	if err != nil {
+		grpcErrorResponse(c, http.StatusInternalServerError, "Internal server error", err)
+		return
+	}
+	etag, err := etagOf(history)
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "Internal server error", err.Error())
+		return
+	}
+	success := response.ClientResponse(http.StatusOK, "Successfully get user history", history, nil)
+	u.conditionalResponse(c, http.StatusOK, etag, success)
+}
+
+// UpdateUser replaces the fields of a user. The version the client last read
+// comes from If-Match or the version field of the body; when the user has
+// been written since, nothing is changed.
+func (u *UserHandler) UpdateUser(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Query("user_id"), 10, 64)
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "UserID not in right format", err.Error())
+		return
+	}
+	var update models.UpdateUser
+	if err := c.ShouldBindJSON(&update); err != nil {
+		errorResponse(c, http.StatusBadRequest, "Details not in correct format", err.Error())
+		return
+	}
+	if !phonePattern.MatchString(update.Phone) {
+		errorResponse(c, http.StatusBadRequest, "Details not in correct format", "Invalid phone number")
+		return
+	}
+	if err := validator.New().Struct(update.User); err != nil {
+		errorResponse(c, http.StatusBadRequest, "Constraints not satisfied", err.Error())
+		return
+	}
+	version, ok := u.expectedVersion(c, id, update.Version)
+	if !ok {
+		return
+	}
+
+	user, err := u.GRPC_Client.UpdateUser(c.Request.Context(), id, update.User, version)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Header("ETag", userETag(user))
+	success := response.ClientResponse(http.StatusOK, "User updated successfully", user, nil)
+	c.JSON(http.StatusOK, success)
+}
+
+// DeleteUser deletes a user. The version the client last read comes from
+// If-Match or the version query parameter.
+func (u *UserHandler) DeleteUser(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Query("user_id"), 10, 64)
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "UserID not in right format", err.Error())
+		return
+	}
+	var requested int64
+	if value := c.Query("version"); value != "" {
+		requested, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			errorResponse(c, http.StatusBadRequest, "Details not in correct format", err.Error())
+			return
+		}
+	}
+	version, ok := u.expectedVersion(c, id, requested)
+	if !ok {
+		return
+	}
+
+	if err := u.GRPC_Client.DeleteUser(c.Request.Context(), id, version); err != nil {
+		writeError(c, err)
+		return
+	}
+	success := response.ClientResponse(http.StatusOK, "User deleted successfully", nil, nil)
+	c.JSON(http.StatusOK, success)
 }
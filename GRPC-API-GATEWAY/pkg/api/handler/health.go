@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"context"
+	interfaces "grpc-user-api-gateway/pkg/client/interface"
+	"grpc-user-api-gateway/pkg/utils/response"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	GRPC_Client interfaces.UserClient
+	Timeout     time.Duration
+	draining    atomic.Bool
+}
+
+func NewHealthHandler(userClient interfaces.UserClient, timeout time.Duration) *HealthHandler {
+	return &HealthHandler{
+		GRPC_Client: userClient,
+		Timeout:     timeout,
+	}
+}
+
+// Liveness reports that the gateway process is up and able to serve HTTP.
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, response.ClientResponse(http.StatusOK, "ok", nil, nil))
+}
+
+// Drain makes Readiness fail from now on so that load balancers stop sending
+// new traffic while in-flight requests finish.
+func (h *HealthHandler) Drain() {
+	h.draining.Store(true)
+}
+
+// Readiness reports whether the gateway can serve traffic, which requires the
+// user service to pass its gRPC health check.
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	if h.draining.Load() {
+		errorResponse(c, http.StatusServiceUnavailable, "Server is shutting down", nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.Timeout)
+	defer cancel()
+
+	if err := h.GRPC_Client.CheckHealth(ctx); err != nil {
+		errorResponse(c, http.StatusServiceUnavailable, "User service is not ready", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, response.ClientResponse(http.StatusOK, "ready", nil, nil))
+}
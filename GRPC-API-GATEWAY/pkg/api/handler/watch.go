@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"grpc-user-api-gateway/pkg/utils/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// watchKeepAlive is how often an idle event stream sends a comment, so that
+// proxies keep it open and clients that went away are noticed.
+const watchKeepAlive = 15 * time.Second
+
+// WatchUsers streams the change feed of the user service as server-sent
+// events. The id of each event is its resume token: clients reconnecting with
+// Last-Event-ID, or the resume_token query parameter, continue after it.
+// Until the first event or keep-alive is sent, failures are reported as
+// regular error responses.
+func (u *UserHandler) WatchUsers(c *gin.Context) {
+	resumeToken := c.GetHeader("Last-Event-ID")
+	if resumeToken == "" {
+		resumeToken = c.Query("resume_token")
+	}
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	events := make(chan models.UserEvent)
+	result := make(chan error, 1)
+	go func() {
+		result <- u.GRPC_Client.WatchUsers(ctx, resumeToken, func(event models.UserEvent) error {
+			select {
+			case events <- event:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	keepAlive := time.NewTicker(watchKeepAlive)
+	defer keepAlive.Stop()
+	started := false
+	start := func() {
+		if started {
+			return
+		}
+		started = true
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+	}
+	for {
+		select {
+		case event := <-events:
+			start()
+			data, err := json.Marshal(event)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(c.Writer, "id: %s\nevent: %s\ndata: %s\n\n", event.ResumeToken, event.Type, data)
+		case <-keepAlive.C:
+			start()
+			fmt.Fprint(c.Writer, ": keep-alive\n\n")
+		case err := <-result:
+			// Once the stream is under way, ending it makes the client
+			// reconnect with the last event id.
+			if !started {
+				grpcErrorResponse(c, http.StatusInternalServerError, "Internal server error", err)
+			}
+			return
+		case <-u.stopWatches:
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+// StopWatches ends all event streams so that a shutting down gateway does
+// not wait for them. Clients reconnect to another instance.
+func (u *UserHandler) StopWatches() {
+	u.stopOnce.Do(func() { close(u.stopWatches) })
+}
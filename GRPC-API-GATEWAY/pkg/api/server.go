@@ -1,33 +1,95 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"grpc-user-api-gateway/pkg/api/handler"
-	"log"
+	"grpc-user-api-gateway/pkg/auth"
+	interfaces "grpc-user-api-gateway/pkg/client/interface"
+	"grpc-user-api-gateway/pkg/config"
+	"grpc-user-api-gateway/pkg/consistency"
+	"grpc-user-api-gateway/pkg/logging"
+	"grpc-user-api-gateway/pkg/metrics"
+	"grpc-user-api-gateway/pkg/tracing"
+	"log/slog"
+	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 type ServerHTTP struct {
-	engine *gin.Engine
+	engine        *gin.Engine
+	server        *http.Server
+	healthHandler *handler.HealthHandler
+	userClient    interfaces.UserClient
+	drainDelay    time.Duration
 }
 
-func NewServerHTTP(userHandler *handler.UserHandler) *ServerHTTP {
+func NewServerHTTP(cfg config.Config, userHandler *handler.UserHandler, healthHandler *handler.HealthHandler, userClient interfaces.UserClient) *ServerHTTP {
 	r := gin.New()
+	r.Use(logging.RequestIDMiddleware())
 
-	r.Use(gin.Logger())
+	r.GET("/healthz", healthHandler.Liveness)
+	r.GET("/readyz", healthHandler.Readiness)
+	r.GET("/metrics", metrics.Handler())
+
+	r.Use(logging.AccessLogMiddleware(slog.Default()))
+	r.Use(metrics.Middleware())
+	r.Use(otelgin.Middleware(tracing.ServiceName))
+	r.Use(auth.Middleware(auth.NewVerifier(cfg.AuthJWTSecret, cfg.AuthJWTIssuer, cfg.AuthJWTAudience)))
+	r.Use(consistency.Middleware())
 
 	r.POST("/adduser", userHandler.AddUser)
 	r.GET("/user", userHandler.GetUserByID)
+	r.PUT("/user", userHandler.UpdateUser)
+	r.DELETE("/user", userHandler.DeleteUser)
 	r.GET("/users", userHandler.GetUsersByIDs)
 	r.GET("/search", userHandler.SearchUsers)
+	r.GET("/v1/users/:id/history", userHandler.GetUserHistory)
+	r.GET("/v1/users/watch", userHandler.WatchUsers)
+
+	server := &http.Server{Addr: cfg.Port, Handler: r}
+	server.RegisterOnShutdown(userHandler.StopWatches)
+	return &ServerHTTP{
+		engine:        r,
+		server:        server,
+		healthHandler: healthHandler,
+		userClient:    userClient,
+		drainDelay:    cfg.ShutdownDrainDelay,
+	}
+}
 
-	return &ServerHTTP{engine: r}
+func (s *ServerHTTP) Start() error {
+	slog.Info("starting server", "addr", s.server.Addr)
+	err := s.server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("error while starting the server", "error", err)
+		return err
+	}
+	return nil
 }
 
-func (s *ServerHTTP) Start() {
-	log.Printf("Starting Server on 3000")
-	err := s.engine.Run(":3000")
-	if err != nil {
-		log.Printf("error while starting the server")
+// Shutdown fails the readiness probe and keeps serving for the drain delay,
+// so that load balancers see the failing probe and stop routing to the
+// gateway before it stops accepting connections. It then waits for in-flight
+// requests to finish until ctx expires and closes the connection to the user
+// service.
+func (s *ServerHTTP) Shutdown(ctx context.Context) error {
+	s.healthHandler.Drain()
+	if s.drainDelay > 0 {
+		slog.Info("waiting for readiness to propagate", "delay", s.drainDelay)
+		timer := time.NewTimer(s.drainDelay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+	err := s.server.Shutdown(ctx)
+	if closeErr := s.userClient.Close(); err == nil {
+		err = closeErr
 	}
+	return err
 }
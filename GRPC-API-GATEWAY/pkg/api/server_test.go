@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"grpc-user-api-gateway/pkg/api/handler"
+	"grpc-user-api-gateway/pkg/client/mock"
+	"grpc-user-api-gateway/pkg/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serve starts a gateway with the given drain delay on a local port and
+// returns it with its base url.
+func serve(t *testing.T, drainDelay time.Duration) (*ServerHTTP, string) {
+	gin.SetMode(gin.TestMode)
+	ctrl := gomock.NewController(t)
+	client := mock.NewMockUserClient(ctrl)
+	client.EXPECT().CheckHealth(gomock.Any()).Return(nil).AnyTimes()
+	client.EXPECT().Close().Return(nil)
+
+	cfg := config.Config{ShutdownDrainDelay: drainDelay}
+	s := NewServerHTTP(cfg, handler.NewUserHandler(client, 0), handler.NewHealthHandler(client, time.Second), client)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	served := make(chan error, 1)
+	go func() { served <- s.server.Serve(listener) }()
+	t.Cleanup(func() { assert.ErrorIs(t, <-served, http.ErrServerClosed) })
+	return s, "http://" + listener.Addr().String()
+}
+
+func get(url string) (int, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func Test_ShutdownDrainDelay(t *testing.T) {
+	const delay = 300 * time.Millisecond
+	s, url := serve(t, delay)
+	code, err := get(url + "/readyz")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+
+	start := time.Now()
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- s.Shutdown(context.Background()) }()
+
+	// Readiness fails at once while requests are still accepted.
+	require.Eventually(t, func() bool {
+		code, err := get(url + "/readyz")
+		return err == nil && code == http.StatusServiceUnavailable
+	}, delay/2, 5*time.Millisecond)
+	code, err = get(url + "/healthz")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Less(t, time.Since(start), delay)
+
+	assert.NoError(t, <-shutdown)
+	assert.GreaterOrEqual(t, time.Since(start), delay)
+	_, err = get(url + "/healthz")
+	assert.Error(t, err)
+}
+
+func Test_ShutdownDrainDelayCutShort(t *testing.T) {
+	s, _ := serve(t, time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := s.Shutdown(ctx)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.True(t, err == nil || errors.Is(err, context.DeadlineExceeded), "unexpected error %v", err)
+}
+
+func Test_ShutdownWithoutDrainDelay(t *testing.T) {
+	s, _ := serve(t, 0)
+	start := time.Now()
+	assert.NoError(t, s.Shutdown(context.Background()))
+	assert.Less(t, time.Since(start), time.Second)
+}
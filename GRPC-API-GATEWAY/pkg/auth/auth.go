@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"grpc-user-api-gateway/pkg/logging"
+	"grpc-user-api-gateway/pkg/utils/response"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// AuthorizationKey is the gRPC metadata key the caller's bearer token is
+// forwarded in. The user service checks the token again and records its
+// subject as the creator or last writer of users.
+const AuthorizationKey = "authorization"
+
+// ErrNotConfigured is returned by Verify when no signing secret is set, so no
+// token can be trusted.
+var ErrNotConfigured = errors.New("token authentication is not configured")
+
+type tokenKey struct{}
+
+// Verifier checks bearer tokens: HS256 JWTs signed with the secret shared
+// with the user service, naming the caller in their subject.
+type Verifier struct {
+	secret []byte
+	parser *jwt.Parser
+}
+
+// NewVerifier returns a Verifier for tokens signed with secret. The issuer
+// and audience claims are checked when they are not empty. With an empty
+// secret every token is rejected.
+func NewVerifier(secret, issuer, audience string) *Verifier {
+	opts := []jwt.ParserOption{jwt.WithValidMethods([]string{"HS256"}), jwt.WithExpirationRequired()}
+	if issuer != "" {
+		opts = append(opts, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		opts = append(opts, jwt.WithAudience(audience))
+	}
+	return &Verifier{secret: []byte(secret), parser: jwt.NewParser(opts...)}
+}
+
+// Verify checks the signature and claims of token and returns its subject.
+func (v *Verifier) Verify(token string) (string, error) {
+	if len(v.secret) == 0 {
+		return "", ErrNotConfigured
+	}
+	var claims jwt.RegisteredClaims
+	if _, err := v.parser.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return v.secret, nil
+	}); err != nil {
+		return "", err
+	}
+	if claims.Subject == "" {
+		return "", errors.New("token has no subject")
+	}
+	return claims.Subject, nil
+}
+
+// Middleware authenticates the request by its Authorization bearer token and
+// stores the token in the request context for UnaryClientInterceptor.
+// Requests without one are sent on anonymously; requests with a token that
+// does not verify are answered with 401. No other header names the caller.
+func Middleware(verifier *Verifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if header == "" {
+			c.Next()
+			return
+		}
+		scheme, token, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			unauthorized(c, "authorization must be a bearer token")
+			return
+		}
+		if _, err := verifier.Verify(token); err != nil {
+			unauthorized(c, err.Error())
+			return
+		}
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), tokenKey{}, token))
+		c.Next()
+	}
+}
+
+func unauthorized(c *gin.Context, reason string) {
+	errs := response.ClientResponse(http.StatusUnauthorized, "invalid credentials", nil, reason)
+	errs.RequestID = logging.RequestID(c.Request.Context())
+	c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, errs)
+}
+
+// UnaryClientInterceptor forwards the verified token of the request to the
+// user service.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(withToken(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor forwards the verified token of the request on
+// streams.
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(withToken(ctx), desc, cc, method, opts...)
+	}
+}
+
+func withToken(ctx context.Context) context.Context {
+	if token, ok := ctx.Value(tokenKey{}).(string); ok {
+		return metadata.AppendToOutgoingContext(ctx, AuthorizationKey, "Bearer "+token)
+	}
+	return ctx
+}
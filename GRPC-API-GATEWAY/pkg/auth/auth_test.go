@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func signToken(t *testing.T, secret string, claims jwt.RegisteredClaims) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	assert.NoError(t, err)
+	return token
+}
+
+func Test_Middleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	expires := jwt.NewNumericDate(time.Now().Add(time.Hour))
+	valid := signToken(t, "test-secret", jwt.RegisteredClaims{Subject: "alice@example.com", ExpiresAt: expires})
+	tests := []struct {
+		name      string
+		header    http.Header
+		status    int
+		forwarded []string
+	}{
+		{name: "token", header: http.Header{"Authorization": {"Bearer " + valid}}, status: http.StatusOK, forwarded: []string{"Bearer " + valid}},
+		{name: "anonymous", status: http.StatusOK},
+		{name: "principal header is ignored", header: http.Header{"X-Forwarded-User": {"root"}}, status: http.StatusOK},
+		{name: "wrong secret", header: http.Header{"Authorization": {"Bearer " + signToken(t, "other", jwt.RegisteredClaims{Subject: "root", ExpiresAt: expires})}}, status: http.StatusUnauthorized},
+		{name: "expired", header: http.Header{"Authorization": {"Bearer " + signToken(t, "test-secret", jwt.RegisteredClaims{Subject: "root", ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Hour))})}}, status: http.StatusUnauthorized},
+		{name: "not a bearer token", header: http.Header{"Authorization": {"Basic cm9vdDpyb290"}}, status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var forwarded []string
+			r := gin.New()
+			r.Use(Middleware(NewVerifier("test-secret", "", "")))
+			r.GET("/user", func(c *gin.Context) {
+				invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+					md, _ := metadata.FromOutgoingContext(ctx)
+					forwarded = md.Get(AuthorizationKey)
+					return nil
+				}
+				assert.NoError(t, UnaryClientInterceptor()(c.Request.Context(), "/userservice.UserService/GetUserByID", nil, nil, nil, invoker))
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/user", nil)
+			req.Header = tt.header
+			if req.Header == nil {
+				req.Header = http.Header{}
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, tt.forwarded, forwarded)
+		})
+	}
+}
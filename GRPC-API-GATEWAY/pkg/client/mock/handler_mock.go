@@ -0,0 +1,181 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: pkg\client\interface\user.go
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	context "context"
+	models "grpc-user-api-gateway/pkg/utils/models"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockUserClient is a mock of UserClient interface.
+type MockUserClient struct {
+	ctrl     *gomock.Controller
+	recorder *MockUserClientMockRecorder
+}
+
+// MockUserClientMockRecorder is the mock recorder for MockUserClient.
+type MockUserClientMockRecorder struct {
+	mock *MockUserClient
+}
+
+// NewMockUserClient creates a new mock instance.
+func NewMockUserClient(ctrl *gomock.Controller) *MockUserClient {
+	mock := &MockUserClient{ctrl: ctrl}
+	mock.recorder = &MockUserClientMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUserClient) EXPECT() *MockUserClientMockRecorder {
+	return m.recorder
+}
+
+// AddUser mocks base method.
+func (m *MockUserClient) AddUser(ctx context.Context, user models.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddUser", ctx, user)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddUser indicates an expected call of AddUser.
+func (mr *MockUserClientMockRecorder) AddUser(ctx, user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUser", reflect.TypeOf((*MockUserClient)(nil).AddUser), ctx, user)
+}
+
+// CheckHealth mocks base method.
+func (m *MockUserClient) CheckHealth(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckHealth", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckHealth indicates an expected call of CheckHealth.
+func (mr *MockUserClientMockRecorder) CheckHealth(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckHealth", reflect.TypeOf((*MockUserClient)(nil).CheckHealth), ctx)
+}
+
+// Close mocks base method.
+func (m *MockUserClient) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockUserClientMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockUserClient)(nil).Close))
+}
+
+// DeleteUser mocks base method.
+func (m *MockUserClient) DeleteUser(ctx context.Context, id, version int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUser", ctx, id, version)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUser indicates an expected call of DeleteUser.
+func (mr *MockUserClientMockRecorder) DeleteUser(ctx, id, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockUserClient)(nil).DeleteUser), ctx, id, version)
+}
+
+// GetUserByID mocks base method.
+func (m *MockUserClient) GetUserByID(ctx context.Context, id int64) (models.Users, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByID", ctx, id)
+	ret0, _ := ret[0].(models.Users)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByID indicates an expected call of GetUserByID.
+func (mr *MockUserClientMockRecorder) GetUserByID(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockUserClient)(nil).GetUserByID), ctx, id)
+}
+
+// GetUserHistory mocks base method.
+func (m *MockUserClient) GetUserHistory(ctx context.Context, id int64, pageSize int, pageToken string) (models.UserHistory, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserHistory", ctx, id, pageSize, pageToken)
+	ret0, _ := ret[0].(models.UserHistory)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserHistory indicates an expected call of GetUserHistory.
+func (mr *MockUserClientMockRecorder) GetUserHistory(ctx, id, pageSize, pageToken interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserHistory", reflect.TypeOf((*MockUserClient)(nil).GetUserHistory), ctx, id, pageSize, pageToken)
+}
+
+// GetUsersByIDs mocks base method.
+func (m *MockUserClient) GetUsersByIDs(ctx context.Context, ids []int64, strict bool) (models.UsersByIDs, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsersByIDs", ctx, ids, strict)
+	ret0, _ := ret[0].(models.UsersByIDs)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUsersByIDs indicates an expected call of GetUsersByIDs.
+func (mr *MockUserClientMockRecorder) GetUsersByIDs(ctx, ids, strict interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsersByIDs", reflect.TypeOf((*MockUserClient)(nil).GetUsersByIDs), ctx, ids, strict)
+}
+
+// SearchUsers mocks base method.
+func (m *MockUserClient) SearchUsers(ctx context.Context, search models.SearchUser) ([]models.Users, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchUsers", ctx, search)
+	ret0, _ := ret[0].([]models.Users)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchUsers indicates an expected call of SearchUsers.
+func (mr *MockUserClientMockRecorder) SearchUsers(ctx, search interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchUsers", reflect.TypeOf((*MockUserClient)(nil).SearchUsers), ctx, search)
+}
+
+// UpdateUser mocks base method.
+func (m *MockUserClient) UpdateUser(ctx context.Context, id int64, user models.User, version int64) (models.Users, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUser", ctx, id, user, version)
+	ret0, _ := ret[0].(models.Users)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUser indicates an expected call of UpdateUser.
+func (mr *MockUserClientMockRecorder) UpdateUser(ctx, id, user, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockUserClient)(nil).UpdateUser), ctx, id, user, version)
+}
+
+// WatchUsers mocks base method.
+func (m *MockUserClient) WatchUsers(ctx context.Context, resumeToken string, send func(models.UserEvent) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WatchUsers", ctx, resumeToken, send)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WatchUsers indicates an expected call of WatchUsers.
+func (mr *MockUserClientMockRecorder) WatchUsers(ctx, resumeToken, send interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WatchUsers", reflect.TypeOf((*MockUserClient)(nil).WatchUsers), ctx, resumeToken, send)
+}
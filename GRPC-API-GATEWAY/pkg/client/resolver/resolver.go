@@ -0,0 +1,196 @@
+package resolver
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/resolver"
+)
+
+const (
+	StaticScheme = "static"
+	FileScheme   = "file"
+	DNSScheme    = "dns"
+)
+
+// DefaultRefresh is how often the file resolver re-reads its file when no
+// positive interval is configured.
+const DefaultRefresh = 10 * time.Second
+
+// Target converts the configured user service url into a gRPC dial target.
+// A comma separated list of addresses uses the static resolver, a file:// url
+// uses the file resolver and a single host is resolved through DNS so that
+// every address behind the name takes part in load balancing.
+func Target(url string) string {
+	url = strings.TrimSpace(url)
+	switch {
+	case strings.Contains(url, "://"), strings.HasPrefix(url, FileScheme+":"):
+		return url
+	case strings.Contains(url, ","):
+		return StaticScheme + ":///" + url
+	default:
+		return DNSScheme + ":///" + url
+	}
+}
+
+// ParseAddresses splits a list of addresses separated by commas or new lines.
+// Blank entries and lines starting with # are ignored.
+func ParseAddresses(data []byte) []string {
+	var addrs []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, addr := range strings.Split(line, ",") {
+			addr = strings.TrimSpace(addr)
+			if addr != "" {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	return addrs
+}
+
+func toState(addrs []string) resolver.State {
+	state := resolver.State{}
+	for _, addr := range addrs {
+		state.Addresses = append(state.Addresses, resolver.Address{Addr: addr})
+	}
+	return state
+}
+
+type staticBuilder struct{}
+
+// NewStaticBuilder returns a resolver builder for static:///host1:port,host2:port targets.
+func NewStaticBuilder() resolver.Builder {
+	return staticBuilder{}
+}
+
+func (staticBuilder) Scheme() string {
+	return StaticScheme
+}
+
+func (staticBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	addrs := ParseAddresses([]byte(target.Endpoint()))
+	if len(addrs) == 0 {
+		return nil, errors.New("static resolver: no addresses in target " + target.String())
+	}
+	if err := cc.UpdateState(toState(addrs)); err != nil {
+		return nil, err
+	}
+	return staticResolver{}, nil
+}
+
+type staticResolver struct{}
+
+func (staticResolver) ResolveNow(resolver.ResolveNowOptions) {}
+
+func (staticResolver) Close() {}
+
+type fileBuilder struct {
+	refresh time.Duration
+}
+
+// NewFileBuilder returns a resolver builder for file:///path/to/targets
+// targets. The file is re-read every refresh interval, DefaultRefresh if
+// refresh is not positive, and the connection is updated whenever the list
+// of addresses changes.
+func NewFileBuilder(refresh time.Duration) resolver.Builder {
+	if refresh <= 0 {
+		refresh = DefaultRefresh
+	}
+	return &fileBuilder{refresh: refresh}
+}
+
+func (b *fileBuilder) Scheme() string {
+	return FileScheme
+}
+
+func (b *fileBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	path := target.URL.Path
+	if path == "" {
+		path = target.URL.Opaque
+	}
+	if path == "" {
+		return nil, errors.New("file resolver: missing path in target " + target.String())
+	}
+	r := &fileResolver{
+		path:    path,
+		cc:      cc,
+		refresh: b.refresh,
+		resolve: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	r.wg.Add(1)
+	go r.watch()
+	return r, nil
+}
+
+type fileResolver struct {
+	path    string
+	cc      resolver.ClientConn
+	refresh time.Duration
+	last    []byte
+	resolve chan struct{}
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func (r *fileResolver) load() error {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return fmt.Errorf("file resolver: %w", err)
+	}
+	if r.last != nil && bytes.Equal(data, r.last) {
+		return nil
+	}
+	addrs := ParseAddresses(data)
+	if len(addrs) == 0 {
+		return errors.New("file resolver: no addresses in " + r.path)
+	}
+	if err := r.cc.UpdateState(toState(addrs)); err != nil {
+		return err
+	}
+	r.last = data
+	return nil
+}
+
+func (r *fileResolver) watch() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+		case <-r.resolve:
+		}
+		if err := r.load(); err != nil {
+			r.cc.ReportError(err)
+		}
+	}
+}
+
+func (r *fileResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.resolve <- struct{}{}:
+	default:
+	}
+}
+
+func (r *fileResolver) Close() {
+	close(r.done)
+	r.wg.Wait()
+}
@@ -0,0 +1,163 @@
+package resolver
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/resolver"
+)
+
+// fakeClientConn records the addresses and errors a resolver reports.
+type fakeClientConn struct {
+	resolver.ClientConn
+	states chan []string
+	errs   chan error
+}
+
+func newFakeClientConn() *fakeClientConn {
+	return &fakeClientConn{states: make(chan []string, 10), errs: make(chan error, 10)}
+}
+
+func (cc *fakeClientConn) UpdateState(state resolver.State) error {
+	var addrs []string
+	for _, addr := range state.Addresses {
+		addrs = append(addrs, addr.Addr)
+	}
+	cc.states <- addrs
+	return nil
+}
+
+// ReportError drops errors nobody waits for, as the file resolver reports
+// one on every refresh while its file is broken.
+func (cc *fakeClientConn) ReportError(err error) {
+	select {
+	case cc.errs <- err:
+	default:
+	}
+}
+
+func parseTarget(t *testing.T, target string) resolver.Target {
+	u, err := url.Parse(target)
+	require.NoError(t, err)
+	return resolver.Target{URL: *u}
+}
+
+func Test_Target(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{url: "user-service:50051", want: "dns:///user-service:50051"},
+		{url: " user-service:50051 ", want: "dns:///user-service:50051"},
+		{url: "10.0.0.1:50051,10.0.0.2:50051", want: "static:///10.0.0.1:50051,10.0.0.2:50051"},
+		{url: "file:///etc/user-service/targets", want: "file:///etc/user-service/targets"},
+		{url: "dns://8.8.8.8/user-service:50051", want: "dns://8.8.8.8/user-service:50051"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Target(tt.url), tt.url)
+	}
+}
+
+func Test_ParseAddresses(t *testing.T) {
+	data := "# user service\n10.0.0.1:50051, 10.0.0.2:50051\n\n  10.0.0.3:50051  \n#10.0.0.4:50051\n,\n"
+	assert.Equal(t, []string{"10.0.0.1:50051", "10.0.0.2:50051", "10.0.0.3:50051"}, ParseAddresses([]byte(data)))
+	assert.Empty(t, ParseAddresses([]byte("# none\n")))
+}
+
+func Test_StaticBuilder(t *testing.T) {
+	cc := newFakeClientConn()
+	r, err := NewStaticBuilder().Build(parseTarget(t, "static:///10.0.0.1:50051,10.0.0.2:50051"), cc, resolver.BuildOptions{})
+	require.NoError(t, err)
+	defer r.Close()
+	assert.Equal(t, []string{"10.0.0.1:50051", "10.0.0.2:50051"}, <-cc.states)
+
+	_, err = NewStaticBuilder().Build(parseTarget(t, "static:///"), newFakeClientConn(), resolver.BuildOptions{})
+	assert.Error(t, err)
+}
+
+func Test_FileBuilder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets")
+	require.NoError(t, os.WriteFile(path, []byte("10.0.0.1:50051\n"), 0o600))
+	cc := newFakeClientConn()
+	r, err := NewFileBuilder(10*time.Millisecond).Build(parseTarget(t, "file://"+path), cc, resolver.BuildOptions{})
+	require.NoError(t, err)
+	defer r.Close()
+	assert.Equal(t, []string{"10.0.0.1:50051"}, <-cc.states)
+
+	// A change is picked up on the next refresh.
+	require.NoError(t, os.WriteFile(path, []byte("10.0.0.1:50051\n10.0.0.2:50051\n"), 0o600))
+	select {
+	case addrs := <-cc.states:
+		assert.Equal(t, []string{"10.0.0.1:50051", "10.0.0.2:50051"}, addrs)
+	case <-time.After(5 * time.Second):
+		t.Fatal("file change not picked up")
+	}
+
+	// An emptied file is reported and the last addresses are kept.
+	require.NoError(t, os.WriteFile(path, []byte("# drained\n"), 0o600))
+	select {
+	case err := <-cc.errs:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("empty file not reported")
+	}
+	assert.Empty(t, cc.states)
+}
+
+func Test_FileBuilderResolveNow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets")
+	require.NoError(t, os.WriteFile(path, []byte("10.0.0.1:50051\n"), 0o600))
+	cc := newFakeClientConn()
+	r, err := NewFileBuilder(time.Hour).Build(parseTarget(t, "file://"+path), cc, resolver.BuildOptions{})
+	require.NoError(t, err)
+	defer r.Close()
+	<-cc.states
+
+	require.NoError(t, os.WriteFile(path, []byte("10.0.0.2:50051\n"), 0o600))
+	r.ResolveNow(resolver.ResolveNowOptions{})
+	select {
+	case addrs := <-cc.states:
+		assert.Equal(t, []string{"10.0.0.2:50051"}, addrs)
+	case <-time.After(5 * time.Second):
+		t.Fatal("ResolveNow did not re-read the file")
+	}
+}
+
+func Test_FileBuilderRefresh(t *testing.T) {
+	for _, refresh := range []time.Duration{0, -time.Second} {
+		assert.Equal(t, DefaultRefresh, NewFileBuilder(refresh).(*fileBuilder).refresh, "refresh %s", refresh)
+	}
+
+	// Building with the fallback interval starts the watcher without
+	// panicking.
+	path := filepath.Join(t.TempDir(), "targets")
+	require.NoError(t, os.WriteFile(path, []byte("10.0.0.1:50051\n"), 0o600))
+	r, err := NewFileBuilder(0).Build(parseTarget(t, "file://"+path), newFakeClientConn(), resolver.BuildOptions{})
+	require.NoError(t, err)
+	r.Close()
+}
+
+func Test_FileBuilderErrors(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty")
+	require.NoError(t, os.WriteFile(empty, []byte("# none\n"), 0o600))
+	tests := []struct {
+		name   string
+		target string
+	}{
+		{name: "missing file", target: "file://" + filepath.Join(dir, "missing")},
+		{name: "no addresses", target: "file://" + empty},
+		{name: "no path", target: "file://"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewFileBuilder(time.Second).Build(parseTarget(t, tt.target), newFakeClientConn(), resolver.BuildOptions{})
+			assert.Error(t, err)
+		})
+	}
+}
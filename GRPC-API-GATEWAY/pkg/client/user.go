@@ -2,34 +2,75 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"grpc-user-api-gateway/pkg/auth"
 	interfaces "grpc-user-api-gateway/pkg/client/interface"
+	"grpc-user-api-gateway/pkg/client/resolver"
 	"grpc-user-api-gateway/pkg/config"
+	"grpc-user-api-gateway/pkg/consistency"
+	"grpc-user-api-gateway/pkg/logging"
+	"grpc-user-api-gateway/pkg/metrics"
 	"grpc-user-api-gateway/pkg/pb"
 	"grpc-user-api-gateway/pkg/utils/models"
+	"log/slog"
+	"strings"
+	"time"
 
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer/leastrequest"
+	"google.golang.org/grpc/balancer/roundrobin"
+	_ "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type UserClient struct {
 	Client pb.UserServiceClient
+	Health healthpb.HealthClient
+	conn   *grpc.ClientConn
 }
 
 func NewUserClient(cfg config.Config) interfaces.UserClient {
-	grpcConnection, err := grpc.Dial(cfg.UserSvcUrl, grpc.WithInsecure())
+	grpcConnection, err := grpc.Dial(resolver.Target(cfg.UserSvcUrl),
+		grpc.WithInsecure(),
+		grpc.WithResolvers(resolver.NewStaticBuilder(), resolver.NewFileBuilder(cfg.UserSvcResolverInterval)),
+		grpc.WithDefaultServiceConfig(serviceConfig(cfg)),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithChainUnaryInterceptor(logging.UnaryClientInterceptor(), metrics.UnaryClientInterceptor(), consistency.UnaryClientInterceptor(), auth.UnaryClientInterceptor()),
+		grpc.WithChainStreamInterceptor(logging.StreamClientInterceptor(), auth.StreamClientInterceptor()),
+	)
 	if err != nil {
-		fmt.Println("Could not connect", err)
+		slog.Error("could not connect to user service", "error", err)
 	}
 
 	grpcClient := pb.NewUserServiceClient(grpcConnection)
 
 	return &UserClient{
 		Client: grpcClient,
+		Health: healthpb.NewHealthClient(grpcConnection),
+		conn:   grpcConnection,
 	}
 }
 
-func (u *UserClient) GetUserByID(id int64) (models.Users, error) {
-	results, err := u.Client.GetUserByID(context.Background(), &pb.UserIDRequest{
+// serviceConfig builds the client side load balancing config. When health
+// checking is enabled, replicas that report NOT_SERVING are taken out of
+// rotation until they recover.
+func serviceConfig(cfg config.Config) string {
+	policy := fmt.Sprintf(`{"%s":{}}`, roundrobin.Name)
+	if cfg.UserSvcLBPolicy == "least_request" {
+		policy = fmt.Sprintf(`{"%s":{"choiceCount":2}}`, leastrequest.Name)
+	}
+	if !cfg.UserSvcHealthCheck {
+		return fmt.Sprintf(`{"loadBalancingConfig":[%s]}`, policy)
+	}
+	return fmt.Sprintf(`{"loadBalancingConfig":[%s],"healthCheckConfig":{"serviceName":"%s"}}`,
+		policy, pb.UserService_ServiceDesc.ServiceName)
+}
+
+func (u *UserClient) GetUserByID(ctx context.Context, id int64) (models.Users, error) {
+	results, err := u.Client.GetUserByID(ctx, &pb.UserIDRequest{
 		Id: id,
 	})
 
@@ -37,43 +78,36 @@ func (u *UserClient) GetUserByID(id int64) (models.Users, error) {
 		return models.Users{}, err
 	}
 
-	return models.Users{
-		ID:      results.User.Id,
-		FName:   results.User.Fname,
-		City:    results.User.City,
-		Phone:   results.User.Phone,
-		Height:  results.User.Height,
-		Married: results.User.Married,
-	}, nil
-}
-
-func (u *UserClient) GetUsersByIDs(ids []int64) ([]models.Users, error) {
-	users, err := u.Client.GetUsersByIDs(context.Background(), &pb.UserIDsRequest{
-		Ids: ids,
+	return fromPBUser(results.User), nil
+}
+
+func (u *UserClient) GetUsersByIDs(ctx context.Context, ids []int64, strict bool) (models.UsersByIDs, error) {
+	users, err := u.Client.GetUsersByIDs(ctx, &pb.UserIDsRequest{
+		Ids:    ids,
+		Strict: strict,
 	})
 	if err != nil {
-		return []models.Users{}, err
+		return models.UsersByIDs{}, err
 	}
 	var results []models.Users
 	for _, v := range users.Users {
-		result := models.Users{
-			ID:      v.Id,
-			FName:   v.Fname,
-			City:    v.City,
-			Phone:   v.Phone,
-			Height:  v.Height,
-			Married: v.Married,
-		}
-		results = append(results, result)
+		results = append(results, fromPBUser(v))
 	}
-	return results, nil
+	return models.UsersByIDs{Users: results, NotFoundIDs: users.NotFoundIds}, nil
 }
 
-func (u *UserClient) SearchUsers(search models.SearchUser) ([]models.Users, error) {
-	users, err := u.Client.SearchUsers(context.Background(), &pb.SearchRequest{
-		City:    search.City,
-		Phone:   search.Phone,
-		Married: search.Married,
+func (u *UserClient) SearchUsers(ctx context.Context, search models.SearchUser) ([]models.Users, error) {
+	users, err := u.Client.SearchUsers(ctx, &pb.SearchRequest{
+		City:          search.City,
+		Phone:         search.Phone,
+		Married:       search.Married,
+		Region:        search.Region,
+		CreatedAfter:  toPBTime(search.CreatedAfter),
+		CreatedBefore: toPBTime(search.CreatedBefore),
+		UpdatedAfter:  toPBTime(search.UpdatedAfter),
+		UpdatedBefore: toPBTime(search.UpdatedBefore),
+		SortBy:        sortOrders[search.SortBy],
+		Descending:    search.Descending,
 	})
 
 	if err != nil {
@@ -81,22 +115,14 @@ func (u *UserClient) SearchUsers(search models.SearchUser) ([]models.Users, erro
 	}
 	var results []models.Users
 	for _, v := range users.Users {
-		result := models.Users{
-			ID:      v.Id,
-			FName:   v.Fname,
-			City:    v.City,
-			Phone:   v.Phone,
-			Height:  v.Height,
-			Married: v.Married,
-		}
-		results = append(results, result)
+		results = append(results, fromPBUser(v))
 	}
 	return results, nil
 }
 
-func (u *UserClient) AddUser(user models.User) error {
-	users := &pb.Users{Fname: user.FName, City: user.City, Phone: user.Phone, Height: user.Height, Married: user.Married}
-	_, err := u.Client.AddUser(context.Background(), &pb.AddUserRequest{
+func (u *UserClient) AddUser(ctx context.Context, user models.User) error {
+	users := &pb.Users{Fname: user.FName, City: user.City, Phone: user.Phone, Height: user.Height, Married: user.Married, Region: user.Region}
+	_, err := u.Client.AddUser(ctx, &pb.AddUserRequest{
 		User: users,
 	})
 	if err != nil {
@@ -104,3 +130,140 @@ func (u *UserClient) AddUser(user models.User) error {
 	}
 	return nil
 }
+
+func (u *UserClient) UpdateUser(ctx context.Context, id int64, user models.User, version int64) (models.Users, error) {
+	result, err := u.Client.UpdateUser(ctx, &pb.UpdateUserRequest{
+		Id:      id,
+		User:    &pb.Users{Fname: user.FName, City: user.City, Phone: user.Phone, Height: user.Height, Married: user.Married, Region: user.Region},
+		Version: version,
+	})
+	if err != nil {
+		return models.Users{}, err
+	}
+	return fromPBUser(result.User), nil
+}
+
+func (u *UserClient) DeleteUser(ctx context.Context, id int64, version int64) error {
+	_, err := u.Client.DeleteUser(ctx, &pb.DeleteUserRequest{Id: id, Version: version})
+	return err
+}
+
+func (u *UserClient) GetUserHistory(ctx context.Context, id int64, pageSize int, pageToken string) (models.UserHistory, error) {
+	history, err := u.Client.GetUserHistory(ctx, &pb.UserHistoryRequest{
+		Id:        id,
+		PageSize:  int32(pageSize),
+		PageToken: pageToken,
+	})
+	if err != nil {
+		return models.UserHistory{}, err
+	}
+	result := models.UserHistory{Changes: []models.UserChange{}, NextPageToken: history.NextPageToken}
+	for _, change := range history.Changes {
+		result.Changes = append(result.Changes, fromPBChange(change))
+	}
+	return result, nil
+}
+
+// WatchUsers passes the events of the user service's change feed to send
+// until ctx is done, send fails or the stream ends.
+func (u *UserClient) WatchUsers(ctx context.Context, resumeToken string, send func(models.UserEvent) error) error {
+	stream, err := u.Client.WatchUsers(ctx, &pb.WatchUsersRequest{ResumeToken: resumeToken})
+	if err != nil {
+		return err
+	}
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		err = send(models.UserEvent{
+			Type:        strings.ToLower(strings.TrimPrefix(event.Type.String(), "USER_EVENT_TYPE_")),
+			User:        fromPBUser(event.User),
+			Actor:       event.Actor,
+			ChangedAt:   fromPBTime(event.ChangedAt),
+			ResumeToken: event.ResumeToken,
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func fromPBChange(change *pb.UserChange) models.UserChange {
+	result := models.UserChange{
+		ID:        change.Id,
+		UserID:    change.UserId,
+		Operation: strings.ToLower(strings.TrimPrefix(change.Operation.String(), "CHANGE_OPERATION_")),
+		Actor:     change.Actor,
+		ChangedAt: fromPBTime(change.ChangedAt),
+	}
+	if change.OldUser != nil {
+		old := fromPBUser(change.OldUser)
+		result.Old = &old
+	}
+	if change.NewUser != nil {
+		user := fromPBUser(change.NewUser)
+		result.New = &user
+	}
+	return result
+}
+
+// sortOrders maps the sort_by values of a search to the user service's
+// orders. The empty value sorts by id.
+var sortOrders = map[string]pb.SortBy{
+	"":           pb.SortBy_SORT_BY_ID,
+	"id":         pb.SortBy_SORT_BY_ID,
+	"created_at": pb.SortBy_SORT_BY_CREATED_AT,
+	"updated_at": pb.SortBy_SORT_BY_UPDATED_AT,
+}
+
+func fromPBUser(user *pb.User) models.Users {
+	return models.Users{
+		ID:        user.Id,
+		FName:     user.Fname,
+		City:      user.City,
+		Phone:     user.Phone,
+		Height:    user.Height,
+		Married:   user.Married,
+		Version:   user.Version,
+		CreatedAt: fromPBTime(user.CreatedAt),
+		UpdatedAt: fromPBTime(user.UpdatedAt),
+		CreatedBy: user.CreatedBy,
+		UpdatedBy: user.UpdatedBy,
+	}
+}
+
+// toPBTime leaves zero times unset.
+func toPBTime(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func fromPBTime(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
+
+func (u *UserClient) CheckHealth(ctx context.Context) error {
+	res, err := u.Health.Check(ctx, &healthpb.HealthCheckRequest{
+		Service: pb.UserService_ServiceDesc.ServiceName,
+	})
+	if err != nil {
+		return err
+	}
+	if res.Status != healthpb.HealthCheckResponse_SERVING {
+		return errors.New("user service is " + res.Status.String())
+	}
+	return nil
+}
+
+func (u *UserClient) Close() error {
+	if u.conn == nil {
+		return nil
+	}
+	return u.conn.Close()
+}
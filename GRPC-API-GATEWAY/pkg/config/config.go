@@ -1,14 +1,34 @@
 package config
 
-import "github.com/spf13/viper"
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
 
 type Config struct {
-	Port       string `mapstructure:"PORT"`
-	UserSvcUrl string `mapstructure:"USER_SVC_URL"`
+	Port                    string        `mapstructure:"PORT"`
+	UserSvcUrl              string        `mapstructure:"USER_SVC_URL"`
+	UserSvcLBPolicy         string        `mapstructure:"USER_SVC_LB_POLICY"`
+	UserSvcHealthCheck      bool          `mapstructure:"USER_SVC_HEALTH_CHECK"`
+	UserSvcResolverInterval time.Duration `mapstructure:"USER_SVC_RESOLVER_INTERVAL"`
+	ReadinessTimeout        time.Duration `mapstructure:"READINESS_TIMEOUT"`
+	ShutdownTimeout         time.Duration `mapstructure:"SHUTDOWN_TIMEOUT"`
+	ShutdownDrainDelay      time.Duration `mapstructure:"SHUTDOWN_DRAIN_DELAY"`
+	TracingExporter         string        `mapstructure:"TRACING_EXPORTER"`
+	TracingFile             string        `mapstructure:"TRACING_FILE"`
+	LogLevel                string        `mapstructure:"LOG_LEVEL"`
+	LogRedactFields         string        `mapstructure:"LOG_REDACT_FIELDS"`
+	HTTPCacheMaxAge         time.Duration `mapstructure:"HTTP_CACHE_MAX_AGE"`
+	AuthJWTSecret           string        `mapstructure:"AUTH_JWT_SECRET"`
+	AuthJWTIssuer           string        `mapstructure:"AUTH_JWT_ISSUER"`
+	AuthJWTAudience         string        `mapstructure:"AUTH_JWT_AUDIENCE"`
 }
 
 var envs = []string{
-	"PORT", "USER_SVC_URL",
+	"PORT", "USER_SVC_URL", "USER_SVC_LB_POLICY", "USER_SVC_HEALTH_CHECK", "USER_SVC_RESOLVER_INTERVAL", "READINESS_TIMEOUT", "SHUTDOWN_TIMEOUT", "SHUTDOWN_DRAIN_DELAY",
+	"TRACING_EXPORTER", "TRACING_FILE", "LOG_LEVEL", "LOG_REDACT_FIELDS", "HTTP_CACHE_MAX_AGE",
+	"AUTH_JWT_SECRET", "AUTH_JWT_ISSUER", "AUTH_JWT_AUDIENCE",
 }
 
 func LoadConfig() (Config, error) {
@@ -18,6 +38,18 @@ func LoadConfig() (Config, error) {
 	viper.SetConfigFile(".env")
 	viper.ReadInConfig()
 
+	viper.SetDefault("USER_SVC_LB_POLICY", "round_robin")
+	viper.SetDefault("USER_SVC_HEALTH_CHECK", true)
+	viper.SetDefault("USER_SVC_RESOLVER_INTERVAL", "10s")
+	viper.SetDefault("READINESS_TIMEOUT", "2s")
+	viper.SetDefault("SHUTDOWN_TIMEOUT", "15s")
+	viper.SetDefault("SHUTDOWN_DRAIN_DELAY", "5s")
+	viper.SetDefault("TRACING_EXPORTER", "none")
+	viper.SetDefault("TRACING_FILE", "traces.json")
+	viper.SetDefault("LOG_LEVEL", "info")
+	viper.SetDefault("LOG_REDACT_FIELDS", "phone=mask,fname=redact,name=redact")
+	viper.SetDefault("HTTP_CACHE_MAX_AGE", "0s")
+
 	for _, env := range envs {
 		if err := viper.BindEnv(env); err != nil {
 			return config, err
@@ -0,0 +1,53 @@
+package consistency
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Header is the HTTP header and gRPC metadata key carrying the time of the
+// client's last write. The user service returns it after every write and
+// reads sent back with it within the replica lag window are served by the
+// primary database, so clients see their own writes.
+const Header = "X-Read-Your-Writes"
+
+type stateKey struct{}
+
+type state struct {
+	lastWrite string
+	response  http.Header
+}
+
+// Middleware stores the X-Read-Your-Writes header of the request and the
+// response headers in the request context for UnaryClientInterceptor.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s := &state{lastWrite: c.GetHeader(Header), response: c.Writer.Header()}
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), stateKey{}, s))
+		c.Next()
+	}
+}
+
+// UnaryClientInterceptor forwards the client's last write time to the user
+// service and copies a new one from the reply into the HTTP response.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		s, ok := ctx.Value(stateKey{}).(*state)
+		if !ok {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		if s.lastWrite != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, Header, s.lastWrite)
+		}
+		var header metadata.MD
+		err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Header(&header))...)
+		if values := header.Get(Header); len(values) > 0 {
+			s.response.Set(Header, values[len(values)-1])
+		}
+		return err
+	}
+}
@@ -9,7 +9,8 @@ import (
 
 func InitializeAPI(cfg config.Config) (*server.ServerHTTP, error) {
 	userClient := client.NewUserClient(cfg)
-	userHandler := handler.NewUserHandler(userClient)
-	serverHTTP := server.NewServerHTTP(userHandler)
+	userHandler := handler.NewUserHandler(userClient, cfg.HTTPCacheMaxAge)
+	healthHandler := handler.NewHealthHandler(userClient, cfg.ReadinessTimeout)
+	serverHTTP := server.NewServerHTTP(cfg, userHandler, healthHandler, userClient)
 	return serverHTTP, nil
 }
@@ -0,0 +1,149 @@
+// Package logging builds the service's structured logger, which tags records
+// with the request id and redacts sensitive attributes.
+//
+// The user service and the gateway are built as separate modules, so each
+// keeps a copy of this file. The copies must stay identical: change both, the
+// user service's tests fail when they differ.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const RequestIDKey = "request_id"
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request id stored in ctx, or "" if there is none.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID generates a random 128 bit request id.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// ValidRequestID reports whether an id received from a caller is safe to
+// reuse: non empty, at most 128 characters and limited to [A-Za-z0-9._-].
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// New builds a JSON logger writing to stdout. Every record logged with a
+// context carries the request id of that context, and attributes named in
+// the redaction policy are masked before they are written.
+func New(level, redactPolicy string) *slog.Logger {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		lvl = slog.LevelInfo
+	}
+	policy := ParsePolicy(redactPolicy)
+	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level:       lvl,
+		ReplaceAttr: policy.ReplaceAttr,
+	})
+	return slog.New(contextHandler{handler})
+}
+
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String(RequestIDKey, id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// Action tells the logger what to do with a sensitive attribute.
+type Action string
+
+const (
+	// Redact replaces the whole value with "[REDACTED]".
+	Redact Action = "redact"
+	// Mask keeps the last two characters, e.g. "+919876543210" becomes "***********10".
+	Mask Action = "mask"
+	// Drop removes the attribute.
+	Drop Action = "drop"
+	// Keep logs the value unchanged.
+	Keep Action = "keep"
+)
+
+// DefaultPolicy redacts names and masks phone numbers.
+const DefaultPolicy = "phone=mask,fname=redact,name=redact"
+
+// Policy maps lower case attribute keys to the action applied to them.
+type Policy map[string]Action
+
+// ParsePolicy parses a comma separated list of key=action pairs. A key
+// without an action is redacted.
+func ParsePolicy(s string) Policy {
+	policy := Policy{}
+	for _, field := range strings.Split(s, ",") {
+		key, action, found := strings.Cut(strings.TrimSpace(field), "=")
+		if key == "" {
+			continue
+		}
+		if !found {
+			action = string(Redact)
+		}
+		policy[strings.ToLower(strings.TrimSpace(key))] = Action(strings.TrimSpace(action))
+	}
+	return policy
+}
+
+func (p Policy) ReplaceAttr(_ []string, a slog.Attr) slog.Attr {
+	action, ok := p[strings.ToLower(a.Key)]
+	if !ok || a.Value.Kind() == slog.KindGroup {
+		return a
+	}
+	switch action {
+	case Drop:
+		return slog.Attr{}
+	case Mask:
+		v := a.Value.String()
+		if len(v) <= 2 {
+			return slog.String(a.Key, strings.Repeat("*", len(v)))
+		}
+		return slog.String(a.Key, strings.Repeat("*", len(v)-2)+v[len(v)-2:])
+	case Keep:
+		return a
+	default:
+		return slog.String(a.Key, "[REDACTED]")
+	}
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Routes are labelled with the registered gin pattern (e.g. /user) rather than
+// the raw path, and requests that match no route share a single label, so the
+// number of series stays bounded.
+const unmatchedRoute = "unmatched"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Total number of HTTP requests handled by the gateway.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Histogram of HTTP request latency per route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	grpcClientHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_client_handled_total",
+		Help: "Total number of RPCs completed by the client, regardless of success or failure.",
+	}, []string{"grpc_method", "grpc_code"})
+
+	grpcClientHandlingSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "grpc_client_handling_seconds",
+		Help:    "Histogram of response latency of RPCs made by the client.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"grpc_method"})
+)
+
+// Middleware records the count and latency of every HTTP request.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		httpRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpRequestSeconds.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler serves the collected metrics.
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// UnaryClientInterceptor records the count and latency of calls to the user service.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		grpcClientHandled.WithLabelValues(method, status.Code(err).String()).Inc()
+		grpcClientHandlingSeconds.WithLabelValues(method).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
@@ -1,17 +1,75 @@
 package models
 
+import "time"
+
 type User struct {
 	FName   string  `json:"fname"`
 	City    string  `json:"city"`
 	Phone   string  `json:"phone"`
 	Height  float32 `json:"height"`
 	Married bool    `json:"married"`
+	Region  string  `json:"region"`
 }
 
+// SearchUser matches the users matching any of City, Phone and Married that
+// are given and all of the time bounds.
 type SearchUser struct {
-	City    string `json:"city"`
-	Phone   string `json:"phone"`
-	Married bool   `json:"married"`
+	City  string `json:"city"`
+	Phone string `json:"phone"`
+	// Omitted leaves marital status out of the search.
+	Married *bool  `json:"married"`
+	Region  string `json:"region"`
+
+	// RFC 3339 bounds on when users were created or last updated. After is
+	// inclusive, before exclusive; omitted bounds leave the range open.
+	CreatedAfter  time.Time `json:"created_after"`
+	CreatedBefore time.Time `json:"created_before"`
+	UpdatedAfter  time.Time `json:"updated_after"`
+	UpdatedBefore time.Time `json:"updated_before"`
+
+	SortBy     string `json:"sort_by" binding:"omitempty,oneof=id created_at updated_at"`
+	Descending bool   `json:"descending"`
+}
+
+// UpdateUser is the body of an update. Version is the version of the user
+// the client last read; the If-Match header can name it instead.
+type UpdateUser struct {
+	User
+	Version int64 `json:"version"`
+}
+
+// UserChange is one recorded write of a user. Operation is snapshot,
+// insert, update or delete; Old is absent for inserts and snapshots, New for
+// deletes.
+type UserChange struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Operation string    `json:"operation"`
+	Old       *Users    `json:"old,omitempty"`
+	New       *Users    `json:"new,omitempty"`
+	Actor     string    `json:"actor"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// UserHistory is one page of the changes of a user, newest first.
+type UserHistory struct {
+	Changes       []UserChange `json:"changes"`
+	NextPageToken string       `json:"next_page_token,omitempty"`
+}
+
+// UserEvent is a change of a user in the change feed: created, updated or
+// deleted. User is the user after the change, or as it was when deleted.
+type UserEvent struct {
+	Type        string    `json:"type"`
+	User        Users     `json:"user"`
+	Actor       string    `json:"actor"`
+	ChangedAt   time.Time `json:"changed_at"`
+	ResumeToken string    `json:"resume_token"`
+}
+
+type UsersByIDs struct {
+	Users       []Users `json:"users"`
+	NotFoundIDs []int64 `json:"not_found_ids"`
 }
 
 type Users struct {
@@ -21,4 +79,10 @@ type Users struct {
 	Phone   string  `json:"phone"`
 	Height  float32 `json:"height"`
 	Married bool    `json:"married"`
+	Version int64   `json:"version"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	CreatedBy string    `json:"created_by"`
+	UpdatedBy string    `json:"updated_by"`
 }
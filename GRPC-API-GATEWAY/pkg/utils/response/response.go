@@ -5,6 +5,7 @@ type Response struct {
 	Message    string      `json:"message"`
 	Data       interface{} `json:"data"`
 	Error      interface{} `json:"error"`
+	RequestID  string      `json:"request_id,omitempty"`
 }
 
 func ClientResponse(statusCode int, message string, data interface{}, err interface{}) Response {
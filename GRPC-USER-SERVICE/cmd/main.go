@@ -1,9 +1,26 @@
 package main
 
 import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"grpc-user-service/pkg/audit"
 	"grpc-user-service/pkg/config"
+	"grpc-user-service/pkg/db"
 	"grpc-user-service/pkg/di"
+	"grpc-user-service/pkg/logging"
+	"grpc-user-service/pkg/repository"
+	"grpc-user-service/pkg/tracing"
 	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 )
 
 func main() {
@@ -11,10 +28,196 @@ func main() {
 	if err != nil {
 		log.Fatal("cannot load config:", err)
 	}
+	slog.SetDefault(logging.New(config.LogLevel, config.LogRedactFields))
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrate(config, os.Args[2:]); err != nil {
+			slog.Error("migration failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "audit" {
+		if err := auditCommand(config, os.Args[2:]); err != nil {
+			slog.Error("audit command failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "outbox" {
+		if err := outboxCommand(config, os.Args[2:]); err != nil {
+			slog.Error("outbox command failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	shutdownTracing, err := tracing.Init(config)
+	if err != nil {
+		slog.Error("cannot initialize tracing", "error", err)
+		os.Exit(1)
+	}
 	server, err := di.InitializeAPI(config)
 	if err != nil {
-		log.Fatal("cannot start server:", err)
-	} else {
-		server.Start()
+		slog.Error("cannot start server", "error", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Start()
+	}()
+
+	select {
+	case err := <-serveErr:
+		slog.Error("grpc server stopped", "error", err)
+	case <-ctx.Done():
+		slog.Info("shutting down grpc server")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+	if err := server.Stop(shutdownCtx); err != nil {
+		slog.Error("error during shutdown", "error", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("error flushing traces", "error", err)
+	}
+}
+
+// migrate implements the "migrate up|down|status|to <version>" subcommand.
+func migrate(cfg config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up|down|status|to <version>")
+	}
+	gormDB, err := db.Open(cfg)
+	if err != nil {
+		return err
+	}
+	migrator, err := db.NewMigrator(gormDB, cfg.DefaultPhoneRegion)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch {
+	case args[0] == "up":
+		return migrator.Up(ctx)
+	case args[0] == "down":
+		return migrator.Down(ctx)
+	case args[0] == "to" && len(args) == 2:
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		return migrator.To(ctx, version)
+	case args[0] == "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range status {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q", strings.Join(args, " "))
+	}
+}
+
+// auditCommand implements the "audit verify [<seq>:<hash> ...]" subcommand.
+// It checks the hash chain of the whole audit log and that each given
+// checkpoint, as printed by an earlier run, is still part of it.
+func auditCommand(cfg config.Config, args []string) error {
+	if len(args) == 0 || args[0] != "verify" {
+		return errors.New("usage: audit verify [<seq>:<hash> ...]")
+	}
+	var expected []audit.Checkpoint
+	for _, arg := range args[1:] {
+		seq, hash, ok := strings.Cut(arg, ":")
+		cp := audit.Checkpoint{}
+		var err error
+		if cp.Seq, err = strconv.ParseInt(seq, 10, 64); err != nil || !ok {
+			return fmt.Errorf("invalid checkpoint %q", arg)
+		}
+		if cp.Hash, err = hex.DecodeString(hash); err != nil {
+			return fmt.Errorf("invalid checkpoint %q", arg)
+		}
+		expected = append(expected, cp)
+	}
+	gormDB, err := db.Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close(gormDB)
+
+	ctx := db.WithPrimary(context.Background())
+	last, err := audit.Verify(ctx, repository.NewAuditRepository(gormDB, 0), expected...)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("audit log intact, %d entries, checkpoint %d:%s\n", last.Seq, last.Seq, hex.EncodeToString(last.Hash))
+	return nil
+}
+
+// outboxCommand implements the "outbox dead|requeue <id>|requeue all"
+// subcommand: it lists the dead-lettered events, or makes them due again for
+// the relay once whatever rejected them has been fixed.
+func outboxCommand(cfg config.Config, args []string) error {
+	usage := errors.New("usage: outbox dead|requeue <id>|requeue all")
+	if len(args) == 0 {
+		return usage
+	}
+	gormDB, err := db.Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close(gormDB)
+	repo := repository.NewOutboxRepository(gormDB, 0)
+
+	ctx := context.Background()
+	switch {
+	case args[0] == "dead" && len(args) == 1:
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tTYPE\tUSER\tOCCURRED AT\tATTEMPTS\tLAST ERROR")
+		var after int64
+		for {
+			events, err := repo.ListDead(ctx, after, 100)
+			if err != nil {
+				return err
+			}
+			for _, e := range events {
+				fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%d\t%s\n", e.ID, e.Type, e.User.ID, e.OccurredAt.Format(time.RFC3339), e.Attempts, e.LastError)
+				after = e.ID
+			}
+			if len(events) < 100 {
+				return w.Flush()
+			}
+		}
+	case args[0] == "requeue" && len(args) == 2:
+		var id int64
+		if args[1] != "all" {
+			if id, err = strconv.ParseInt(args[1], 10, 64); err != nil || id <= 0 {
+				return fmt.Errorf("invalid event id %q", args[1])
+			}
+		}
+		n, err := repo.Requeue(ctx, id)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("requeued %d events\n", n)
+		return nil
+	default:
+		return usage
 	}
 }
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"grpc-user-service/pkg/pb"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"gorm.io/gorm"
+)
+
+// healthChecker pings the database periodically and reports the result
+// through the grpc.health.v1.Health service, both for the server as a whole
+// ("") and for the UserService.
+type healthChecker struct {
+	db       *gorm.DB
+	health   *health.Server
+	interval time.Duration
+	done     chan struct{}
+}
+
+func newHealthChecker(db *gorm.DB, healthServer *health.Server, interval time.Duration) *healthChecker {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	return &healthChecker{
+		db:       db,
+		health:   healthServer,
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+}
+
+func (h *healthChecker) run() {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		h.check()
+		select {
+		case <-h.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *healthChecker) stop() {
+	close(h.done)
+}
+
+func (h *healthChecker) check() {
+	status := healthpb.HealthCheckResponse_SERVING
+	if err := h.ping(); err != nil {
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	h.health.SetServingStatus("", status)
+	h.health.SetServingStatus(pb.UserService_ServiceDesc.ServiceName, status)
+}
+
+func (h *healthChecker) ping() error {
+	sqlDB, err := h.db.DB()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), h.interval)
+	defer cancel()
+	return sqlDB.PingContext(ctx)
+}
@@ -0,0 +1,128 @@
+package interceptor
+
+import (
+	"context"
+	"grpc-user-service/pkg/auth"
+	"grpc-user-service/pkg/logging"
+	"grpc-user-service/pkg/metrics"
+	"grpc-user-service/pkg/pb"
+	"grpc-user-service/pkg/utils/models"
+	"log/slog"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// auditedPrefix selects the methods of the services in the userservice
+// package, leaving out health checks.
+const auditedPrefix = "/userservice."
+
+// AuditRecorder appends entries to the audit log.
+type AuditRecorder interface {
+	Record(ctx context.Context, entry models.AuditEntry) error
+}
+
+// UnaryAudit records every call with its caller, the users it named or
+// returned and its outcome. The response is sent once the entry is stored; a
+// failure to store it is logged and counted but does not fail the call,
+// whose effects have already happened.
+func UnaryAudit(recorder AuditRecorder) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		if !strings.HasPrefix(info.FullMethod, auditedPrefix) {
+			return handler(ctx, req)
+		}
+		entry := newAuditEntry(ctx, info.FullMethod)
+		defer func() {
+			if r := recover(); r != nil {
+				entry.Code = codes.Internal.String()
+				entry.TargetIDs = targetIDs(req, nil)
+				record(ctx, recorder, entry)
+				panic(r)
+			}
+			entry.Code = status.Code(err).String()
+			entry.TargetIDs = targetIDs(req, resp)
+			record(ctx, recorder, entry)
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// StreamAudit records every stream with its caller and outcome.
+func StreamAudit(recorder AuditRecorder) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		if !strings.HasPrefix(info.FullMethod, auditedPrefix) {
+			return handler(srv, ss)
+		}
+		ctx := ss.Context()
+		entry := newAuditEntry(ctx, info.FullMethod)
+		defer func() {
+			if r := recover(); r != nil {
+				entry.Code = codes.Internal.String()
+				record(ctx, recorder, entry)
+				panic(r)
+			}
+			entry.Code = status.Code(err).String()
+			record(ctx, recorder, entry)
+		}()
+		return handler(srv, ss)
+	}
+}
+
+func newAuditEntry(ctx context.Context, method string) models.AuditEntry {
+	return models.AuditEntry{
+		RecordedAt: time.Now(),
+		Principal:  auth.Principal(ctx),
+		Method:     method,
+		TargetIDs:  []int64{},
+		RequestID:  logging.RequestID(ctx),
+	}
+}
+
+// record stores entry even when the call was cancelled.
+func record(ctx context.Context, recorder AuditRecorder, entry models.AuditEntry) {
+	err := recorder.Record(context.WithoutCancel(ctx), entry)
+	metrics.ObserveAudit(err)
+	if err != nil {
+		slog.ErrorContext(ctx, "cannot record audit entry", "method", entry.Method, "error", err)
+	}
+}
+
+// targetIDs returns the ids of the users named by req or returned in resp,
+// each once.
+func targetIDs(req, resp interface{}) []int64 {
+	var ids []int64
+	switch r := req.(type) {
+	case *pb.UserIDRequest:
+		ids = append(ids, r.Id)
+	case *pb.UserIDsRequest:
+		ids = append(ids, r.Ids...)
+	case *pb.UpdateUserRequest:
+		ids = append(ids, r.Id)
+	case *pb.DeleteUserRequest:
+		ids = append(ids, r.Id)
+	case *pb.UserHistoryRequest:
+		ids = append(ids, r.Id)
+	case *pb.UserAsOfRequest:
+		ids = append(ids, r.Id)
+	}
+	switch r := resp.(type) {
+	case *pb.UserResponse:
+		ids = append(ids, r.GetUser().GetId())
+	case *pb.UsersResponse:
+		for _, user := range r.GetUsers() {
+			ids = append(ids, user.GetId())
+		}
+	}
+	seen := make(map[int64]bool, len(ids))
+	result := []int64{}
+	for _, id := range ids {
+		if id > 0 && !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
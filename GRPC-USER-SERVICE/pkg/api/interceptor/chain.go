@@ -0,0 +1,74 @@
+package interceptor
+
+import (
+	"grpc-user-service/pkg/auth"
+	"grpc-user-service/pkg/config"
+	"grpc-user-service/pkg/logging"
+	"grpc-user-service/pkg/metrics"
+	"log/slog"
+
+	"google.golang.org/grpc"
+)
+
+// Chain is the ordered list of interceptors wrapped around every RPC. The
+// first interceptor added is the outermost one.
+type Chain struct {
+	unary  []grpc.UnaryServerInterceptor
+	stream []grpc.StreamServerInterceptor
+}
+
+// NewChain returns the default chain: access logging and metrics on the
+// outside so they observe the final status code, then panic recovery,
+// concurrency limiting, deadline capping, read-your-writes routing, the
+// caller's principal taken from its verified bearer token, auditing and
+// request validation. Auditing is left out when recorder is nil. Streams are
+// long lived, so they are limited separately from unary calls.
+func NewChain(cfg config.Config, logger *slog.Logger, recorder AuditRecorder) *Chain {
+	limiter := NewLimiter(cfg.GRPCMaxInFlight)
+	streamLimiter := NewLimiter(cfg.GRPCMaxStreams)
+	verifier := auth.NewVerifier(cfg.AuthJWTSecret, cfg.AuthJWTIssuer, cfg.AuthJWTAudience)
+	c := &Chain{
+		unary: []grpc.UnaryServerInterceptor{
+			logging.UnaryServerInterceptor(logger),
+			metrics.UnaryServerInterceptor(),
+			UnaryRecovery(logger),
+			limiter.Unary(),
+			UnaryMaxDeadline(cfg.GRPCMaxDeadline),
+			UnaryReadYourWrites(cfg.DBReplicaMaxLag),
+			auth.UnaryServerInterceptor(verifier),
+		},
+		stream: []grpc.StreamServerInterceptor{
+			logging.StreamServerInterceptor(logger),
+			metrics.StreamServerInterceptor(),
+			StreamRecovery(logger),
+			streamLimiter.Stream(),
+			StreamReadYourWrites(cfg.DBReplicaMaxLag),
+			auth.StreamServerInterceptor(verifier),
+		},
+	}
+	if recorder != nil {
+		c.Unary(UnaryAudit(recorder))
+		c.Stream(StreamAudit(recorder))
+	}
+	return c.Unary(UnaryValidation()).Stream(StreamValidation())
+}
+
+// Unary appends interceptors to the unary chain.
+func (c *Chain) Unary(interceptors ...grpc.UnaryServerInterceptor) *Chain {
+	c.unary = append(c.unary, interceptors...)
+	return c
+}
+
+// Stream appends interceptors to the stream chain.
+func (c *Chain) Stream(interceptors ...grpc.StreamServerInterceptor) *Chain {
+	c.stream = append(c.stream, interceptors...)
+	return c
+}
+
+// ServerOptions returns the options installing the chain on a grpc.Server.
+func (c *Chain) ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(c.unary...),
+		grpc.ChainStreamInterceptor(c.stream...),
+	}
+}
@@ -0,0 +1,76 @@
+package interceptor
+
+import (
+	"context"
+	"grpc-user-service/pkg/db"
+	"grpc-user-service/pkg/pb"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// ReadYourWritesKey is the metadata key carrying the time, in Unix
+// milliseconds, of the caller's last write. The server sets it as a response
+// header after every successful mutation and clients send it back on later
+// calls.
+const ReadYourWritesKey = "x-read-your-writes"
+
+// mutatingMethods lists the RPCs whose effects a replica may not show yet.
+var mutatingMethods = map[string]bool{
+	pb.UserService_AddUser_FullMethodName:    true,
+	pb.UserService_UpdateUser_FullMethodName: true,
+	pb.UserService_DeleteUser_FullMethodName: true,
+
+	pb.WebhookService_CreateWebhookSubscription_FullMethodName: true,
+	pb.WebhookService_DeleteWebhookSubscription_FullMethodName: true,
+	pb.WebhookService_ReplayWebhookDeliveries_FullMethodName:   true,
+}
+
+// UnaryReadYourWrites sends the reads of callers that wrote within the last
+// window to the primary, so that they see their own writes while replicas
+// catch up. window should be the largest replica lag tolerated.
+func UnaryReadYourWrites(window time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if wroteRecently(ctx, window) {
+			ctx = db.WithPrimary(ctx)
+		}
+		resp, err := handler(ctx, req)
+		if err == nil && mutatingMethods[info.FullMethod] {
+			now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+			grpc.SetHeader(ctx, metadata.Pairs(ReadYourWritesKey, now))
+		}
+		return resp, err
+	}
+}
+
+// StreamReadYourWrites is the streaming counterpart of UnaryReadYourWrites.
+func StreamReadYourWrites(window time.Duration) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if wroteRecently(ss.Context(), window) {
+			ss = &contextStream{ServerStream: ss, ctx: db.WithPrimary(ss.Context())}
+		}
+		return handler(srv, ss)
+	}
+}
+
+func wroteRecently(ctx context.Context, window time.Duration) bool {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get(ReadYourWritesKey) {
+		ms, err := strconv.ParseInt(value, 10, 64)
+		if err == nil && time.Since(time.UnixMilli(ms)) < window {
+			return true
+		}
+	}
+	return false
+}
+
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,25 @@
+package interceptor
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// UnaryMaxDeadline makes sure no unary RPC runs longer than max: calls
+// without a deadline, or with a later one, get their deadline lowered to max.
+// A max of zero disables the cap.
+func UnaryMaxDeadline(max time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if max <= 0 {
+			return handler(ctx, req)
+		}
+		if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > max {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, max)
+			defer cancel()
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,352 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strconv"
+	"testing"
+	"time"
+
+	"grpc-user-service/pkg/api/service"
+	"grpc-user-service/pkg/auth"
+	"grpc-user-service/pkg/config"
+	"grpc-user-service/pkg/db"
+	"grpc-user-service/pkg/pb"
+	mock_usecase "grpc-user-service/pkg/usecase/mock"
+	"grpc-user-service/pkg/utils/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var info = &grpc.UnaryServerInfo{FullMethod: "/userservice.UserService/AddUser"}
+
+func Test_UnaryRecovery(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userServer := service.NewAuthServer(mock_usecase.NewMockUserUseCase(ctrl))
+	recovery := UnaryRecovery(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+
+	resp, err := recovery(context.Background(), &pb.AddUserRequest{}, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return userServer.AddUser(ctx, req.(*pb.AddUserRequest))
+	})
+
+	assert.Nil(t, resp)
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
+
+func Test_UnaryMaxDeadline(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		max     time.Duration
+		wantMax time.Duration
+	}{
+		{name: "no deadline", max: time.Second, wantMax: time.Second},
+		{name: "later deadline", timeout: time.Minute, max: time.Second, wantMax: time.Second},
+		{name: "earlier deadline", timeout: 100 * time.Millisecond, max: time.Second, wantMax: 100 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			_, err := UnaryMaxDeadline(tt.max)(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				deadline, ok := ctx.Deadline()
+				assert.True(t, ok)
+				assert.LessOrEqual(t, time.Until(deadline), tt.wantMax)
+				return nil, nil
+			})
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_Limiter(t *testing.T) {
+	limiter := NewLimiter(1).Unary()
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	go limiter(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		close(started)
+		<-release
+		return nil, nil
+	})
+	<-started
+
+	_, err := limiter(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// Health checks pass while the limit is reached.
+	health := &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+	_, err = limiter(context.Background(), nil, health, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	assert.NoError(t, err)
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		_, err := limiter(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+func Test_StreamLimiter(t *testing.T) {
+	limiter := NewLimiter(1).Stream()
+	watch := &grpc.StreamServerInfo{FullMethod: "/userservice.UserService/WatchUsers", IsServerStream: true}
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	go limiter(nil, nil, watch, func(srv interface{}, ss grpc.ServerStream) error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+	defer close(release)
+
+	err := limiter(nil, nil, watch, func(srv interface{}, ss grpc.ServerStream) error {
+		return nil
+	})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// Health watches are not counted, or busy replicas would be reported
+	// unhealthy.
+	health := &grpc.StreamServerInfo{FullMethod: "/grpc.health.v1.Health/Watch", IsServerStream: true}
+	err = limiter(nil, nil, health, func(srv interface{}, ss grpc.ServerStream) error {
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+type validatedRequest struct {
+	err error
+}
+
+func (r validatedRequest) Validate() error {
+	return r.err
+}
+
+func Test_UnaryValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      interface{}
+		wantCode codes.Code
+	}{
+		{name: "valid", req: validatedRequest{}, wantCode: codes.OK},
+		{name: "invalid", req: validatedRequest{err: errors.New("phone is required")}, wantCode: codes.InvalidArgument},
+		{name: "status error", req: validatedRequest{err: status.Error(codes.FailedPrecondition, "nope")}, wantCode: codes.FailedPrecondition},
+		{name: "valid message", req: &pb.UserIDRequest{Id: 1}, wantCode: codes.OK},
+		{name: "invalid message", req: &pb.UserIDRequest{Id: 0}, wantCode: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := UnaryValidation()(context.Background(), tt.req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, nil
+			})
+			assert.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+}
+
+type transportStream struct {
+	grpc.ServerTransportStream
+	header metadata.MD
+}
+
+func (s *transportStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func Test_UnaryReadYourWrites(t *testing.T) {
+	lastWrite := func(ago time.Duration) string {
+		return strconv.FormatInt(time.Now().Add(-ago).UnixMilli(), 10)
+	}
+	tests := []struct {
+		name        string
+		method      string
+		lastWrite   string
+		err         error
+		wantPrimary bool
+		wantHeader  bool
+	}{
+		{name: "no recent write", method: pb.UserService_GetUserByID_FullMethodName},
+		{name: "recent write", method: pb.UserService_GetUserByID_FullMethodName, lastWrite: lastWrite(time.Second), wantPrimary: true},
+		{name: "write older than the window", method: pb.UserService_GetUserByID_FullMethodName, lastWrite: lastWrite(time.Minute)},
+		{name: "malformed timestamp", method: pb.UserService_GetUserByID_FullMethodName, lastWrite: "yesterday"},
+		{name: "successful write", method: pb.UserService_AddUser_FullMethodName, wantHeader: true},
+		{name: "failed write", method: pb.UserService_AddUser_FullMethodName, err: status.Error(codes.AlreadyExists, "exists")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := &transportStream{}
+			ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+			if tt.lastWrite != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(ReadYourWritesKey, tt.lastWrite))
+			}
+			info := &grpc.UnaryServerInfo{FullMethod: tt.method}
+			_, err := UnaryReadYourWrites(10*time.Second)(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				assert.Equal(t, tt.wantPrimary, db.PrimaryRequested(ctx))
+				return nil, tt.err
+			})
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, tt.wantHeader, len(stream.header.Get(ReadYourWritesKey)) == 1)
+		})
+	}
+}
+
+// auditLog collects the entries recorded by the audit interceptor.
+type auditLog struct {
+	entries []models.AuditEntry
+	err     error
+}
+
+func (l *auditLog) Record(ctx context.Context, entry models.AuditEntry) error {
+	l.entries = append(l.entries, entry)
+	return l.err
+}
+
+func Test_UnaryAudit(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		req           interface{}
+		resp          interface{}
+		err           error
+		recordErr     error
+		wantRecorded  bool
+		wantCode      string
+		wantTargetIDs []int64
+	}{
+		{
+			name:          "read",
+			method:        pb.UserService_GetUsersByIDs_FullMethodName,
+			req:           &pb.UserIDsRequest{Ids: []int64{3, 1, 3}},
+			resp:          &pb.UsersResponse{Users: []*pb.User{{Id: 1}}, NotFoundIds: []int64{3}},
+			wantRecorded:  true,
+			wantCode:      "OK",
+			wantTargetIDs: []int64{3, 1},
+		},
+		{
+			name:          "search",
+			method:        pb.UserService_SearchUsers_FullMethodName,
+			req:           &pb.SearchRequest{City: "Kochi"},
+			resp:          &pb.UsersResponse{Users: []*pb.User{{Id: 5}, {Id: 2}}},
+			wantRecorded:  true,
+			wantCode:      "OK",
+			wantTargetIDs: []int64{5, 2},
+		},
+		{
+			name:          "failed call",
+			method:        pb.UserService_DeleteUser_FullMethodName,
+			req:           &pb.DeleteUserRequest{Id: 4, Version: 1},
+			err:           status.Error(codes.Aborted, "conflict"),
+			wantRecorded:  true,
+			wantCode:      "Aborted",
+			wantTargetIDs: []int64{4},
+		},
+		{
+			name:          "recording fails",
+			method:        pb.UserService_GetUserByID_FullMethodName,
+			req:           &pb.UserIDRequest{Id: 1},
+			resp:          &pb.UserResponse{User: &pb.User{Id: 1}},
+			recordErr:     errors.New("error"),
+			wantRecorded:  true,
+			wantCode:      "OK",
+			wantTargetIDs: []int64{1},
+		},
+		{
+			name:   "health check",
+			method: "/grpc.health.v1.Health/Check",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := &auditLog{err: tt.recordErr}
+			ctx := auth.WithPrincipal(context.Background(), "alice")
+			info := &grpc.UnaryServerInfo{FullMethod: tt.method}
+			resp, err := UnaryAudit(log)(ctx, tt.req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return tt.resp, tt.err
+			})
+
+			assert.Equal(t, tt.resp, resp)
+			assert.Equal(t, tt.err, err)
+			if !tt.wantRecorded {
+				assert.Empty(t, log.entries)
+				return
+			}
+			assert.Len(t, log.entries, 1)
+			assert.Equal(t, "alice", log.entries[0].Principal)
+			assert.Equal(t, tt.method, log.entries[0].Method)
+			assert.Equal(t, tt.wantCode, log.entries[0].Code)
+			assert.Equal(t, tt.wantTargetIDs, log.entries[0].TargetIDs)
+		})
+	}
+}
+
+// unaryChain calls handler through interceptors, the first one outermost.
+func unaryChain(interceptors []grpc.UnaryServerInterceptor, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) grpc.UnaryHandler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(ctx context.Context, req interface{}) (interface{}, error) {
+			return interceptor(ctx, req, info, next)
+		}
+	}
+	return handler
+}
+
+func Test_ChainAuditsVerifiedPrincipal(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUseCase := mock_usecase.NewMockAuditUseCase(ctrl)
+	auditServer := service.NewAuditServer(mockUseCase, []string{"root"})
+	log := &auditLog{}
+	chain := NewChain(config.Config{AuthJWTSecret: "test-secret"}, slog.New(slog.NewTextHandler(io.Discard, nil)), log)
+	info := &grpc.UnaryServerInfo{FullMethod: pb.AuditService_QueryAuditLog_FullMethodName}
+	call := unaryChain(chain.unary, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return auditServer.QueryAuditLog(ctx, req.(*pb.AuditLogRequest))
+	})
+
+	// A caller naming itself in x-principal is not trusted.
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-principal", "root"))
+	_, err := call(ctx, &pb.AuditLogRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   "root",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString([]byte("test-secret"))
+	assert.NoError(t, err)
+	mockUseCase.EXPECT().QueryAuditLog(gomock.Any(), models.AuditQuery{}, 0, "").Return(models.AuditPage{}, nil)
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(auth.AuthorizationKey, "Bearer "+token, "x-principal", "alice"))
+	_, err = call(ctx, &pb.AuditLogRequest{})
+	assert.NoError(t, err)
+
+	if assert.Len(t, log.entries, 2) {
+		assert.Equal(t, auth.System, log.entries[0].Principal)
+		assert.Equal(t, "PermissionDenied", log.entries[0].Code)
+		assert.Equal(t, "root", log.entries[1].Principal)
+		assert.Equal(t, "OK", log.entries[1].Code)
+	}
+}
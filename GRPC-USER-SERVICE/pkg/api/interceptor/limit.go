@@ -0,0 +1,75 @@
+package interceptor
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// healthPrefix selects the methods of the gRPC health service. A replica
+// busy with requests is still healthy, so health checks are never limited:
+// failing them would take the replica out of rotation.
+const healthPrefix = "/grpc.health.v1.Health/"
+
+// Limiter caps the number of RPCs handled concurrently. Calls over the limit
+// are rejected with ResourceExhausted straight away so that clients can retry
+// against another replica instead of queueing. Health checks are exempt.
+type Limiter struct {
+	slots chan struct{}
+}
+
+// NewLimiter returns a limiter allowing max concurrent RPCs. A max of zero
+// or less disables the limit.
+func NewLimiter(max int) *Limiter {
+	if max <= 0 {
+		return &Limiter{}
+	}
+	return &Limiter{slots: make(chan struct{}, max)}
+}
+
+func (l *Limiter) acquire() bool {
+	if l.slots == nil {
+		return true
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *Limiter) release() {
+	if l.slots != nil {
+		<-l.slots
+	}
+}
+
+func (l *Limiter) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if strings.HasPrefix(info.FullMethod, healthPrefix) {
+			return handler(ctx, req)
+		}
+		if !l.acquire() {
+			return nil, status.Error(codes.ResourceExhausted, "too many concurrent requests")
+		}
+		defer l.release()
+		return handler(ctx, req)
+	}
+}
+
+func (l *Limiter) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if strings.HasPrefix(info.FullMethod, healthPrefix) {
+			return handler(srv, ss)
+		}
+		if !l.acquire() {
+			return status.Error(codes.ResourceExhausted, "too many concurrent requests")
+		}
+		defer l.release()
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,64 @@
+package interceptor
+
+import (
+	"context"
+	"grpc-user-service/pkg/validate"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// validator is implemented by request messages that can check themselves.
+type validator interface {
+	Validate() error
+}
+
+// UnaryValidation rejects invalid requests with InvalidArgument before they
+// reach the handler. Messages implementing Validate check themselves, other
+// protobuf messages are checked against their (userservice.rules) field
+// options. Errors that already carry a gRPC status are returned unchanged.
+func UnaryValidation() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := validateRequest(req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamValidation validates every message received on a stream.
+func StreamValidation() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &validatingStream{ss})
+	}
+}
+
+type validatingStream struct {
+	grpc.ServerStream
+}
+
+func (s *validatingStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return validateRequest(m)
+}
+
+func validateRequest(req interface{}) error {
+	var err error
+	switch v := req.(type) {
+	case validator:
+		err = v.Validate()
+	case proto.Message:
+		err = validate.Message(v)
+	}
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
@@ -1,34 +1,119 @@
 package server
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"grpc-user-service/pkg/api/interceptor"
+	"grpc-user-service/pkg/audit"
 	"grpc-user-service/pkg/config"
+	"grpc-user-service/pkg/db"
+	"grpc-user-service/pkg/metrics"
+	"grpc-user-service/pkg/outbox"
 	"grpc-user-service/pkg/pb"
+	"grpc-user-service/pkg/webhook"
+	"log/slog"
 	"net"
+	"net/http"
 
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"gorm.io/gorm"
 )
 
 type Server struct {
-	server   *grpc.Server
-	listener net.Listener
+	server     *grpc.Server
+	listener   net.Listener
+	health     *health.Server
+	checker    *healthChecker
+	metrics    *http.Server
+	db         *gorm.DB
+	recorder   *audit.Recorder
+	changes    *db.Listener
+	relay      *outbox.Relay
+	dispatcher *webhook.Dispatcher
 }
 
-func NewGRPCServer(cfg config.Config, server pb.UserServiceServer) (*Server, error) {
+// NewGRPCServer returns the gRPC server. Calls are recorded in the audit log
+// through recorder unless it is nil. changes wakes up WatchUsers streams, the
+// outbox relay and the webhook dispatcher, and is closed on Stop to end them.
+// relay and dispatcher may be nil.
+func NewGRPCServer(cfg config.Config, server pb.UserServiceServer, auditServer pb.AuditServiceServer, webhookServer pb.WebhookServiceServer, recorder *audit.Recorder, changes *db.Listener, relay *outbox.Relay, dispatcher *webhook.Dispatcher, db *gorm.DB) (*Server, error) {
 	lis, err := net.Listen("tcp", cfg.Port)
 	if err != nil {
 		return nil, err
 	}
-	newServer := grpc.NewServer()
+	var auditRecorder interceptor.AuditRecorder
+	if recorder != nil {
+		auditRecorder = recorder
+	}
+	chain := interceptor.NewChain(cfg, slog.Default(), auditRecorder)
+	options := append([]grpc.ServerOption{grpc.StatsHandler(otelgrpc.NewServerHandler())}, chain.ServerOptions()...)
+	newServer := grpc.NewServer(options...)
 	pb.RegisterUserServiceServer(newServer, server)
+	pb.RegisterAuditServiceServer(newServer, auditServer)
+	pb.RegisterWebhookServiceServer(newServer, webhookServer)
+
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(newServer, healthServer)
 
 	return &Server{
-		server:   newServer,
-		listener: lis,
+		server:     newServer,
+		listener:   lis,
+		health:     healthServer,
+		checker:    newHealthChecker(db, healthServer, cfg.HealthCheckInterval),
+		metrics:    metrics.NewServer(cfg.MetricsPort),
+		db:         db,
+		recorder:   recorder,
+		changes:    changes,
+		relay:      relay,
+		dispatcher: dispatcher,
 	}, nil
 }
 
 func (c *Server) Start() error {
-	fmt.Println("grpc server listening on port :50051")
+	go c.checker.run()
+	go func() {
+		if err := c.metrics.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("metrics server stopped", "error", err)
+		}
+	}()
+	slog.Info("grpc server listening", "addr", c.listener.Addr().String())
 	return c.server.Serve(c.listener)
 }
+
+// Stop marks the server as not serving, ends WatchUsers streams, lets
+// in-flight RPCs finish until ctx expires, stops the audit recorder, the
+// outbox relay and the webhook dispatcher and then closes the database pools.
+// RPCs still running when ctx expires are cancelled.
+func (c *Server) Stop(ctx context.Context) error {
+	c.checker.stop()
+	c.health.Shutdown()
+	c.changes.Close()
+
+	stopped := make(chan struct{})
+	go func() {
+		c.server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		c.server.Stop()
+	}
+	c.metrics.Shutdown(ctx)
+	if c.recorder != nil {
+		c.recorder.Close()
+	}
+	if c.relay != nil {
+		if err := c.relay.Close(); err != nil {
+			slog.Warn("closing outbox publisher failed", "error", err)
+		}
+	}
+	if c.dispatcher != nil {
+		c.dispatcher.Close()
+	}
+
+	return db.Close(c.db)
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"grpc-user-service/pkg/auth"
+	"grpc-user-service/pkg/pb"
+	interfaces "grpc-user-service/pkg/usecase/interface"
+	"grpc-user-service/pkg/utils/models"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type AuditServer struct {
+	auditUseCase interfaces.AuditUseCase
+	admins       map[string]bool
+	pb.UnimplementedAuditServiceServer
+}
+
+// NewAuditServer returns the audit service. Only the given principals may
+// query the audit log, and only when they authenticated with a token.
+func NewAuditServer(useCaseAudit interfaces.AuditUseCase, admins []string) pb.AuditServiceServer {
+	s := &AuditServer{
+		auditUseCase: useCaseAudit,
+		admins:       make(map[string]bool, len(admins)),
+	}
+	for _, admin := range admins {
+		s.admins[admin] = true
+	}
+	return s
+}
+
+func (s *AuditServer) QueryAuditLog(ctx context.Context, req *pb.AuditLogRequest) (*pb.AuditLogResponse, error) {
+	if principal, ok := auth.Authenticated(ctx); !ok || !s.admins[principal] {
+		return &pb.AuditLogResponse{}, status.Errorf(codes.PermissionDenied, "%s may not query the audit log", principal)
+	}
+	query := models.AuditQuery{
+		Principal: req.Principal,
+		Method:    req.Method,
+		TargetID:  req.TargetId,
+	}
+	bounds := []struct {
+		name string
+		ts   *timestamppb.Timestamp
+		t    *time.Time
+	}{
+		{"after", req.After, &query.After},
+		{"before", req.Before, &query.Before},
+	}
+	for _, b := range bounds {
+		if b.ts == nil {
+			continue
+		}
+		if err := b.ts.CheckValid(); err != nil {
+			return &pb.AuditLogResponse{}, status.Errorf(codes.InvalidArgument, "%s: %v", b.name, err)
+		}
+		*b.t = b.ts.AsTime()
+	}
+	page, err := s.auditUseCase.QueryAuditLog(ctx, query, int(req.PageSize), req.PageToken)
+	if err != nil {
+		return &pb.AuditLogResponse{}, statusError(err)
+	}
+	var entries []*pb.AuditEntry
+	for _, e := range page.Entries {
+		entries = append(entries, &pb.AuditEntry{
+			Seq:        e.Seq,
+			RecordedAt: toPBTime(e.RecordedAt),
+			Principal:  e.Principal,
+			Method:     e.Method,
+			TargetIds:  e.TargetIDs,
+			Code:       e.Code,
+			RequestId:  e.RequestID,
+			PrevHash:   e.PrevHash,
+			Hash:       e.Hash,
+		})
+	}
+	return &pb.AuditLogResponse{
+		Entries:       entries,
+		NextPageToken: page.NextPageToken,
+	}, nil
+}
@@ -0,0 +1,53 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"grpc-user-service/pkg/api/service"
+	"grpc-user-service/pkg/auth"
+	"grpc-user-service/pkg/pb"
+	mock_usecase "grpc-user-service/pkg/usecase/mock"
+	"grpc-user-service/pkg/utils/models"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestAuditServer_QueryAuditLog(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUseCase := mock_usecase.NewMockAuditUseCase(ctrl)
+	auditServer := service.NewAuditServer(mockUseCase, []string{"root"})
+	after := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	recordedAt := after.Add(time.Minute)
+
+	mockUseCase.EXPECT().QueryAuditLog(gomock.Any(), models.AuditQuery{Principal: "alice", TargetID: 1, After: after}, 10, "").Times(1).Return(models.AuditPage{
+		Entries: []models.AuditEntry{
+			{Seq: 7, RecordedAt: recordedAt, Principal: "alice", Method: "/userservice.UserService/GetUserByID", TargetIDs: []int64{1}, Code: "OK", Hash: []byte("h")},
+		},
+		NextPageToken: "Nw",
+	}, nil)
+
+	ctx := auth.WithPrincipal(context.Background(), "root")
+	resp, err := auditServer.QueryAuditLog(ctx, &pb.AuditLogRequest{Principal: "alice", TargetId: 1, After: timestamppb.New(after), PageSize: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, "Nw", resp.NextPageToken)
+	assert.Len(t, resp.Entries, 1)
+	assert.Equal(t, int64(7), resp.Entries[0].Seq)
+	assert.Equal(t, recordedAt, resp.Entries[0].RecordedAt.AsTime())
+	assert.Equal(t, []int64{1}, resp.Entries[0].TargetIds)
+
+	_, err = auditServer.QueryAuditLog(ctx, &pb.AuditLogRequest{Before: &timestamppb.Timestamp{Nanos: -1}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	for _, principal := range []string{"alice", auth.System} {
+		_, err = auditServer.QueryAuditLog(auth.WithPrincipal(context.Background(), principal), &pb.AuditLogRequest{})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err), principal)
+	}
+}
@@ -3,9 +3,16 @@ package service
 import (
 	"context"
 	"errors"
+	"grpc-user-service/pkg/domain"
 	"grpc-user-service/pkg/pb"
+	"grpc-user-service/pkg/phone"
 	interfaces "grpc-user-service/pkg/usecase/interface"
 	"grpc-user-service/pkg/utils/models"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type UserSever struct {
@@ -20,61 +27,40 @@ func NewAuthServer(useCaseUser interfaces.UserUseCase) pb.UserServiceServer {
 }
 
 func (s *UserSever) GetUserByID(ctx context.Context, req *pb.UserIDRequest) (*pb.UserResponse, error) {
-	results, err := s.userUseCase.GetUserByID(req.Id)
+	results, err := s.userUseCase.GetUserByID(ctx, req.Id)
 	if err != nil {
-		return &pb.UserResponse{}, errors.New("user not found")
+		return &pb.UserResponse{}, statusError(err)
 	}
-	return &pb.UserResponse{User: &pb.User{
-		Id:      results.ID,
-		Fname:   results.Fname,
-		City:    results.City,
-		Phone:   results.Phone,
-		Height:  results.Height,
-		Married: results.Married,
-	}}, nil
+	return &pb.UserResponse{User: toPBUser(results)}, nil
 }
 
 func (s *UserSever) GetUsersByIDs(ctx context.Context, req *pb.UserIDsRequest) (*pb.UsersResponse, error) {
-	users, err := s.userUseCase.GetUsersByIDs(req.Ids)
+	users, err := s.userUseCase.GetUsersByIDs(ctx, req.Ids, req.Strict)
 	if err != nil {
-		return &pb.UsersResponse{}, err
+		return &pb.UsersResponse{}, statusError(err)
 	}
 	var result []*pb.User
-	for _, user := range users {
-		result = append(result, &pb.User{
-			Id:      user.ID,
-			Fname:   user.Fname,
-			City:    user.City,
-			Phone:   user.Phone,
-			Height:  user.Height,
-			Married: user.Married,
-		})
+	for _, user := range users.Users {
+		result = append(result, toPBUser(user))
 	}
 	return &pb.UsersResponse{
-		Users: result,
+		Users:       result,
+		NotFoundIds: users.NotFoundIDs,
 	}, nil
 }
 
 func (s *UserSever) SearchUsers(ctx context.Context, req *pb.SearchRequest) (*pb.UsersResponse, error) {
-	search := models.SearchUser{
-		City:    req.City,
-		Phone:   req.Phone,
-		Married: req.Married,
-	}
-	users, err := s.userUseCase.SearchUsers(search)
+	search, err := searchFromPB(req)
 	if err != nil {
 		return &pb.UsersResponse{}, err
 	}
+	users, err := s.userUseCase.SearchUsers(ctx, search)
+	if err != nil {
+		return &pb.UsersResponse{}, statusError(err)
+	}
 	var result []*pb.User
 	for _, user := range users {
-		result = append(result, &pb.User{
-			Id:      user.ID,
-			Fname:   user.Fname,
-			City:    user.City,
-			Phone:   user.Phone,
-			Height:  user.Height,
-			Married: user.Married,
-		})
+		result = append(result, toPBUser(user))
 	}
 	return &pb.UsersResponse{
 		Users: result,
@@ -88,10 +74,201 @@ func (s *UserSever) AddUser(ctx context.Context, req *pb.AddUserRequest) (*pb.Ad
 		Phone:   req.User.Phone,
 		Height:  req.User.Height,
 		Married: req.User.Married,
+		Region:  req.User.Region,
 	}
-	err := s.userUseCase.AddUser(newUser)
+	err := s.userUseCase.AddUser(ctx, newUser)
 	if err != nil {
-		return &pb.AddUserResponse{}, err
+		return &pb.AddUserResponse{}, statusError(err)
 	}
 	return &pb.AddUserResponse{}, nil
 }
+
+func (s *UserSever) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.UserResponse, error) {
+	user := models.User{
+		Fname:   req.User.Fname,
+		City:    req.User.City,
+		Phone:   req.User.Phone,
+		Height:  req.User.Height,
+		Married: req.User.Married,
+		Region:  req.User.Region,
+	}
+	updated, err := s.userUseCase.UpdateUser(ctx, req.Id, user, req.Version)
+	if err != nil {
+		return &pb.UserResponse{}, statusError(err)
+	}
+	return &pb.UserResponse{User: toPBUser(updated)}, nil
+}
+
+func (s *UserSever) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*pb.DeleteUserResponse, error) {
+	if err := s.userUseCase.DeleteUser(ctx, req.Id, req.Version); err != nil {
+		return &pb.DeleteUserResponse{}, statusError(err)
+	}
+	return &pb.DeleteUserResponse{}, nil
+}
+
+func (s *UserSever) GetUserHistory(ctx context.Context, req *pb.UserHistoryRequest) (*pb.UserHistoryResponse, error) {
+	history, err := s.userUseCase.GetUserHistory(ctx, req.Id, int(req.PageSize), req.PageToken)
+	if err != nil {
+		return &pb.UserHistoryResponse{}, statusError(err)
+	}
+	var changes []*pb.UserChange
+	for _, change := range history.Changes {
+		changes = append(changes, toPBChange(change))
+	}
+	return &pb.UserHistoryResponse{
+		Changes:       changes,
+		NextPageToken: history.NextPageToken,
+	}, nil
+}
+
+func (s *UserSever) GetUserAsOf(ctx context.Context, req *pb.UserAsOfRequest) (*pb.UserResponse, error) {
+	if err := req.AsOf.CheckValid(); err != nil {
+		return &pb.UserResponse{}, status.Errorf(codes.InvalidArgument, "as_of: %v", err)
+	}
+	user, err := s.userUseCase.GetUserAsOf(ctx, req.Id, req.AsOf.AsTime())
+	if err != nil {
+		return &pb.UserResponse{}, statusError(err)
+	}
+	return &pb.UserResponse{User: toPBUser(user)}, nil
+}
+
+// WatchUsers streams the change feed until the client goes away. When the
+// server stops, the stream ends with UNAVAILABLE and the client resumes with
+// the token of the last event it received.
+func (s *UserSever) WatchUsers(req *pb.WatchUsersRequest, stream pb.UserService_WatchUsersServer) error {
+	err := s.userUseCase.WatchUsers(stream.Context(), req.ResumeToken, func(event models.UserEvent) error {
+		return stream.Send(&pb.UserEvent{
+			Type:        eventTypes[event.Type],
+			User:        toPBUser(event.User),
+			Actor:       event.Actor,
+			ChangedAt:   toPBTime(event.ChangedAt),
+			ResumeToken: event.ResumeToken,
+		})
+	})
+	return statusError(err)
+}
+
+// searchFromPB converts req, rejecting sort orders and timestamps that the
+// field rules cannot express.
+func searchFromPB(req *pb.SearchRequest) (models.SearchUser, error) {
+	search := models.SearchUser{
+		City:       req.City,
+		Phone:      req.Phone,
+		Married:    req.Married,
+		Region:     req.Region,
+		Descending: req.Descending,
+	}
+	switch req.SortBy {
+	case pb.SortBy_SORT_BY_ID:
+		search.SortBy = models.SortByID
+	case pb.SortBy_SORT_BY_CREATED_AT:
+		search.SortBy = models.SortByCreatedAt
+	case pb.SortBy_SORT_BY_UPDATED_AT:
+		search.SortBy = models.SortByUpdatedAt
+	default:
+		return models.SearchUser{}, status.Errorf(codes.InvalidArgument, "sort_by: unknown value %d", req.SortBy)
+	}
+	bounds := []struct {
+		name string
+		ts   *timestamppb.Timestamp
+		t    *time.Time
+	}{
+		{"created_after", req.CreatedAfter, &search.CreatedAfter},
+		{"created_before", req.CreatedBefore, &search.CreatedBefore},
+		{"updated_after", req.UpdatedAfter, &search.UpdatedAfter},
+		{"updated_before", req.UpdatedBefore, &search.UpdatedBefore},
+	}
+	for _, b := range bounds {
+		if b.ts == nil {
+			continue
+		}
+		if err := b.ts.CheckValid(); err != nil {
+			return models.SearchUser{}, status.Errorf(codes.InvalidArgument, "%s: %v", b.name, err)
+		}
+		*b.t = b.ts.AsTime()
+	}
+	return search, nil
+}
+
+func toPBUser(user models.Users) *pb.User {
+	return &pb.User{
+		Id:        user.ID,
+		Fname:     user.Fname,
+		City:      user.City,
+		Phone:     user.Phone,
+		Height:    user.Height,
+		Married:   user.Married,
+		Version:   user.Version,
+		CreatedAt: toPBTime(user.CreatedAt),
+		UpdatedAt: toPBTime(user.UpdatedAt),
+		CreatedBy: user.CreatedBy,
+		UpdatedBy: user.UpdatedBy,
+	}
+}
+
+var changeOperations = map[string]pb.ChangeOperation{
+	models.ChangeSnapshot: pb.ChangeOperation_CHANGE_OPERATION_SNAPSHOT,
+	models.ChangeInsert:   pb.ChangeOperation_CHANGE_OPERATION_INSERT,
+	models.ChangeUpdate:   pb.ChangeOperation_CHANGE_OPERATION_UPDATE,
+	models.ChangeDelete:   pb.ChangeOperation_CHANGE_OPERATION_DELETE,
+}
+
+var eventTypes = map[string]pb.UserEventType{
+	models.EventCreated: pb.UserEventType_USER_EVENT_TYPE_CREATED,
+	models.EventUpdated: pb.UserEventType_USER_EVENT_TYPE_UPDATED,
+	models.EventDeleted: pb.UserEventType_USER_EVENT_TYPE_DELETED,
+}
+
+func toPBChange(change models.UserChange) *pb.UserChange {
+	pbChange := &pb.UserChange{
+		Id:        change.ID,
+		UserId:    change.UserID,
+		Operation: changeOperations[change.Operation],
+		Actor:     change.Actor,
+		ChangedAt: toPBTime(change.ChangedAt),
+	}
+	if change.Old != nil {
+		pbChange.OldUser = toPBUser(*change.Old)
+	}
+	if change.New != nil {
+		pbChange.NewUser = toPBUser(*change.New)
+	}
+	return pbChange
+}
+
+// toPBTime leaves zero times unset.
+func toPBTime(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// statusError gives errors caused by the request itself, or by its
+// cancellation, a gRPC status code so that callers can tell them apart from
+// server failures.
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	case errors.Is(err, phone.ErrInvalid):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, domain.ErrUserAlreadyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, domain.ErrUserNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, domain.ErrVersionMismatch):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, domain.ErrInvalidPageToken):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, domain.ErrInvalidResumeToken):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, domain.ErrShuttingDown):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, domain.ErrSubscriptionNotFound), errors.Is(err, domain.ErrDeliveryNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, domain.ErrInvalidWebhookURL), errors.Is(err, domain.ErrInvalidEventType):
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return err
+}
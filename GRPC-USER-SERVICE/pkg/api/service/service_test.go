@@ -3,15 +3,24 @@ package service_test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"grpc-user-service/pkg/api/service"
+	"grpc-user-service/pkg/domain"
 	"grpc-user-service/pkg/pb"
+	"grpc-user-service/pkg/phone"
 	mock_usecase "grpc-user-service/pkg/usecase/mock"
 	"grpc-user-service/pkg/utils/models"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 func TestUserServer_GetUserByID(t *testing.T) {
@@ -32,7 +41,7 @@ func TestUserServer_GetUserByID(t *testing.T) {
 			userID: 1,
 			buildStubs: func() {
 				mockUseCase.EXPECT().
-					GetUserByID(int64(1)).
+					GetUserByID(gomock.Any(), int64(1)).
 					Times(1).
 					Return(models.Users{ID: 1, Fname: "John", City: "New York", Phone: "1234567890", Height: 180, Married: false}, nil)
 			},
@@ -47,16 +56,44 @@ func TestUserServer_GetUserByID(t *testing.T) {
 			userID: 2,
 			buildStubs: func() {
 				mockUseCase.EXPECT().
-					GetUserByID(int64(2)).
+					GetUserByID(gomock.Any(), int64(2)).
 					Times(1).
-					Return(models.Users{}, errors.New("user not found"))
+					Return(models.Users{}, domain.ErrUserNotFound)
 			},
 			checkResponse: func(t *testing.T, resp *pb.UserResponse, err error) {
-				assert.Error(t, err)
+				assert.Equal(t, codes.NotFound, status.Code(err))
 				assert.NotNil(t, resp)
 				assert.Nil(t, resp.User)
 			},
 		},
+		{
+			name:   "Deadline Exceeded",
+			userID: 3,
+			buildStubs: func() {
+				mockUseCase.EXPECT().
+					GetUserByID(gomock.Any(), int64(3)).
+					Times(1).
+					Return(models.Users{}, fmt.Errorf("query: %w", context.DeadlineExceeded))
+			},
+			checkResponse: func(t *testing.T, resp *pb.UserResponse, err error) {
+				assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+				assert.Nil(t, resp.User)
+			},
+		},
+		{
+			name:   "Canceled",
+			userID: 4,
+			buildStubs: func() {
+				mockUseCase.EXPECT().
+					GetUserByID(gomock.Any(), int64(4)).
+					Times(1).
+					Return(models.Users{}, context.Canceled)
+			},
+			checkResponse: func(t *testing.T, resp *pb.UserResponse, err error) {
+				assert.Equal(t, codes.Canceled, status.Code(err))
+				assert.Nil(t, resp.User)
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -81,6 +118,7 @@ func TestUserServer_GetUsersByIDs(t *testing.T) {
 	testCases := []struct {
 		name          string
 		userIDs       []int64
+		strict        bool
 		buildStubs    func()
 		checkResponse func(t *testing.T, resp *pb.UsersResponse, err error)
 	}{
@@ -89,12 +127,12 @@ func TestUserServer_GetUsersByIDs(t *testing.T) {
 			userIDs: []int64{1, 2},
 			buildStubs: func() {
 				mockUseCase.EXPECT().
-					GetUsersByIDs([]int64{1, 2}).
+					GetUsersByIDs(gomock.Any(), []int64{1, 2}, false).
 					Times(1).
-					Return([]models.Users{
+					Return(models.UsersByIDs{Users: []models.Users{
 						{ID: 1, Fname: "John", City: "New York", Phone: "1234567890", Height: 180, Married: false},
 						{ID: 2, Fname: "Doe", City: "Los Angeles", Phone: "0987654321", Height: 170, Married: true},
-					}, nil)
+					}}, nil)
 			},
 			checkResponse: func(t *testing.T, resp *pb.UsersResponse, err error) {
 				assert.NoError(t, err)
@@ -103,14 +141,46 @@ func TestUserServer_GetUsersByIDs(t *testing.T) {
 				assert.Equal(t, "Doe", resp.Users[1].Fname)
 			},
 		},
+		{
+			name:    "NotFoundIDs",
+			userIDs: []int64{1, 2},
+			buildStubs: func() {
+				mockUseCase.EXPECT().
+					GetUsersByIDs(gomock.Any(), []int64{1, 2}, false).
+					Times(1).
+					Return(models.UsersByIDs{Users: []models.Users{
+						{ID: 1, Fname: "John", City: "New York", Phone: "1234567890", Height: 180, Married: false},
+					}, NotFoundIDs: []int64{2}}, nil)
+			},
+			checkResponse: func(t *testing.T, resp *pb.UsersResponse, err error) {
+				assert.NoError(t, err)
+				assert.Len(t, resp.Users, 1)
+				assert.Equal(t, []int64{2}, resp.NotFoundIds)
+			},
+		},
+		{
+			name:    "StrictNotFound",
+			userIDs: []int64{1, 2},
+			strict:  true,
+			buildStubs: func() {
+				mockUseCase.EXPECT().
+					GetUsersByIDs(gomock.Any(), []int64{1, 2}, true).
+					Times(1).
+					Return(models.UsersByIDs{}, domain.ErrUserNotFound)
+			},
+			checkResponse: func(t *testing.T, resp *pb.UsersResponse, err error) {
+				assert.Equal(t, codes.NotFound, status.Code(err))
+				assert.Nil(t, resp.Users)
+			},
+		},
 		{
 			name:    "Error",
 			userIDs: []int64{1, 2},
 			buildStubs: func() {
 				mockUseCase.EXPECT().
-					GetUsersByIDs([]int64{1, 2}).
+					GetUsersByIDs(gomock.Any(), []int64{1, 2}, false).
 					Times(1).
-					Return(nil, errors.New("internal error"))
+					Return(models.UsersByIDs{}, errors.New("internal error"))
 			},
 			checkResponse: func(t *testing.T, resp *pb.UsersResponse, err error) {
 				assert.Error(t, err)
@@ -122,7 +192,7 @@ func TestUserServer_GetUsersByIDs(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			tc.buildStubs()
-			req := &pb.UserIDsRequest{Ids: tc.userIDs}
+			req := &pb.UserIDsRequest{Ids: tc.userIDs, Strict: tc.strict}
 			resp, err := userServer.GetUsersByIDs(context.Background(), req)
 			tc.checkResponse(t, resp, err)
 		})
@@ -135,6 +205,7 @@ func TestUserServer_SearchUsers(t *testing.T) {
 
 	mockUseCase := mock_usecase.NewMockUserUseCase(ctrl)
 	userServer := service.NewAuthServer(mockUseCase)
+	createdAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
 
 	testCases := []struct {
 		name          string
@@ -147,11 +218,11 @@ func TestUserServer_SearchUsers(t *testing.T) {
 			searchRequest: &pb.SearchRequest{
 				City:    "New York",
 				Phone:   "1234567890",
-				Married: false,
+				Married: proto.Bool(false),
 			},
 			buildStubs: func() {
 				mockUseCase.EXPECT().
-					SearchUsers(models.SearchUser{City: "New York", Phone: "1234567890", Married: false}).
+					SearchUsers(gomock.Any(), models.SearchUser{City: "New York", Phone: "1234567890", Married: proto.Bool(false)}).
 					Times(1).
 					Return([]models.Users{
 						{ID: 1, Fname: "John", City: "New York", Phone: "1234567890", Height: 180, Married: false},
@@ -168,11 +239,11 @@ func TestUserServer_SearchUsers(t *testing.T) {
 			searchRequest: &pb.SearchRequest{
 				City:    "New York",
 				Phone:   "1234567890",
-				Married: false,
+				Married: proto.Bool(false),
 			},
 			buildStubs: func() {
 				mockUseCase.EXPECT().
-					SearchUsers(models.SearchUser{City: "New York", Phone: "1234567890", Married: false}).
+					SearchUsers(gomock.Any(), models.SearchUser{City: "New York", Phone: "1234567890", Married: proto.Bool(false)}).
 					Times(1).
 					Return(nil, errors.New("search error"))
 			},
@@ -181,6 +252,46 @@ func TestUserServer_SearchUsers(t *testing.T) {
 				assert.Nil(t, resp.Users)
 			},
 		},
+		{
+			name: "Time range and sort order",
+			searchRequest: &pb.SearchRequest{
+				City:         "New York",
+				CreatedAfter: timestamppb.New(createdAt),
+				SortBy:       pb.SortBy_SORT_BY_UPDATED_AT,
+				Descending:   true,
+			},
+			buildStubs: func() {
+				mockUseCase.EXPECT().
+					SearchUsers(gomock.Any(), models.SearchUser{City: "New York", CreatedAfter: createdAt, SortBy: models.SortByUpdatedAt, Descending: true}).
+					Times(1).
+					Return([]models.Users{
+						{ID: 1, Fname: "John", City: "New York", CreatedAt: createdAt, UpdatedAt: createdAt, CreatedBy: "alice", UpdatedBy: "bob"},
+					}, nil)
+			},
+			checkResponse: func(t *testing.T, resp *pb.UsersResponse, err error) {
+				assert.NoError(t, err)
+				assert.Len(t, resp.Users, 1)
+				assert.Equal(t, createdAt, resp.Users[0].CreatedAt.AsTime())
+				assert.Equal(t, "alice", resp.Users[0].CreatedBy)
+				assert.Equal(t, "bob", resp.Users[0].UpdatedBy)
+			},
+		},
+		{
+			name:          "Unknown sort order",
+			searchRequest: &pb.SearchRequest{SortBy: pb.SortBy(7)},
+			buildStubs:    func() {},
+			checkResponse: func(t *testing.T, resp *pb.UsersResponse, err error) {
+				assert.Equal(t, codes.InvalidArgument, status.Code(err))
+			},
+		},
+		{
+			name:          "Invalid timestamp",
+			searchRequest: &pb.SearchRequest{UpdatedBefore: &timestamppb.Timestamp{Nanos: -1}},
+			buildStubs:    func() {},
+			checkResponse: func(t *testing.T, resp *pb.UsersResponse, err error) {
+				assert.Equal(t, codes.InvalidArgument, status.Code(err))
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -218,7 +329,7 @@ func TestUserServer_AddUser(t *testing.T) {
 			},
 			buildStubs: func() {
 				mockUseCase.EXPECT().
-					AddUser(models.User{Fname: "John", City: "New York", Phone: "1234567890", Height: 180, Married: false}).
+					AddUser(gomock.Any(), models.User{Fname: "John", City: "New York", Phone: "1234567890", Height: 180, Married: false}).
 					Times(1).
 					Return(nil)
 			},
@@ -240,7 +351,7 @@ func TestUserServer_AddUser(t *testing.T) {
 			},
 			buildStubs: func() {
 				mockUseCase.EXPECT().
-					AddUser(models.User{Fname: "John", City: "New York", Phone: "1234567890", Height: 180, Married: false}).
+					AddUser(gomock.Any(), models.User{Fname: "John", City: "New York", Phone: "1234567890", Height: 180, Married: false}).
 					Times(1).
 					Return(errors.New("add user error"))
 			},
@@ -250,6 +361,51 @@ func TestUserServer_AddUser(t *testing.T) {
 				assert.Empty(t, resp)
 			},
 		},
+		{
+			name: "InvalidPhone",
+			addUserRequest: &pb.AddUserRequest{
+				User: &pb.Users{
+					Fname:   "John",
+					City:    "New York",
+					Phone:   "12345",
+					Height:  180,
+					Married: false,
+					Region:  "US",
+				},
+			},
+			buildStubs: func() {
+				mockUseCase.EXPECT().
+					AddUser(gomock.Any(), models.User{Fname: "John", City: "New York", Phone: "12345", Height: 180, Married: false, Region: "US"}).
+					Times(1).
+					Return(phone.ErrInvalid)
+			},
+			checkResponse: func(t *testing.T, resp *pb.AddUserResponse, err error) {
+				assert.Equal(t, codes.InvalidArgument, status.Code(err))
+				assert.Empty(t, resp)
+			},
+		},
+		{
+			name: "AlreadyExists",
+			addUserRequest: &pb.AddUserRequest{
+				User: &pb.Users{
+					Fname:   "John",
+					City:    "New York",
+					Phone:   "1234567890",
+					Height:  180,
+					Married: false,
+				},
+			},
+			buildStubs: func() {
+				mockUseCase.EXPECT().
+					AddUser(gomock.Any(), models.User{Fname: "John", City: "New York", Phone: "1234567890", Height: 180, Married: false}).
+					Times(1).
+					Return(domain.ErrUserAlreadyExists)
+			},
+			checkResponse: func(t *testing.T, resp *pb.AddUserResponse, err error) {
+				assert.Equal(t, codes.AlreadyExists, status.Code(err))
+				assert.Empty(t, resp)
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -259,4 +415,178 @@ func TestUserServer_AddUser(t *testing.T) {
 			tc.checkResponse(t, resp, err)
 		})
 	}
-}
\ No newline at end of file
+}
+func TestUserServer_UpdateUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUseCase := mock_usecase.NewMockUserUseCase(ctrl)
+	userServer := service.NewAuthServer(mockUseCase)
+	request := &pb.UpdateUserRequest{
+		Id:      1,
+		User:    &pb.Users{Fname: "John", City: "Boston", Phone: "+16502530000", Height: 180},
+		Version: 2,
+	}
+	user := models.User{Fname: "John", City: "Boston", Phone: "+16502530000", Height: 180}
+
+	testCases := []struct {
+		name     string
+		err      error
+		wantCode codes.Code
+	}{
+		{name: "Success", wantCode: codes.OK},
+		{name: "VersionMismatch", err: domain.ErrVersionMismatch, wantCode: codes.Aborted},
+		{name: "NotFound", err: domain.ErrUserNotFound, wantCode: codes.NotFound},
+		{name: "DuplicatePhone", err: domain.ErrUserAlreadyExists, wantCode: codes.AlreadyExists},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			updated := models.Users{ID: 1, Fname: "John", City: "Boston", Phone: "+16502530000", Height: 180, Version: 3}
+			if tc.err != nil {
+				updated = models.Users{}
+			}
+			mockUseCase.EXPECT().UpdateUser(gomock.Any(), int64(1), user, int64(2)).Times(1).Return(updated, tc.err)
+
+			resp, err := userServer.UpdateUser(context.Background(), request)
+			assert.Equal(t, tc.wantCode, status.Code(err))
+			if tc.err == nil {
+				assert.Equal(t, int64(3), resp.User.Version)
+			}
+		})
+	}
+}
+
+func TestUserServer_DeleteUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUseCase := mock_usecase.NewMockUserUseCase(ctrl)
+	userServer := service.NewAuthServer(mockUseCase)
+
+	testCases := []struct {
+		name     string
+		err      error
+		wantCode codes.Code
+	}{
+		{name: "Success", wantCode: codes.OK},
+		{name: "VersionMismatch", err: domain.ErrVersionMismatch, wantCode: codes.Aborted},
+		{name: "NotFound", err: domain.ErrUserNotFound, wantCode: codes.NotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockUseCase.EXPECT().DeleteUser(gomock.Any(), int64(1), int64(2)).Times(1).Return(tc.err)
+
+			_, err := userServer.DeleteUser(context.Background(), &pb.DeleteUserRequest{Id: 1, Version: 2})
+			assert.Equal(t, tc.wantCode, status.Code(err))
+		})
+	}
+}
+
+func TestUserServer_GetUserHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUseCase := mock_usecase.NewMockUserUseCase(ctrl)
+	userServer := service.NewAuthServer(mockUseCase)
+	changedAt := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	mockUseCase.EXPECT().GetUserHistory(gomock.Any(), int64(1), 10, "").Times(1).Return(models.UserHistory{
+		Changes: []models.UserChange{
+			{ID: 2, UserID: 1, Operation: models.ChangeDelete, Old: &models.Users{ID: 1, City: "Kochi"}, Actor: "alice", ChangedAt: changedAt},
+		},
+		NextPageToken: "Mg",
+	}, nil)
+
+	resp, err := userServer.GetUserHistory(context.Background(), &pb.UserHistoryRequest{Id: 1, PageSize: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, "Mg", resp.NextPageToken)
+	assert.Len(t, resp.Changes, 1)
+	assert.Equal(t, pb.ChangeOperation_CHANGE_OPERATION_DELETE, resp.Changes[0].Operation)
+	assert.Equal(t, "Kochi", resp.Changes[0].OldUser.City)
+	assert.Nil(t, resp.Changes[0].NewUser)
+	assert.Equal(t, changedAt, resp.Changes[0].ChangedAt.AsTime())
+
+	mockUseCase.EXPECT().GetUserHistory(gomock.Any(), int64(1), 0, "bogus").Times(1).Return(models.UserHistory{}, domain.ErrInvalidPageToken)
+	_, err = userServer.GetUserHistory(context.Background(), &pb.UserHistoryRequest{Id: 1, PageToken: "bogus"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestUserServer_GetUserAsOf(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUseCase := mock_usecase.NewMockUserUseCase(ctrl)
+	userServer := service.NewAuthServer(mockUseCase)
+	asOf := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		err      error
+		wantCode codes.Code
+	}{
+		{name: "Success", wantCode: codes.OK},
+		{name: "NotFound", err: domain.ErrUserNotFound, wantCode: codes.NotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockUseCase.EXPECT().GetUserAsOf(gomock.Any(), int64(1), asOf).Times(1).Return(models.Users{ID: 1, City: "Kannur"}, tc.err)
+
+			_, err := userServer.GetUserAsOf(context.Background(), &pb.UserAsOfRequest{Id: 1, AsOf: timestamppb.New(asOf)})
+			assert.Equal(t, tc.wantCode, status.Code(err))
+		})
+	}
+}
+
+// watchStream collects the events sent on a WatchUsers stream.
+type watchStream struct {
+	grpc.ServerStream
+	events []*pb.UserEvent
+}
+
+func (s *watchStream) Context() context.Context { return context.Background() }
+
+func (s *watchStream) Send(event *pb.UserEvent) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+func TestUserServer_WatchUsers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUseCase := mock_usecase.NewMockUserUseCase(ctrl)
+	userServer := service.NewAuthServer(mockUseCase)
+	changedAt := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		err      error
+		wantCode codes.Code
+	}{
+		{name: "ShuttingDown", err: domain.ErrShuttingDown, wantCode: codes.Unavailable},
+		{name: "InvalidToken", err: domain.ErrInvalidResumeToken, wantCode: codes.InvalidArgument},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockUseCase.EXPECT().WatchUsers(gomock.Any(), "MTAxLjU", gomock.Any()).Times(1).DoAndReturn(
+				func(ctx context.Context, token string, send func(models.UserEvent) error) error {
+					assert.NoError(t, send(models.UserEvent{Type: models.EventUpdated, User: models.Users{ID: 1, Version: 2}, Actor: "alice", ChangedAt: changedAt, ResumeToken: "MTAyLjc"}))
+					return tc.err
+				})
+
+			stream := &watchStream{}
+			err := userServer.WatchUsers(&pb.WatchUsersRequest{ResumeToken: "MTAxLjU"}, stream)
+
+			assert.Equal(t, tc.wantCode, status.Code(err))
+			assert.Len(t, stream.events, 1)
+			assert.Equal(t, pb.UserEventType_USER_EVENT_TYPE_UPDATED, stream.events[0].Type)
+			assert.Equal(t, int64(2), stream.events[0].User.Version)
+			assert.Equal(t, changedAt, stream.events[0].ChangedAt.AsTime())
+			assert.Equal(t, "MTAyLjc", stream.events[0].ResumeToken)
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"grpc-user-service/pkg/auth"
+	"grpc-user-service/pkg/pb"
+	interfaces "grpc-user-service/pkg/usecase/interface"
+	"grpc-user-service/pkg/utils/models"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// deliveryStatuses maps the delivery statuses to their protobuf form.
+var deliveryStatuses = map[string]pb.WebhookDeliveryStatus{
+	models.DeliveryPending:   pb.WebhookDeliveryStatus_WEBHOOK_DELIVERY_STATUS_PENDING,
+	models.DeliveryDelivered: pb.WebhookDeliveryStatus_WEBHOOK_DELIVERY_STATUS_DELIVERED,
+	models.DeliveryFailed:    pb.WebhookDeliveryStatus_WEBHOOK_DELIVERY_STATUS_FAILED,
+}
+
+type WebhookServer struct {
+	webhookUseCase interfaces.WebhookUseCase
+	admins         map[string]bool
+	pb.UnimplementedWebhookServiceServer
+}
+
+// NewWebhookServer returns the webhook service. Only the given principals may
+// use it, and only when they authenticated with a token.
+func NewWebhookServer(useCaseWebhook interfaces.WebhookUseCase, admins []string) pb.WebhookServiceServer {
+	s := &WebhookServer{
+		webhookUseCase: useCaseWebhook,
+		admins:         make(map[string]bool, len(admins)),
+	}
+	for _, admin := range admins {
+		s.admins[admin] = true
+	}
+	return s
+}
+
+func (s *WebhookServer) authorize(ctx context.Context) error {
+	if principal, ok := auth.Authenticated(ctx); !ok || !s.admins[principal] {
+		return status.Errorf(codes.PermissionDenied, "%s may not manage webhooks", principal)
+	}
+	return nil
+}
+
+func (s *WebhookServer) CreateWebhookSubscription(ctx context.Context, req *pb.CreateWebhookSubscriptionRequest) (*pb.WebhookSubscription, error) {
+	if err := s.authorize(ctx); err != nil {
+		return &pb.WebhookSubscription{}, err
+	}
+	sub, err := s.webhookUseCase.CreateWebhookSubscription(ctx, req.Url, req.EventTypes)
+	if err != nil {
+		return &pb.WebhookSubscription{}, statusError(err)
+	}
+	return toPBSubscription(sub), nil
+}
+
+func (s *WebhookServer) ListWebhookSubscriptions(ctx context.Context, req *pb.ListWebhookSubscriptionsRequest) (*pb.ListWebhookSubscriptionsResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return &pb.ListWebhookSubscriptionsResponse{}, err
+	}
+	page, err := s.webhookUseCase.ListWebhookSubscriptions(ctx, int(req.PageSize), req.PageToken)
+	if err != nil {
+		return &pb.ListWebhookSubscriptionsResponse{}, statusError(err)
+	}
+	var subs []*pb.WebhookSubscription
+	for _, sub := range page.Subscriptions {
+		subs = append(subs, toPBSubscription(sub))
+	}
+	return &pb.ListWebhookSubscriptionsResponse{
+		Subscriptions: subs,
+		NextPageToken: page.NextPageToken,
+	}, nil
+}
+
+func (s *WebhookServer) DeleteWebhookSubscription(ctx context.Context, req *pb.DeleteWebhookSubscriptionRequest) (*pb.DeleteWebhookSubscriptionResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return &pb.DeleteWebhookSubscriptionResponse{}, err
+	}
+	if err := s.webhookUseCase.DeleteWebhookSubscription(ctx, req.Id); err != nil {
+		return &pb.DeleteWebhookSubscriptionResponse{}, statusError(err)
+	}
+	return &pb.DeleteWebhookSubscriptionResponse{}, nil
+}
+
+func (s *WebhookServer) ListWebhookDeliveries(ctx context.Context, req *pb.ListWebhookDeliveriesRequest) (*pb.ListWebhookDeliveriesResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return &pb.ListWebhookDeliveriesResponse{}, err
+	}
+	page, err := s.webhookUseCase.ListWebhookDeliveries(ctx, req.SubscriptionId, int(req.PageSize), req.PageToken)
+	if err != nil {
+		return &pb.ListWebhookDeliveriesResponse{}, statusError(err)
+	}
+	var deliveries []*pb.WebhookDelivery
+	for _, d := range page.Deliveries {
+		delivery := &pb.WebhookDelivery{
+			Id:             d.ID,
+			SubscriptionId: d.SubscriptionID,
+			EventId:        d.EventID,
+			EventType:      d.EventType,
+			UserId:         d.UserID,
+			Status:         deliveryStatuses[d.Status],
+			Attempts:       int32(d.Attempts),
+			ResponseCode:   int32(d.ResponseCode),
+			LastError:      d.LastError,
+			CreatedAt:      toPBTime(d.CreatedAt),
+			LastAttemptAt:  toPBTimePtr(d.LastAttemptAt),
+			DeliveredAt:    toPBTimePtr(d.DeliveredAt),
+		}
+		if d.Status == models.DeliveryPending {
+			delivery.NextAttemptAt = toPBTime(d.NextAttemptAt)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return &pb.ListWebhookDeliveriesResponse{
+		Deliveries:    deliveries,
+		NextPageToken: page.NextPageToken,
+	}, nil
+}
+
+func (s *WebhookServer) ReplayWebhookDeliveries(ctx context.Context, req *pb.ReplayWebhookDeliveriesRequest) (*pb.ReplayWebhookDeliveriesResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return &pb.ReplayWebhookDeliveriesResponse{}, err
+	}
+	var since time.Time
+	if req.Since != nil {
+		if err := req.Since.CheckValid(); err != nil {
+			return &pb.ReplayWebhookDeliveriesResponse{}, status.Errorf(codes.InvalidArgument, "since: %v", err)
+		}
+		since = req.Since.AsTime()
+	}
+	n, err := s.webhookUseCase.ReplayWebhookDeliveries(ctx, req.SubscriptionId, req.DeliveryId, since)
+	if err != nil {
+		return &pb.ReplayWebhookDeliveriesResponse{}, statusError(err)
+	}
+	return &pb.ReplayWebhookDeliveriesResponse{Replayed: n}, nil
+}
+
+func toPBSubscription(sub models.WebhookSubscription) *pb.WebhookSubscription {
+	return &pb.WebhookSubscription{
+		Id:         sub.ID,
+		Url:        sub.URL,
+		EventTypes: sub.EventTypes,
+		Secret:     sub.Secret,
+		CreatedBy:  sub.CreatedBy,
+		CreatedAt:  toPBTime(sub.CreatedAt),
+	}
+}
+
+func toPBTimePtr(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return toPBTime(*t)
+}
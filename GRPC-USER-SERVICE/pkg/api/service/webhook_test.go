@@ -0,0 +1,128 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"grpc-user-service/pkg/api/service"
+	"grpc-user-service/pkg/auth"
+	"grpc-user-service/pkg/domain"
+	"grpc-user-service/pkg/pb"
+	mock_usecase "grpc-user-service/pkg/usecase/mock"
+	"grpc-user-service/pkg/utils/models"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestWebhookServer_Subscriptions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUseCase := mock_usecase.NewMockWebhookUseCase(ctrl)
+	webhookServer := service.NewWebhookServer(mockUseCase, []string{"root"})
+	ctx := auth.WithPrincipal(context.Background(), "root")
+	createdAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	mockUseCase.EXPECT().CreateWebhookSubscription(gomock.Any(), "https://example.com/hook", []string{"UserCreated"}).Return(models.WebhookSubscription{
+		ID: 3, URL: "https://example.com/hook", EventTypes: []string{"UserCreated"}, Secret: "whsec_1", CreatedBy: "root", CreatedAt: createdAt,
+	}, nil)
+	sub, err := webhookServer.CreateWebhookSubscription(ctx, &pb.CreateWebhookSubscriptionRequest{Url: "https://example.com/hook", EventTypes: []string{"UserCreated"}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), sub.Id)
+	assert.Equal(t, "whsec_1", sub.Secret)
+	assert.Equal(t, createdAt, sub.CreatedAt.AsTime())
+
+	mockUseCase.EXPECT().CreateWebhookSubscription(gomock.Any(), "ftp://example.com", nil).Return(models.WebhookSubscription{}, domain.ErrInvalidWebhookURL)
+	_, err = webhookServer.CreateWebhookSubscription(ctx, &pb.CreateWebhookSubscriptionRequest{Url: "ftp://example.com"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	mockUseCase.EXPECT().ListWebhookSubscriptions(gomock.Any(), 10, "").Return(models.WebhookSubscriptionPage{
+		Subscriptions: []models.WebhookSubscription{{ID: 3, URL: "https://example.com/hook", EventTypes: []string{}}},
+		NextPageToken: "Mw",
+	}, nil)
+	list, err := webhookServer.ListWebhookSubscriptions(ctx, &pb.ListWebhookSubscriptionsRequest{PageSize: 10})
+	assert.NoError(t, err)
+	assert.Len(t, list.Subscriptions, 1)
+	assert.Empty(t, list.Subscriptions[0].Secret)
+	assert.Equal(t, "Mw", list.NextPageToken)
+
+	mockUseCase.EXPECT().DeleteWebhookSubscription(gomock.Any(), int64(4)).Return(domain.ErrSubscriptionNotFound)
+	_, err = webhookServer.DeleteWebhookSubscription(ctx, &pb.DeleteWebhookSubscriptionRequest{Id: 4})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestWebhookServer_Deliveries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockUseCase := mock_usecase.NewMockWebhookUseCase(ctrl)
+	webhookServer := service.NewWebhookServer(mockUseCase, []string{"root"})
+	ctx := auth.WithPrincipal(context.Background(), "root")
+	createdAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	attemptedAt := createdAt.Add(time.Second)
+
+	mockUseCase.EXPECT().ListWebhookDeliveries(gomock.Any(), int64(3), 0, "").Return(models.WebhookDeliveryPage{
+		Deliveries: []models.WebhookDelivery{
+			{ID: 9, SubscriptionID: 3, EventID: 42, EventType: "UserUpdated", UserID: 7, Status: models.DeliveryPending, Attempts: 2, ResponseCode: 503, LastError: "webhook responded 503 Service Unavailable", CreatedAt: createdAt, NextAttemptAt: attemptedAt.Add(time.Minute), LastAttemptAt: &attemptedAt},
+			{ID: 8, SubscriptionID: 3, EventID: 41, EventType: "UserCreated", UserID: 7, Status: models.DeliveryDelivered, Attempts: 1, ResponseCode: 204, CreatedAt: createdAt, NextAttemptAt: createdAt, LastAttemptAt: &attemptedAt, DeliveredAt: &attemptedAt},
+		},
+	}, nil)
+	list, err := webhookServer.ListWebhookDeliveries(ctx, &pb.ListWebhookDeliveriesRequest{SubscriptionId: 3})
+	assert.NoError(t, err)
+	assert.Len(t, list.Deliveries, 2)
+	pending, delivered := list.Deliveries[0], list.Deliveries[1]
+	assert.Equal(t, pb.WebhookDeliveryStatus_WEBHOOK_DELIVERY_STATUS_PENDING, pending.Status)
+	assert.Equal(t, int32(503), pending.ResponseCode)
+	assert.Equal(t, attemptedAt.Add(time.Minute), pending.NextAttemptAt.AsTime())
+	assert.Nil(t, pending.DeliveredAt)
+	assert.Equal(t, pb.WebhookDeliveryStatus_WEBHOOK_DELIVERY_STATUS_DELIVERED, delivered.Status)
+	assert.Nil(t, delivered.NextAttemptAt)
+	assert.Equal(t, attemptedAt, delivered.DeliveredAt.AsTime())
+
+	since := createdAt
+	mockUseCase.EXPECT().ReplayWebhookDeliveries(gomock.Any(), int64(3), int64(0), since).Return(int64(2), nil)
+	replay, err := webhookServer.ReplayWebhookDeliveries(ctx, &pb.ReplayWebhookDeliveriesRequest{SubscriptionId: 3, Since: timestamppb.New(since)})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), replay.Replayed)
+
+	mockUseCase.EXPECT().ReplayWebhookDeliveries(gomock.Any(), int64(3), int64(5), time.Time{}).Return(int64(0), domain.ErrDeliveryNotFound)
+	_, err = webhookServer.ReplayWebhookDeliveries(ctx, &pb.ReplayWebhookDeliveriesRequest{SubscriptionId: 3, DeliveryId: 5})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = webhookServer.ReplayWebhookDeliveries(ctx, &pb.ReplayWebhookDeliveriesRequest{SubscriptionId: 3, Since: &timestamppb.Timestamp{Nanos: -1}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestWebhookServer_PermissionDenied(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	webhookServer := service.NewWebhookServer(mock_usecase.NewMockWebhookUseCase(ctrl), []string{"root", auth.System})
+
+	// Listing system as an admin does not let unauthenticated calls in, nor
+	// does naming an admin in x-principal.
+	forged := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-principal", "root"))
+	contexts := map[string]context.Context{
+		"alice":     auth.WithPrincipal(context.Background(), "alice"),
+		auth.System: auth.WithPrincipal(context.Background(), auth.System),
+		"forged":    forged,
+	}
+	for principal, ctx := range contexts {
+		_, err := webhookServer.CreateWebhookSubscription(ctx, &pb.CreateWebhookSubscriptionRequest{Url: "https://example.com/hook"})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err), principal)
+		_, err = webhookServer.ListWebhookSubscriptions(ctx, &pb.ListWebhookSubscriptionsRequest{})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err), principal)
+		_, err = webhookServer.DeleteWebhookSubscription(ctx, &pb.DeleteWebhookSubscriptionRequest{Id: 1})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err), principal)
+		_, err = webhookServer.ListWebhookDeliveries(ctx, &pb.ListWebhookDeliveriesRequest{SubscriptionId: 1})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err), principal)
+		_, err = webhookServer.ReplayWebhookDeliveries(ctx, &pb.ReplayWebhookDeliveriesRequest{SubscriptionId: 1})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err), principal)
+	}
+}
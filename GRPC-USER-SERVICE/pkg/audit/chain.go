@@ -0,0 +1,108 @@
+// Package audit records UserService calls in a hash-chained, append-only
+// log and verifies that the log has not been tampered with.
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	interfaces "grpc-user-service/pkg/repository/interface"
+	"grpc-user-service/pkg/utils/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Genesis is the previous hash of the first entry.
+var Genesis = make([]byte, sha256.Size)
+
+// verifyBatch is the number of entries Verify reads per query.
+const verifyBatch = 1000
+
+// Hash returns the hash of entry e following an entry with hash prev. Every
+// field is length-prefixed so that moving bytes between fields changes the
+// hash. RecordedAt must already have the microsecond precision it is stored
+// with.
+func Hash(prev []byte, e models.AuditEntry) []byte {
+	ids := make([]string, len(e.TargetIDs))
+	for i, id := range e.TargetIDs {
+		ids[i] = strconv.FormatInt(id, 10)
+	}
+	h := sha256.New()
+	h.Write(prev)
+	for _, field := range []string{
+		strconv.FormatInt(e.Seq, 10),
+		e.RecordedAt.UTC().Format(time.RFC3339Nano),
+		e.Principal,
+		e.Method,
+		strings.Join(ids, ","),
+		e.Code,
+		e.RequestID,
+	} {
+		var n [binary.MaxVarintLen64]byte
+		h.Write(n[:binary.PutUvarint(n[:], uint64(len(field)))])
+		h.Write([]byte(field))
+	}
+	return h.Sum(nil)
+}
+
+// Checkpoint names an entry of the log by sequence number and hash. Kept
+// outside the database, a checkpoint lets Verify detect that entries were
+// cut from the end of the log, which the chain alone cannot show.
+type Checkpoint struct {
+	Seq  int64
+	Hash []byte
+}
+
+// TamperError reports the first entry that does not continue the chain.
+type TamperError struct {
+	Seq    int64
+	Reason string
+}
+
+func (e *TamperError) Error() string {
+	return fmt.Sprintf("audit log entry %d: %s", e.Seq, e.Reason)
+}
+
+// Verify reads the whole log in order and checks that sequence numbers have
+// no gaps, that every entry names the hash of its predecessor and that its
+// own hash matches its contents. Each of the expected checkpoints must be
+// part of the log. It returns the checkpoint of the last entry, or a
+// *TamperError for the first entry failing a check.
+func Verify(ctx context.Context, repo interfaces.AuditRepository, expected ...Checkpoint) (Checkpoint, error) {
+	want := make(map[int64][]byte, len(expected))
+	for _, cp := range expected {
+		want[cp.Seq] = cp.Hash
+	}
+	last := Checkpoint{Hash: Genesis}
+	for {
+		entries, err := repo.List(ctx, last.Seq, verifyBatch)
+		if err != nil {
+			return Checkpoint{}, err
+		}
+		for _, e := range entries {
+			switch {
+			case e.Seq != last.Seq+1:
+				return Checkpoint{}, &TamperError{Seq: e.Seq, Reason: fmt.Sprintf("entries %d to %d are missing", last.Seq+1, e.Seq-1)}
+			case !bytes.Equal(e.PrevHash, last.Hash):
+				return Checkpoint{}, &TamperError{Seq: e.Seq, Reason: "previous hash does not match the preceding entry"}
+			case !bytes.Equal(e.Hash, Hash(e.PrevHash, e)):
+				return Checkpoint{}, &TamperError{Seq: e.Seq, Reason: "hash does not match the entry's contents"}
+			}
+			if hash, ok := want[e.Seq]; ok && !bytes.Equal(hash, e.Hash) {
+				return Checkpoint{}, &TamperError{Seq: e.Seq, Reason: "hash differs from the checkpoint"}
+			}
+			delete(want, e.Seq)
+			last = Checkpoint{Seq: e.Seq, Hash: e.Hash}
+		}
+		if len(entries) < verifyBatch {
+			break
+		}
+	}
+	for seq := range want {
+		return Checkpoint{}, &TamperError{Seq: seq, Reason: fmt.Sprintf("checkpoint is missing, the log ends at entry %d", last.Seq)}
+	}
+	return last, nil
+}
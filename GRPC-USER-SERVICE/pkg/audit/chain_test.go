@@ -0,0 +1,116 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	mock_repository "grpc-user-service/pkg/repository/mock"
+	"grpc-user-service/pkg/utils/models"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// chain returns n correctly chained entries.
+func chain(n int) []models.AuditEntry {
+	entries := make([]models.AuditEntry, n)
+	prev := Genesis
+	for i := range entries {
+		e := models.AuditEntry{
+			Seq:        int64(i + 1),
+			RecordedAt: time.Date(2024, 5, 1, 10, 0, i, 123000, time.UTC),
+			Principal:  "alice",
+			Method:     "/userservice.UserService/GetUserByID",
+			TargetIDs:  []int64{int64(i + 1)},
+			Code:       "OK",
+			RequestID:  "req",
+			PrevHash:   prev,
+		}
+		e.Hash = Hash(prev, e)
+		entries[i] = e
+		prev = e.Hash
+	}
+	return entries
+}
+
+func Test_Hash(t *testing.T) {
+	e := chain(1)[0]
+	moved := e
+	moved.Principal, moved.Method = "alice/userservice.UserService", "/GetUserByID"
+	assert.NotEqual(t, e.Hash, Hash(e.PrevHash, moved))
+
+	local := e
+	local.RecordedAt = e.RecordedAt.In(time.FixedZone("IST", 19800))
+	assert.Equal(t, e.Hash, Hash(e.PrevHash, local))
+}
+
+func Test_Verify(t *testing.T) {
+	tests := []struct {
+		name     string
+		tamper   func(entries []models.AuditEntry) []models.AuditEntry
+		expected []Checkpoint
+		wantSeq  int64
+		wantErr  *TamperError
+	}{
+		{
+			name:    "intact",
+			tamper:  func(entries []models.AuditEntry) []models.AuditEntry { return entries },
+			wantSeq: 3,
+		},
+		{
+			name: "changed entry",
+			tamper: func(entries []models.AuditEntry) []models.AuditEntry {
+				entries[1].Principal = "mallory"
+				return entries
+			},
+			wantErr: &TamperError{Seq: 2, Reason: "hash does not match the entry's contents"},
+		},
+		{
+			name: "rehashed entry",
+			tamper: func(entries []models.AuditEntry) []models.AuditEntry {
+				entries[1].Principal = "mallory"
+				entries[1].Hash = Hash(entries[1].PrevHash, entries[1])
+				return entries
+			},
+			wantErr: &TamperError{Seq: 3, Reason: "previous hash does not match the preceding entry"},
+		},
+		{
+			name: "removed entry",
+			tamper: func(entries []models.AuditEntry) []models.AuditEntry {
+				return append(entries[:1], entries[2:]...)
+			},
+			wantErr: &TamperError{Seq: 3, Reason: "entries 2 to 2 are missing"},
+		},
+		{
+			name:     "truncated log",
+			tamper:   func(entries []models.AuditEntry) []models.AuditEntry { return entries[:2] },
+			expected: []Checkpoint{{Seq: 3, Hash: chain(3)[2].Hash}},
+			wantErr:  &TamperError{Seq: 3, Reason: "checkpoint is missing, the log ends at entry 2"},
+		},
+		{
+			name:     "checkpoint matches",
+			tamper:   func(entries []models.AuditEntry) []models.AuditEntry { return entries },
+			expected: []Checkpoint{{Seq: 2, Hash: chain(3)[1].Hash}},
+			wantSeq:  3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mock_repository.NewMockAuditRepository(ctrl)
+			repo.EXPECT().List(gomock.Any(), int64(0), verifyBatch).Return(tt.tamper(chain(3)), nil)
+
+			last, err := Verify(context.Background(), repo, tt.expected...)
+
+			if tt.wantErr != nil {
+				assert.Equal(t, tt.wantErr, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantSeq, last.Seq)
+		})
+	}
+}
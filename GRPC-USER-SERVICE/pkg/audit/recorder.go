@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	interfaces "grpc-user-service/pkg/repository/interface"
+	"grpc-user-service/pkg/utils/models"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by Record once the recorder has been closed.
+var ErrClosed = errors.New("audit recorder closed")
+
+const (
+	// maxBatch bounds the number of entries appended in one transaction.
+	maxBatch = 100
+	// appendTimeout bounds a single append, independent of the callers.
+	appendTimeout = 10 * time.Second
+)
+
+type request struct {
+	entry  models.AuditEntry
+	result chan error
+}
+
+// Recorder appends entries to the audit log. Appending takes a lock on the
+// end of the log, so concurrent calls are grouped: while one batch is being
+// written the next one collects, and every batch is written in a single
+// transaction.
+type Recorder struct {
+	repo  interfaces.AuditRepository
+	queue chan request
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// NewRecorder starts a recorder writing to repo.
+func NewRecorder(repo interfaces.AuditRepository) *Recorder {
+	r := &Recorder{
+		repo:  repo,
+		queue: make(chan request),
+		done:  make(chan struct{}),
+	}
+	r.wg.Add(1)
+	go r.run()
+	return r
+}
+
+// Record appends entry and waits until it is committed, ctx is done or the
+// recorder is closed.
+func (r *Recorder) Record(ctx context.Context, entry models.AuditEntry) error {
+	req := request{entry: entry, result: make(chan error, 1)}
+	select {
+	case r.queue <- req:
+	case <-r.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-req.result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops the recorder after the batch being written. Entries recorded
+// afterwards fail with ErrClosed.
+func (r *Recorder) Close() {
+	close(r.done)
+	r.wg.Wait()
+}
+
+func (r *Recorder) run() {
+	defer r.wg.Done()
+	for {
+		var batch []request
+		select {
+		case req := <-r.queue:
+			batch = append(batch, req)
+		case <-r.done:
+			return
+		}
+	collect:
+		for len(batch) < maxBatch {
+			select {
+			case req := <-r.queue:
+				batch = append(batch, req)
+			default:
+				break collect
+			}
+		}
+		r.write(batch)
+	}
+}
+
+func (r *Recorder) write(batch []request) {
+	entries := make([]models.AuditEntry, len(batch))
+	for i, req := range batch {
+		entries[i] = req.entry
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), appendTimeout)
+	defer cancel()
+	err := r.repo.Append(ctx, entries)
+	for _, req := range batch {
+		req.result <- err
+	}
+}
@@ -0,0 +1,88 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"grpc-user-service/pkg/utils/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingRepo records the batches appended to it. The first append waits
+// for release, so that the following records pile up.
+type blockingRepo struct {
+	release chan struct{}
+	err     error
+
+	mu      sync.Mutex
+	batches [][]models.AuditEntry
+}
+
+func (r *blockingRepo) Append(ctx context.Context, entries []models.AuditEntry) error {
+	r.mu.Lock()
+	first := len(r.batches) == 0
+	r.batches = append(r.batches, entries)
+	r.mu.Unlock()
+	if first {
+		<-r.release
+	}
+	return r.err
+}
+
+func (r *blockingRepo) List(ctx context.Context, afterSeq int64, limit int) ([]models.AuditEntry, error) {
+	return nil, nil
+}
+
+func (r *blockingRepo) Query(ctx context.Context, query models.AuditQuery, before int64, limit int) ([]models.AuditEntry, error) {
+	return nil, nil
+}
+
+func Test_RecorderBatches(t *testing.T) {
+	repo := &blockingRepo{release: make(chan struct{})}
+	r := NewRecorder(repo)
+	defer r.Close()
+
+	var wg sync.WaitGroup
+	record := func(method string) {
+		defer wg.Done()
+		assert.NoError(t, r.Record(context.Background(), models.AuditEntry{Method: method}))
+	}
+	wg.Add(1)
+	go record("first")
+	for len(repo.snapshot()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go record("next")
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(repo.release)
+	wg.Wait()
+
+	batches := repo.snapshot()
+	assert.Len(t, batches, 2)
+	assert.Len(t, batches[0], 1)
+	assert.Len(t, batches[1], 5)
+}
+
+func Test_RecorderErrors(t *testing.T) {
+	repo := &blockingRepo{release: make(chan struct{}), err: errors.New("error")}
+	close(repo.release)
+	r := NewRecorder(repo)
+
+	assert.EqualError(t, r.Record(context.Background(), models.AuditEntry{}), "error")
+
+	r.Close()
+	assert.ErrorIs(t, r.Record(context.Background(), models.AuditEntry{}), ErrClosed)
+}
+
+func (r *blockingRepo) snapshot() [][]models.AuditEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]models.AuditEntry(nil), r.batches...)
+}
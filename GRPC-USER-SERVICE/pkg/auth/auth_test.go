@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const testSecret = "test-secret"
+
+func signToken(t *testing.T, secret string, claims jwt.RegisteredClaims) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	assert.NoError(t, err)
+	return "Bearer " + token
+}
+
+func Test_UnaryServerInterceptor(t *testing.T) {
+	expires := jwt.NewNumericDate(time.Now().Add(time.Hour))
+	valid := signToken(t, testSecret, jwt.RegisteredClaims{Subject: "alice@example.com", Issuer: "gateway", ExpiresAt: expires})
+	tests := []struct {
+		name string
+		md   metadata.MD
+		want string
+		code codes.Code
+	}{
+		{name: "token", md: metadata.Pairs(AuthorizationKey, valid), want: "alice@example.com"},
+		{name: "token wins over x-principal", md: metadata.Pairs(AuthorizationKey, valid, "x-principal", "root"), want: "alice@example.com"},
+		{name: "forged x-principal", md: metadata.Pairs("x-principal", "root"), want: System},
+		{name: "no metadata", want: System},
+		{name: "wrong secret", md: metadata.Pairs(AuthorizationKey, signToken(t, "other", jwt.RegisteredClaims{Subject: "root", Issuer: "gateway", ExpiresAt: expires})), code: codes.Unauthenticated},
+		{name: "expired", md: metadata.Pairs(AuthorizationKey, signToken(t, testSecret, jwt.RegisteredClaims{Subject: "root", Issuer: "gateway", ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Hour))})), code: codes.Unauthenticated},
+		{name: "no expiry", md: metadata.Pairs(AuthorizationKey, signToken(t, testSecret, jwt.RegisteredClaims{Subject: "root", Issuer: "gateway"})), code: codes.Unauthenticated},
+		{name: "wrong issuer", md: metadata.Pairs(AuthorizationKey, signToken(t, testSecret, jwt.RegisteredClaims{Subject: "root", Issuer: "other", ExpiresAt: expires})), code: codes.Unauthenticated},
+		{name: "system subject", md: metadata.Pairs(AuthorizationKey, signToken(t, testSecret, jwt.RegisteredClaims{Subject: System, Issuer: "gateway", ExpiresAt: expires})), code: codes.Unauthenticated},
+		{name: "no subject", md: metadata.Pairs(AuthorizationKey, signToken(t, testSecret, jwt.RegisteredClaims{Issuer: "gateway", ExpiresAt: expires})), code: codes.Unauthenticated},
+		{name: "unsigned", md: metadata.Pairs(AuthorizationKey, "Bearer eyJhbGciOiJub25lIn0.eyJzdWIiOiJyb290In0."), code: codes.Unauthenticated},
+		{name: "not a bearer token", md: metadata.Pairs(AuthorizationKey, "Basic cm9vdDpyb290"), code: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+			var got string
+			_, err := UnaryServerInterceptor(NewVerifier(testSecret, "gateway", ""))(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+				got = Principal(ctx)
+				return nil, nil
+			})
+			assert.Equal(t, tt.code, status.Code(err))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_VerifyNotConfigured(t *testing.T) {
+	token := signToken(t, testSecret, jwt.RegisteredClaims{Subject: "root", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))})
+	_, err := NewVerifier("", "", "").Verify(token[len("Bearer "):])
+	assert.ErrorIs(t, err, ErrNotConfigured)
+}
@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Cache stores encoded values under string keys. Implementations must be safe
+// for concurrent use. A shared cache, such as Redis, can be plugged in by
+// implementing it; errors should be logged and reported as misses, the
+// database stays the source of truth.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, bool)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration)
+	Delete(ctx context.Context, keys ...string)
+}
+
+// Stats are the counters of an LRU cache.
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+}
+
+type entry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// LRU is an in-process Cache holding at most size entries. The least
+// recently used entry is evicted when it is full and entries expire after
+// their TTL.
+type LRU struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+	stats Stats
+	now   func() time.Time
+}
+
+// NewLRU returns an LRU cache holding up to size entries.
+func NewLRU(size int) *LRU {
+	return &LRU{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+		now:   time.Now,
+	}
+}
+
+func (c *LRU) Get(ctx context.Context, key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+	e := elem.Value.(*entry)
+	if !e.expires.IsZero() && c.now().After(e.expires) {
+		c.remove(elem)
+		c.stats.Misses++
+		return nil, false
+	}
+	c.ll.MoveToFront(elem)
+	c.stats.Hits++
+	return e.value, true
+}
+
+// Set stores value under key. A ttl of zero keeps the entry until it is
+// evicted.
+func (c *LRU) Set(ctx context.Context, key string, value []byte, ttl time.Duration) {
+	var expires time.Time
+	if ttl > 0 {
+		expires = c.now().Add(ttl)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.ll.MoveToFront(elem)
+		elem.Value = &entry{key: key, value: value, expires: expires}
+		return
+	}
+	c.items[key] = c.ll.PushFront(&entry{key: key, value: value, expires: expires})
+	for c.ll.Len() > c.size {
+		c.remove(c.ll.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *LRU) Delete(ctx context.Context, keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		if elem, ok := c.items[key]; ok {
+			c.remove(elem)
+		}
+	}
+}
+
+// Stats returns the counters since the cache was created.
+func (c *LRU) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = c.ll.Len()
+	return stats
+}
+
+func (c *LRU) remove(elem *list.Element) {
+	c.ll.Remove(elem)
+	delete(c.items, elem.Value.(*entry).key)
+}
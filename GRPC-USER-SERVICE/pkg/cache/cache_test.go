@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LRU(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(0, 0)
+	c := NewLRU(2)
+	c.now = func() time.Time { return now }
+
+	c.Set(ctx, "a", []byte("1"), time.Minute)
+	c.Set(ctx, "b", []byte("2"), 0)
+	value, ok := c.Get(ctx, "a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), value)
+
+	// b is now the least recently used entry.
+	c.Set(ctx, "c", []byte("3"), time.Minute)
+	_, ok = c.Get(ctx, "b")
+	assert.False(t, ok)
+
+	now = now.Add(2 * time.Minute)
+	_, ok = c.Get(ctx, "a")
+	assert.False(t, ok, "expired entry returned")
+
+	c.Set(ctx, "d", []byte("4"), 0)
+	c.Delete(ctx, "d", "missing")
+	_, ok = c.Get(ctx, "d")
+	assert.False(t, ok)
+
+	assert.Equal(t, Stats{Hits: 1, Misses: 3, Evictions: 1, Entries: 1}, c.Stats())
+}
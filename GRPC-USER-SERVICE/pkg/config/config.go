@@ -1,6 +1,9 @@
 package config
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/spf13/viper"
 )
 
@@ -11,10 +14,85 @@ type Config struct {
 	DBPort     string `mapstructure:"DB_PORT"`
 	DBPassword string `mapstructure:"DB_PASSWORD"`
 	Port       string `mapstructure:"PORT"`
+
+	HealthCheckInterval time.Duration `mapstructure:"HEALTH_CHECK_INTERVAL"`
+	ShutdownTimeout     time.Duration `mapstructure:"SHUTDOWN_TIMEOUT"`
+	MetricsPort         string        `mapstructure:"METRICS_PORT"`
+	TracingExporter     string        `mapstructure:"TRACING_EXPORTER"`
+	TracingFile         string        `mapstructure:"TRACING_FILE"`
+	LogLevel            string        `mapstructure:"LOG_LEVEL"`
+	LogRedactFields     string        `mapstructure:"LOG_REDACT_FIELDS"`
+	GRPCMaxDeadline     time.Duration `mapstructure:"GRPC_MAX_DEADLINE"`
+	GRPCMaxInFlight     int           `mapstructure:"GRPC_MAX_IN_FLIGHT"`
+	DefaultPhoneRegion  string        `mapstructure:"DEFAULT_PHONE_REGION"`
+	DBMigrateOnStart    bool          `mapstructure:"DB_MIGRATE_ON_START"`
+	DBQueryTimeout      time.Duration `mapstructure:"DB_QUERY_TIMEOUT"`
+	DBDSN               string        `mapstructure:"DB_DSN"`
+	DBSSLMode           string        `mapstructure:"DB_SSLMODE"`
+	DBSSLRootCert       string        `mapstructure:"DB_SSLROOTCERT"`
+	DBApplicationName   string        `mapstructure:"DB_APPLICATION_NAME"`
+	DBStatementTimeout  time.Duration `mapstructure:"DB_STATEMENT_TIMEOUT"`
+	DBMaxOpenConns      int           `mapstructure:"DB_MAX_OPEN_CONNS"`
+	DBMaxIdleConns      int           `mapstructure:"DB_MAX_IDLE_CONNS"`
+	DBConnMaxLifetime   time.Duration `mapstructure:"DB_CONN_MAX_LIFETIME"`
+	DBConnMaxIdleTime   time.Duration `mapstructure:"DB_CONN_MAX_IDLE_TIME"`
+	DBConnectTimeout    time.Duration `mapstructure:"DB_CONNECT_TIMEOUT"`
+	DBReplicaDSNs       string        `mapstructure:"DB_REPLICA_DSNS"`
+	DBReplicaMaxLag     time.Duration `mapstructure:"DB_REPLICA_MAX_LAG"`
+	DBReplicaCheck      time.Duration `mapstructure:"DB_REPLICA_CHECK_INTERVAL"`
+	CacheSize           int           `mapstructure:"CACHE_SIZE"`
+	CacheTTL            time.Duration `mapstructure:"CACHE_TTL"`
+	AuditEnabled        bool          `mapstructure:"AUDIT_ENABLED"`
+	AuditAdmins         string        `mapstructure:"AUDIT_ADMINS"`
+	WatchPollInterval   time.Duration `mapstructure:"WATCH_POLL_INTERVAL"`
+	GRPCMaxStreams      int           `mapstructure:"GRPC_MAX_STREAMS"`
+
+	OutboxPublisher      string        `mapstructure:"OUTBOX_PUBLISHER"`
+	OutboxFile           string        `mapstructure:"OUTBOX_FILE"`
+	OutboxWebhookURL     string        `mapstructure:"OUTBOX_WEBHOOK_URL"`
+	OutboxWebhookTimeout time.Duration `mapstructure:"OUTBOX_WEBHOOK_TIMEOUT"`
+	OutboxNATSURL        string        `mapstructure:"OUTBOX_NATS_URL"`
+	OutboxNATSSubject    string        `mapstructure:"OUTBOX_NATS_SUBJECT"`
+	OutboxKafkaBrokers   string        `mapstructure:"OUTBOX_KAFKA_BROKERS"`
+	OutboxKafkaTopic     string        `mapstructure:"OUTBOX_KAFKA_TOPIC"`
+	OutboxBatchSize      int           `mapstructure:"OUTBOX_BATCH_SIZE"`
+	OutboxLease          time.Duration `mapstructure:"OUTBOX_LEASE"`
+	OutboxMaxAttempts    int           `mapstructure:"OUTBOX_MAX_ATTEMPTS"`
+	OutboxMinBackoff     time.Duration `mapstructure:"OUTBOX_MIN_BACKOFF"`
+	OutboxMaxBackoff     time.Duration `mapstructure:"OUTBOX_MAX_BACKOFF"`
+	OutboxRetention      time.Duration `mapstructure:"OUTBOX_RETENTION"`
+
+	WebhooksEnabled    bool          `mapstructure:"WEBHOOKS_ENABLED"`
+	WebhookAdmins      string        `mapstructure:"WEBHOOK_ADMINS"`
+	WebhookTimeout     time.Duration `mapstructure:"WEBHOOK_TIMEOUT"`
+	WebhookBatchSize   int           `mapstructure:"WEBHOOK_BATCH_SIZE"`
+	WebhookLease       time.Duration `mapstructure:"WEBHOOK_LEASE"`
+	WebhookMaxAttempts int           `mapstructure:"WEBHOOK_MAX_ATTEMPTS"`
+	WebhookMinBackoff  time.Duration `mapstructure:"WEBHOOK_MIN_BACKOFF"`
+	WebhookMaxBackoff  time.Duration `mapstructure:"WEBHOOK_MAX_BACKOFF"`
+	WebhookRetention   time.Duration `mapstructure:"WEBHOOK_RETENTION"`
+
+	AuthJWTSecret   string `mapstructure:"AUTH_JWT_SECRET"`
+	AuthJWTIssuer   string `mapstructure:"AUTH_JWT_ISSUER"`
+	AuthJWTAudience string `mapstructure:"AUTH_JWT_AUDIENCE"`
 }
 
 var envs = []string{
-	"DB_HOST", "DB_NAME", "DB_USER", "DB_PORT", "DB_PASSWORD", "PORT",
+	"DB_HOST", "DB_NAME", "DB_USER", "DB_PORT", "DB_PASSWORD", "PORT", "HEALTH_CHECK_INTERVAL", "SHUTDOWN_TIMEOUT", "METRICS_PORT",
+	"TRACING_EXPORTER", "TRACING_FILE", "LOG_LEVEL", "LOG_REDACT_FIELDS",
+	"GRPC_MAX_DEADLINE", "GRPC_MAX_IN_FLIGHT", "DEFAULT_PHONE_REGION", "DB_MIGRATE_ON_START",
+	"DB_QUERY_TIMEOUT",
+	"DB_DSN", "DB_SSLMODE", "DB_SSLROOTCERT", "DB_APPLICATION_NAME", "DB_STATEMENT_TIMEOUT",
+	"DB_MAX_OPEN_CONNS", "DB_MAX_IDLE_CONNS", "DB_CONN_MAX_LIFETIME", "DB_CONN_MAX_IDLE_TIME", "DB_CONNECT_TIMEOUT",
+	"DB_REPLICA_DSNS", "DB_REPLICA_MAX_LAG", "DB_REPLICA_CHECK_INTERVAL",
+	"CACHE_SIZE", "CACHE_TTL",
+	"AUDIT_ENABLED", "AUDIT_ADMINS", "WATCH_POLL_INTERVAL", "GRPC_MAX_STREAMS",
+	"OUTBOX_PUBLISHER", "OUTBOX_FILE", "OUTBOX_WEBHOOK_URL", "OUTBOX_WEBHOOK_TIMEOUT",
+	"OUTBOX_NATS_URL", "OUTBOX_NATS_SUBJECT", "OUTBOX_KAFKA_BROKERS", "OUTBOX_KAFKA_TOPIC",
+	"OUTBOX_BATCH_SIZE", "OUTBOX_LEASE", "OUTBOX_MAX_ATTEMPTS", "OUTBOX_MIN_BACKOFF", "OUTBOX_MAX_BACKOFF", "OUTBOX_RETENTION",
+	"WEBHOOKS_ENABLED", "WEBHOOK_ADMINS", "WEBHOOK_TIMEOUT", "WEBHOOK_BATCH_SIZE", "WEBHOOK_LEASE",
+	"WEBHOOK_MAX_ATTEMPTS", "WEBHOOK_MIN_BACKOFF", "WEBHOOK_MAX_BACKOFF", "WEBHOOK_RETENTION",
+	"AUTH_JWT_SECRET", "AUTH_JWT_ISSUER", "AUTH_JWT_AUDIENCE",
 }
 
 func LoadConfig() (Config, error) {
@@ -22,6 +100,54 @@ func LoadConfig() (Config, error) {
 	viper.AddConfigPath("./")
 	viper.SetConfigFile(".env")
 	viper.ReadInConfig()
+	viper.SetDefault("HEALTH_CHECK_INTERVAL", "5s")
+	viper.SetDefault("SHUTDOWN_TIMEOUT", "15s")
+	viper.SetDefault("METRICS_PORT", ":9090")
+	viper.SetDefault("TRACING_EXPORTER", "none")
+	viper.SetDefault("TRACING_FILE", "traces.json")
+	viper.SetDefault("LOG_LEVEL", "info")
+	viper.SetDefault("LOG_REDACT_FIELDS", "phone=mask,fname=redact,name=redact")
+	viper.SetDefault("GRPC_MAX_DEADLINE", "30s")
+	viper.SetDefault("GRPC_MAX_IN_FLIGHT", 100)
+	viper.SetDefault("DEFAULT_PHONE_REGION", "IN")
+	viper.SetDefault("DB_MIGRATE_ON_START", true)
+	viper.SetDefault("DB_QUERY_TIMEOUT", "5s")
+	viper.SetDefault("DB_SSLMODE", "disable")
+	viper.SetDefault("DB_APPLICATION_NAME", "grpc-user-service")
+	viper.SetDefault("DB_STATEMENT_TIMEOUT", "0s")
+	viper.SetDefault("DB_MAX_OPEN_CONNS", 25)
+	viper.SetDefault("DB_MAX_IDLE_CONNS", 10)
+	viper.SetDefault("DB_CONN_MAX_LIFETIME", "30m")
+	viper.SetDefault("DB_CONN_MAX_IDLE_TIME", "5m")
+	viper.SetDefault("DB_CONNECT_TIMEOUT", "30s")
+	viper.SetDefault("DB_REPLICA_MAX_LAG", "10s")
+	viper.SetDefault("DB_REPLICA_CHECK_INTERVAL", "5s")
+	viper.SetDefault("CACHE_SIZE", 10000)
+	viper.SetDefault("CACHE_TTL", "1m")
+	viper.SetDefault("AUDIT_ENABLED", true)
+	viper.SetDefault("WATCH_POLL_INTERVAL", "5s")
+	viper.SetDefault("GRPC_MAX_STREAMS", 100)
+	viper.SetDefault("OUTBOX_PUBLISHER", "log")
+	viper.SetDefault("OUTBOX_FILE", "outbox.jsonl")
+	viper.SetDefault("OUTBOX_WEBHOOK_TIMEOUT", "10s")
+	viper.SetDefault("OUTBOX_NATS_URL", "nats://127.0.0.1:4222")
+	viper.SetDefault("OUTBOX_NATS_SUBJECT", "users")
+	viper.SetDefault("OUTBOX_KAFKA_BROKERS", "localhost:9092")
+	viper.SetDefault("OUTBOX_KAFKA_TOPIC", "users")
+	viper.SetDefault("OUTBOX_BATCH_SIZE", 100)
+	viper.SetDefault("OUTBOX_LEASE", "30s")
+	viper.SetDefault("OUTBOX_MAX_ATTEMPTS", 10)
+	viper.SetDefault("OUTBOX_MIN_BACKOFF", "1s")
+	viper.SetDefault("OUTBOX_MAX_BACKOFF", "10m")
+	viper.SetDefault("OUTBOX_RETENTION", "168h")
+	viper.SetDefault("WEBHOOKS_ENABLED", true)
+	viper.SetDefault("WEBHOOK_TIMEOUT", "10s")
+	viper.SetDefault("WEBHOOK_BATCH_SIZE", 100)
+	viper.SetDefault("WEBHOOK_LEASE", "30s")
+	viper.SetDefault("WEBHOOK_MAX_ATTEMPTS", 12)
+	viper.SetDefault("WEBHOOK_MIN_BACKOFF", "30s")
+	viper.SetDefault("WEBHOOK_MAX_BACKOFF", "1h")
+	viper.SetDefault("WEBHOOK_RETENTION", "720h")
 	for _, env := range envs {
 		if err := viper.BindEnv(env); err != nil {
 			return config, err
@@ -30,5 +156,23 @@ func LoadConfig() (Config, error) {
 	if err := viper.Unmarshal(&config); err != nil {
 		return config, err
 	}
+	if config.OutboxBatchSize <= 0 {
+		return config, fmt.Errorf("OUTBOX_BATCH_SIZE must be positive, got %d", config.OutboxBatchSize)
+	}
+	if config.OutboxLease <= 0 {
+		return config, fmt.Errorf("OUTBOX_LEASE must be positive, got %s", config.OutboxLease)
+	}
+	if config.WebhookBatchSize <= 0 {
+		return config, fmt.Errorf("WEBHOOK_BATCH_SIZE must be positive, got %d", config.WebhookBatchSize)
+	}
+	if config.WebhookLease <= 0 {
+		return config, fmt.Errorf("WEBHOOK_LEASE must be positive, got %s", config.WebhookLease)
+	}
+	// A request cut off by the lease is not counted as an attempt, so a lease
+	// no longer than the request timeout would claim a slow endpoint's
+	// delivery again and again instead of failing it.
+	if config.WebhookLease <= config.WebhookTimeout {
+		return config, fmt.Errorf("WEBHOOK_LEASE must be longer than WEBHOOK_TIMEOUT, got %s and %s", config.WebhookLease, config.WebhookTimeout)
+	}
 	return config, nil
 }
@@ -1,42 +1,155 @@
 package db
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"grpc-user-service/pkg/config"
-	"grpc-user-service/pkg/domain"
+	"grpc-user-service/pkg/logging"
+	"grpc-user-service/pkg/tracing"
+	"log/slog"
+	"net"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
+const invalidCatalogName = "3D000"
+
+// ConnectDatabase opens the database, creating it when it does not exist, and
+// applies pending migrations unless DB_MIGRATE_ON_START is disabled.
 func ConnectDatabase(cfg config.Config) (*gorm.DB, error) {
-	psqlInfo := fmt.Sprintf("host=%s user=%s dbname=postgres port=%s password=%s sslmode=disable", cfg.DBHost, cfg.DBUser, cfg.DBPort, cfg.DBPassword)
-	db, err := gorm.Open(postgres.Open(psqlInfo), &gorm.Config{})
+	db, err := Open(cfg)
 	if err != nil {
 		return nil, err
 	}
-
-	rows, err := db.Raw("SELECT 1 FROM pg_database WHERE datname = ?", cfg.DBName).Rows()
+	if !cfg.DBMigrateOnStart {
+		return db, nil
+	}
+	migrator, err := NewMigrator(db, cfg.DefaultPhoneRegion)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	if err := migrator.Up(context.Background()); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
 
-	if !rows.Next() {
-		query := fmt.Sprintf("CREATE DATABASE %s", cfg.DBName)
-		if err := db.Exec(query).Error; err != nil {
-			return nil, err
+// Open opens the database without touching the schema. While Postgres is
+// still starting, connecting is retried with backoff for up to
+// DB_CONNECT_TIMEOUT. A missing database is created first unless the
+// connection is configured through DB_DSN.
+func Open(cfg config.Config) (*gorm.DB, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.DBConnectTimeout)
+	defer cancel()
+
+	var db *gorm.DB
+	err := retry(ctx, func() error {
+		var err error
+		db, err = open(DSN(cfg, cfg.DBName))
+		if isMissingDatabase(err) && cfg.DBDSN == "" {
+			if err := createDatabase(cfg); err != nil {
+				return err
+			}
+			db, err = open(DSN(cfg, cfg.DBName))
 		}
-		fmt.Printf("Database %s created successfully.\n", cfg.DBName)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
-	psqlInfo = fmt.Sprintf("host=%s user=%s dbname=%s port=%s password=%s sslmode=disable", cfg.DBHost, cfg.DBUser, cfg.DBName, cfg.DBPort, cfg.DBPassword)
-	db, err = gorm.Open(postgres.Open(psqlInfo), &gorm.Config{})
+
+	sqlDB, err := db.DB()
 	if err != nil {
 		return nil, err
 	}
+	configurePool(sqlDB, cfg)
 
-	db.AutoMigrate(
-		&domain.User{},
-	)
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		return nil, err
+	}
+	if dsns := ReplicaDSNs(cfg); len(dsns) > 0 {
+		replicas, err := NewReplicas(dsns, cfg.DBReplicaMaxLag, cfg.DBReplicaCheck, func(replica *sql.DB) {
+			configurePool(replica, cfg)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := db.Use(replicas); err != nil {
+			return nil, err
+		}
+	}
 	return db, nil
 }
+
+func configurePool(sqlDB *sql.DB, cfg config.Config) {
+	sqlDB.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.DBConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.DBConnMaxIdleTime)
+}
+
+func open(dsn string) (*gorm.DB, error) {
+	return gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logging.NewGormLogger(slog.Default())})
+}
+
+// createDatabase creates cfg.DBName through the postgres maintenance
+// database and closes that connection again.
+func createDatabase(cfg config.Config) error {
+	db, err := open(DSN(cfg, "postgres"))
+	if err != nil {
+		return err
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+	query := fmt.Sprintf("CREATE DATABASE %s", cfg.DBName)
+	if err := db.Exec(query).Error; err != nil {
+		return err
+	}
+	slog.Info("database created", "database", cfg.DBName)
+	return nil
+}
+
+func isMissingDatabase(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == invalidCatalogName
+}
+
+// retry calls fn until it succeeds or ctx expires, doubling the delay between
+// attempts up to five seconds. Errors that will not go away by waiting, such
+// as a wrong password, are returned at once.
+func retry(ctx context.Context, fn func() error) error {
+	delay := 250 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !isTransient(err) {
+			return err
+		}
+		slog.Warn("database not ready, retrying", "attempt", attempt, "delay", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("giving up connecting to the database: %w", err)
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, 5*time.Second)
+	}
+}
+
+// isTransient reports whether a connection error may resolve itself once
+// Postgres has finished starting: a network failure, such as a refused or
+// timed out dial, or the server reporting that it is starting up or shutting
+// down. Anything else, such as a bad password, a TLS failure or a malformed
+// DSN, is final.
+func isTransient(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "57P03" || pgErr.Code == "57P01"
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
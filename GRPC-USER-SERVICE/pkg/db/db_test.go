@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"grpc-user-service/pkg/config"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DSN(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    config.Config
+		dbname string
+		want   string
+	}{
+		{
+			name: "defaults",
+			cfg: config.Config{DBHost: "postgres", DBPort: "5432", DBUser: "postgres", DBPassword: "secret",
+				DBSSLMode: "disable", DBApplicationName: "grpc-user-service"},
+			dbname: "userservice",
+			want:   "application_name=grpc-user-service dbname=userservice host=postgres password=secret port=5432 sslmode=disable user=postgres",
+		},
+		{
+			name: "tls and statement timeout",
+			cfg: config.Config{DBHost: "db.internal", DBPort: "5432", DBUser: "svc", DBPassword: "it's a secret",
+				DBSSLMode: "verify-full", DBSSLRootCert: "/etc/ssl/root.crt", DBStatementTimeout: 30 * time.Second},
+			dbname: "userservice",
+			want:   `dbname=userservice host=db.internal password='it\'s a secret' port=5432 sslmode=verify-full sslrootcert=/etc/ssl/root.crt statement_timeout=30000 user=svc`,
+		},
+		{
+			name:   "dsn override",
+			cfg:    config.Config{DBHost: "postgres", DBDSN: "postgres://svc@db/users?sslmode=require"},
+			dbname: "userservice",
+			want:   "postgres://svc@db/users?sslmode=require",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, DSN(tt.cfg, tt.dbname))
+		})
+	}
+}
+
+// refused is the error of dialling a port nobody listens on.
+var refused = &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+
+func Test_retry(t *testing.T) {
+	t.Run("succeeds once postgres is up", func(t *testing.T) {
+		calls := 0
+		err := retry(context.Background(), func() error {
+			calls++
+			if calls < 3 {
+				return refused
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("gives up when the context expires", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		err := retry(ctx, func() error { return refused })
+		assert.ErrorIs(t, err, syscall.ECONNREFUSED)
+	})
+
+	t.Run("does not retry authentication failures", func(t *testing.T) {
+		calls := 0
+		authErr := &pgconn.PgError{Code: "28P01"}
+		err := retry(context.Background(), func() error {
+			calls++
+			return authErr
+		})
+		assert.Equal(t, authErr, err)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func Test_isTransient(t *testing.T) {
+	// A real dial failure, as pgx reports it.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+	_, dialErr := pgconn.Connect(context.Background(), "postgres://user@"+addr+"/users?connect_timeout=5")
+	var connectErr *pgconn.ConnectError
+	assert.ErrorAs(t, dialErr, &connectErr)
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "connect error from a refused dial", err: dialErr, want: true},
+		{name: "refused dial", err: refused, want: true},
+		{name: "wrapped refused dial", err: fmt.Errorf("open: %w", refused), want: true},
+		{name: "dial timeout", err: &net.OpError{Op: "dial", Net: "tcp", Err: context.DeadlineExceeded}, want: true},
+		{name: "unknown host", err: &net.DNSError{Err: "no such host", Name: "postgres", IsNotFound: true}, want: true},
+		{name: "starting up", err: &pgconn.PgError{Code: "57P03"}, want: true},
+		{name: "shutting down", err: &pgconn.PgError{Code: "57P01"}, want: true},
+		{name: "wrong password", err: &pgconn.PgError{Code: "28P01"}, want: false},
+		{name: "missing database", err: &pgconn.PgError{Code: invalidCatalogName}, want: false},
+		{name: "tls failure", err: errors.New("tls: failed to verify certificate"), want: false},
+		{name: "malformed dsn", err: errors.New("cannot parse `postgres://%`: invalid URL escape"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isTransient(tt.err))
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"grpc-user-service/pkg/config"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DSN builds the keyword/value connection string for database dbname from
+// the DB_* settings. DB_DSN, when set, is returned unchanged.
+func DSN(cfg config.Config, dbname string) string {
+	if cfg.DBDSN != "" {
+		return cfg.DBDSN
+	}
+	params := map[string]string{
+		"host":             cfg.DBHost,
+		"port":             cfg.DBPort,
+		"user":             cfg.DBUser,
+		"password":         cfg.DBPassword,
+		"dbname":           dbname,
+		"sslmode":          cfg.DBSSLMode,
+		"sslrootcert":      cfg.DBSSLRootCert,
+		"application_name": cfg.DBApplicationName,
+	}
+	if cfg.DBStatementTimeout > 0 {
+		params["statement_timeout"] = strconv.FormatInt(cfg.DBStatementTimeout.Milliseconds(), 10)
+	}
+
+	keys := make([]string, 0, len(params))
+	for key, value := range params {
+		if value != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + quoteDSNValue(params[key])
+	}
+	return strings.Join(pairs, " ")
+}
+
+// quoteDSNValue quotes values containing spaces, quotes or backslashes as
+// described for libpq keyword/value connection strings.
+func quoteDSNValue(value string) string {
+	if !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+// ReplicaDSNs splits the comma separated DB_REPLICA_DSNS. Each entry is a
+// full connection string, in keyword/value or URL form.
+func ReplicaDSNs(cfg config.Config) []string {
+	var dsns []string
+	for _, dsn := range strings.Split(cfg.DBReplicaDSNs, ",") {
+		if dsn = strings.TrimSpace(dsn); dsn != "" {
+			dsns = append(dsns, dsn)
+		}
+	}
+	return dsns
+}
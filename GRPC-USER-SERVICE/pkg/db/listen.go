@@ -0,0 +1,155 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// Listener wakes up subscribers whenever a notification arrives on a Postgres
+// channel and at least every interval. Notifications only shorten the wait:
+// while the connection is down, or when a notification is lost, subscribers
+// still wake up on the interval.
+type Listener struct {
+	db       *sql.DB
+	channel  string
+	interval time.Duration
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+
+	mu     sync.Mutex
+	subs   map[chan struct{}]struct{}
+	closed bool
+}
+
+// NewListener starts listening on channel. The listening connection is taken
+// from db's pool and held until Close.
+func NewListener(db *sql.DB, channel string, interval time.Duration) *Listener {
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &Listener{
+		db:       db,
+		channel:  channel,
+		interval: interval,
+		cancel:   cancel,
+		subs:     make(map[chan struct{}]struct{}),
+	}
+	l.wg.Add(2)
+	go l.run(ctx)
+	go l.tick(ctx)
+	return l
+}
+
+// Subscribe returns a channel receiving a value on every wake-up and a
+// function ending the subscription. Wake-ups coalesce while the subscriber is
+// busy. The channel is closed by Close.
+func (l *Listener) Subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	l.subs[ch] = struct{}{}
+	return ch, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if _, ok := l.subs[ch]; ok {
+			delete(l.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Close stops listening and closes the channels of all subscribers.
+func (l *Listener) Close() {
+	l.cancel()
+	l.wg.Wait()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	for ch := range l.subs {
+		close(ch)
+	}
+	l.subs = nil
+}
+
+func (l *Listener) broadcast() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for ch := range l.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (l *Listener) tick(ctx context.Context) {
+	defer l.wg.Done()
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.broadcast()
+		}
+	}
+}
+
+func (l *Listener) run(ctx context.Context) {
+	defer l.wg.Done()
+	for {
+		err := l.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Warn("listening for notifications failed, retrying", "channel", l.channel, "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(l.interval):
+		}
+	}
+}
+
+// listen holds a connection listening on the channel until ctx is done or
+// the connection fails. The connection is discarded afterwards rather than
+// returned to the pool still listening.
+func (l *Listener) listen(ctx context.Context) error {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = errors.New("not a pgx connection")
+	conn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return driver.ErrBadConn
+		}
+		pgConn := c.Conn()
+		if _, err = pgConn.Exec(ctx, "LISTEN "+pgx.Identifier{l.channel}.Sanitize()); err != nil {
+			return driver.ErrBadConn
+		}
+		// Changes committed while nobody was listening are found by the
+		// first wake-up.
+		l.broadcast()
+		for {
+			if _, err = pgConn.WaitForNotification(ctx); err != nil {
+				return driver.ErrBadConn
+			}
+			l.broadcast()
+		}
+	})
+	return err
+}
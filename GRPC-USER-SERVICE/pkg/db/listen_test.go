@@ -0,0 +1,69 @@
+package db
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// waitWake reports whether ch received a wake-up within timeout.
+func waitWake(ch <-chan struct{}, timeout time.Duration) bool {
+	select {
+	case _, ok := <-ch:
+		return ok
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func Test_ListenerPolls(t *testing.T) {
+	// Not a Postgres connection, so listening keeps failing and subscribers
+	// are only woken up by the interval.
+	mockDB, _, _ := sqlmock.New()
+	defer mockDB.Close()
+	l := NewListener(mockDB, "user_changes", 10*time.Millisecond)
+
+	ch, unsubscribe := l.Subscribe()
+	assert.True(t, waitWake(ch, time.Second))
+	assert.True(t, waitWake(ch, time.Second))
+
+	unsubscribe()
+	_, ok := <-ch
+	assert.False(t, ok)
+
+	ch, _ = l.Subscribe()
+	l.Close()
+	for range ch {
+	}
+	ch, _ = l.Subscribe()
+	_, ok = <-ch
+	assert.False(t, ok)
+}
+
+func Test_ListenerNotifications(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+	gormDB, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := gormDB.DB()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	l := NewListener(sqlDB, "listener_test", time.Hour)
+	defer l.Close()
+	ch, unsubscribe := l.Subscribe()
+	defer unsubscribe()
+	// The first wake-up follows LISTEN.
+	require.True(t, waitWake(ch, 10*time.Second))
+
+	require.NoError(t, gormDB.Exec(`SELECT pg_notify('listener_test', '')`).Error)
+	assert.True(t, waitWake(ch, 10*time.Second))
+}
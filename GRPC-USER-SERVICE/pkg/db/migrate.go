@@ -0,0 +1,283 @@
+package db
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockKey is the pg_advisory_xact_lock key held while migrations
+// run, so that replicas starting at the same time apply them only once.
+const migrationLockKey = 4617203519
+
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is one versioned schema change. Up and Down run inside the
+// transaction of the migration run.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(ctx context.Context, tx *gorm.DB) error
+	Down    func(ctx context.Context, tx *gorm.DB) error
+}
+
+// MigrationStatus reports whether a migration has been applied.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Migrator applies the embedded SQL migrations together with the migrations
+// written in Go.
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// NewMigrator returns a migrator for the service schema. phoneRegion is used
+// by the migration converting phone numbers to E.164.
+func NewMigrator(db *gorm.DB, phoneRegion string) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	migrations = append(migrations, Migration{
+		Version: 2,
+		Name:    "normalize_phones",
+		Up: func(ctx context.Context, tx *gorm.DB) error {
+			_, err := NormalizePhones(ctx, tx, phoneRegion)
+			return err
+		},
+		Down: func(context.Context, *gorm.DB) error { return nil },
+	})
+	return newMigrator(db, migrations)
+}
+
+func newMigrator(db *gorm.DB, migrations []Migration) (*Migrator, error) {
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("migrate: duplicate version %d", migrations[i].Version)
+		}
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migrate: unexpected file %s", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migrate: version %d has two names, %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = execSQL(string(data))
+		} else {
+			m.Down = execSQL(string(data))
+		}
+	}
+
+	var migrations []Migration
+	for _, m := range byVersion {
+		if m.Up == nil || m.Down == nil {
+			return nil, fmt.Errorf("migrate: version %d needs both an up and a down file", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	return migrations, nil
+}
+
+func execSQL(query string) func(ctx context.Context, tx *gorm.DB) error {
+	return func(ctx context.Context, tx *gorm.DB) error {
+		return tx.WithContext(ctx).Exec(query).Error
+	}
+}
+
+// Latest returns the highest known version.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, m.Latest())
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.run(ctx, func(applied map[int64]time.Time) (int64, error) {
+		current := currentVersion(applied)
+		if current == 0 {
+			return 0, nil
+		}
+		var target int64
+		for _, migration := range m.migrations {
+			if migration.Version < current {
+				target = migration.Version
+			}
+		}
+		return target, nil
+	})
+}
+
+// To migrates up or down until version is the latest applied migration.
+// Version 0 rolls back every migration.
+func (m *Migrator) To(ctx context.Context, version int64) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("migrate: unknown version %d", version)
+	}
+	return m.run(ctx, func(map[int64]time.Time) (int64, error) { return version, nil })
+}
+
+// Status lists every known migration and when it was applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var status []MigrationStatus
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := createMigrationsTable(tx); err != nil {
+			return err
+		}
+		applied, err := appliedMigrations(tx)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			s := MigrationStatus{Version: migration.Version, Name: migration.Name}
+			if at, ok := applied[migration.Version]; ok {
+				s.AppliedAt = &at
+			}
+			status = append(status, s)
+		}
+		return nil
+	})
+	return status, err
+}
+
+// run applies or rolls back migrations until the target version returned by
+// target is reached. Everything happens in one transaction holding the
+// migration lock, so a failed migration leaves the schema untouched and
+// concurrent runs wait for each other.
+func (m *Migrator) run(ctx context.Context, target func(applied map[int64]time.Time) (int64, error)) error {
+	return m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, migrationLockKey).Error; err != nil {
+			return err
+		}
+		if err := createMigrationsTable(tx); err != nil {
+			return err
+		}
+		applied, err := appliedMigrations(tx)
+		if err != nil {
+			return err
+		}
+		version, err := target(applied)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok || migration.Version > version {
+				continue
+			}
+			slog.InfoContext(ctx, "applying migration", "version", migration.Version, "name", migration.Name)
+			if err := migration.Up(ctx, tx); err != nil {
+				return fmt.Errorf("migrate: %d_%s up: %w", migration.Version, migration.Name, err)
+			}
+			if err := tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, migration.Version, migration.Name).Error; err != nil {
+				return err
+			}
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok || migration.Version <= version {
+				continue
+			}
+			slog.InfoContext(ctx, "rolling back migration", "version", migration.Version, "name", migration.Name)
+			if err := migration.Down(ctx, tx); err != nil {
+				return fmt.Errorf("migrate: %d_%s down: %w", migration.Version, migration.Name, err)
+			}
+			if err := tx.Exec(`DELETE FROM schema_migrations WHERE version=$1`, migration.Version).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+func createMigrationsTable(tx *gorm.DB) error {
+	return tx.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    bigint PRIMARY KEY,
+		name       text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`).Error
+}
+
+func appliedMigrations(tx *gorm.DB) (map[int64]time.Time, error) {
+	var rows []struct {
+		Version   int64
+		AppliedAt time.Time
+	}
+	if err := tx.Raw(`SELECT version, applied_at FROM schema_migrations`).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]time.Time, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row.AppliedAt
+	}
+	return applied, nil
+}
+
+func currentVersion(applied map[int64]time.Time) int64 {
+	var current int64
+	for version := range applied {
+		if version > current {
+			current = version
+		}
+	}
+	return current
+}
@@ -0,0 +1,177 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func Test_NewMigrator(t *testing.T) {
+	m, err := NewMigrator(nil, "IN")
+
+	assert.NoError(t, err)
+	var versions []int64
+	for _, migration := range m.migrations {
+		versions = append(versions, migration.Version)
+	}
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}, versions)
+	assert.Equal(t, int64(12), m.Latest())
+}
+
+func Test_loadMigrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		wantErr bool
+	}{
+		{
+			name: "up and down",
+			files: fstest.MapFS{
+				"m/0001_init.up.sql":   {Data: []byte("CREATE TABLE t ()")},
+				"m/0001_init.down.sql": {Data: []byte("DROP TABLE t")},
+			},
+		},
+		{
+			name:    "missing down",
+			files:   fstest.MapFS{"m/0001_init.up.sql": {Data: []byte("CREATE TABLE t ()")}},
+			wantErr: true,
+		},
+		{
+			name: "two names for one version",
+			files: fstest.MapFS{
+				"m/0001_init.up.sql":    {Data: []byte("CREATE TABLE t ()")},
+				"m/0001_other.down.sql": {Data: []byte("DROP TABLE t")},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "unexpected file",
+			files:   fstest.MapFS{"m/init.sql": {Data: []byte("CREATE TABLE t ()")}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadMigrations(tt.files, "m")
+			assert.Equal(t, tt.wantErr, err != nil, "got %v", err)
+		})
+	}
+}
+
+func Test_MigratorRun(t *testing.T) {
+	lockQuery := regexp.QuoteMeta(`SELECT pg_advisory_xact_lock($1)`)
+	createQuery := regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS schema_migrations`)
+	appliedQuery := regexp.QuoteMeta(`SELECT version, applied_at FROM schema_migrations`)
+	insertQuery := regexp.QuoteMeta(`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`)
+	deleteQuery := regexp.QuoteMeta(`DELETE FROM schema_migrations WHERE version=$1`)
+
+	expectStart := func(mockSQL sqlmock.Sqlmock, applied ...int64) {
+		mockSQL.ExpectBegin()
+		mockSQL.ExpectExec(lockQuery).WithArgs(migrationLockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+		mockSQL.ExpectExec(createQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+		rows := sqlmock.NewRows([]string{"version", "applied_at"})
+		for _, version := range applied {
+			rows.AddRow(version, time.Now())
+		}
+		mockSQL.ExpectQuery(appliedQuery).WillReturnRows(rows)
+	}
+
+	tests := []struct {
+		name    string
+		run     func(m *Migrator) error
+		stub    func(mockSQL sqlmock.Sqlmock)
+		wantErr bool
+	}{
+		{
+			name: "up applies pending migrations in order",
+			run:  func(m *Migrator) error { return m.Up(context.Background()) },
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				expectStart(mockSQL, 1)
+				mockSQL.ExpectExec("up 2").WillReturnResult(sqlmock.NewResult(0, 0))
+				mockSQL.ExpectExec(insertQuery).WithArgs(2, "two").WillReturnResult(sqlmock.NewResult(0, 1))
+				mockSQL.ExpectExec("up 3").WillReturnResult(sqlmock.NewResult(0, 0))
+				mockSQL.ExpectExec(insertQuery).WithArgs(3, "three").WillReturnResult(sqlmock.NewResult(0, 1))
+				mockSQL.ExpectCommit()
+			},
+		},
+		{
+			name: "up with nothing pending",
+			run:  func(m *Migrator) error { return m.Up(context.Background()) },
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				expectStart(mockSQL, 1, 2, 3)
+				mockSQL.ExpectCommit()
+			},
+		},
+		{
+			name: "down rolls back the latest migration",
+			run:  func(m *Migrator) error { return m.Down(context.Background()) },
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				expectStart(mockSQL, 1, 2, 3)
+				mockSQL.ExpectExec("down 3").WillReturnResult(sqlmock.NewResult(0, 0))
+				mockSQL.ExpectExec(deleteQuery).WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockSQL.ExpectCommit()
+			},
+		},
+		{
+			name: "to rolls back in reverse order",
+			run:  func(m *Migrator) error { return m.To(context.Background(), 1) },
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				expectStart(mockSQL, 1, 2, 3)
+				mockSQL.ExpectExec("down 3").WillReturnResult(sqlmock.NewResult(0, 0))
+				mockSQL.ExpectExec(deleteQuery).WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockSQL.ExpectExec("down 2").WillReturnResult(sqlmock.NewResult(0, 0))
+				mockSQL.ExpectExec(deleteQuery).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockSQL.ExpectCommit()
+			},
+		},
+		{
+			name:    "to unknown version",
+			run:     func(m *Migrator) error { return m.To(context.Background(), 9) },
+			stub:    func(mockSQL sqlmock.Sqlmock) {},
+			wantErr: true,
+		},
+		{
+			name: "failed migration rolls back the whole run",
+			run:  func(m *Migrator) error { return m.Up(context.Background()) },
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				expectStart(mockSQL)
+				mockSQL.ExpectExec("up 1").WillReturnResult(sqlmock.NewResult(0, 0))
+				mockSQL.ExpectExec(insertQuery).WithArgs(1, "one").WillReturnResult(sqlmock.NewResult(0, 1))
+				mockSQL.ExpectExec("up 2").WillReturnError(errors.New("error"))
+				mockSQL.ExpectRollback()
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, _ := sqlmock.New()
+			defer mockDB.Close()
+			gormDB, _ := gorm.Open(postgres.New(postgres.Config{
+				Conn: mockDB,
+			}), &gorm.Config{})
+			tt.stub(mockSQL)
+
+			m, err := newMigrator(gormDB, []Migration{
+				{Version: 3, Name: "three", Up: execSQL("up 3"), Down: execSQL("down 3")},
+				{Version: 1, Name: "one", Up: execSQL("up 1"), Down: execSQL("down 1")},
+				{Version: 2, Name: "two", Up: execSQL("up 2"), Down: execSQL("down 2")},
+			})
+			assert.NoError(t, err)
+
+			err = tt.run(m)
+
+			assert.Equal(t, tt.wantErr, err != nil, "got %v", err)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,12 @@
+-- Matches the table previously created by GORM AutoMigrate so that existing
+-- databases can adopt the migrations without changes.
+CREATE TABLE IF NOT EXISTS users (
+    id      bigserial PRIMARY KEY,
+    fname   text,
+    city    text,
+    phone   text,
+    height  decimal,
+    married boolean
+);
+
+ALTER TABLE users ALTER COLUMN phone TYPE text USING phone::text;
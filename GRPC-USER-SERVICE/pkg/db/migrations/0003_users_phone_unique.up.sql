@@ -0,0 +1,24 @@
+-- Users sharing a phone number, possibly only after migration 2 normalized
+-- the numbers, would make CREATE UNIQUE INDEX fail with an error naming
+-- neither the rows nor a way out. Deleting or renumbering users is not a
+-- decision a migration can make, so stop with the ids to resolve instead.
+DO $$
+DECLARE
+    duplicates text;
+BEGIN
+    SELECT string_agg(ids, '; ') INTO duplicates
+    FROM (
+        SELECT string_agg(id::text, ', ' ORDER BY id) AS ids
+        FROM users WHERE phone IS NOT NULL
+        GROUP BY phone HAVING count(*) > 1
+        ORDER BY min(id) LIMIT 50
+    ) d;
+    IF duplicates IS NOT NULL THEN
+        RAISE EXCEPTION 'users_phone_key: users share a phone number (ids %)', duplicates
+            USING HINT = 'Merge the users of each group or change their phone numbers, then run the migration again.';
+    END IF;
+END;
+$$;
+
+DROP INDEX IF EXISTS users_phone_key;
+CREATE UNIQUE INDEX IF NOT EXISTS users_phone_key ON users (phone);
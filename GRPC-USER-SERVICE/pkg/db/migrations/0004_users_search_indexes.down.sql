@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS users_married_idx;
+DROP INDEX IF EXISTS users_city_trgm_idx;
@@ -0,0 +1,5 @@
+-- SearchCity matches with ILIKE '%city%', which only a trigram index serves.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS users_city_trgm_idx ON users USING gin (city gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS users_married_idx ON users (married);
@@ -0,0 +1,3 @@
+-- Optimistic concurrency: every write increments version and updates and
+-- deletes only apply to the version the caller read.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 1;
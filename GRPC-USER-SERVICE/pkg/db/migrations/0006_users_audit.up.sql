@@ -0,0 +1,7 @@
+-- Who created and last changed each user, and when. Rows that predate the
+-- columns get the migration time and an empty actor.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS created_at timestamptz NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS updated_at timestamptz NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS created_by text NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS updated_by text NOT NULL DEFAULT '';
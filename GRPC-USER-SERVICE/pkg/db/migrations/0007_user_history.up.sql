@@ -0,0 +1,17 @@
+-- Every write of a user, with the row before and after it. Rows are written
+-- by the same statement as the change they record.
+CREATE TABLE IF NOT EXISTS user_history (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    operation text NOT NULL CHECK (operation IN ('snapshot', 'insert', 'update', 'delete')),
+    old_values jsonb,
+    new_values jsonb,
+    actor text NOT NULL,
+    changed_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS user_history_user_id_changed_at_idx ON user_history (user_id, changed_at, id);
+
+-- History starts now: existing users are recorded as they are today.
+INSERT INTO user_history (user_id, operation, new_values, actor)
+SELECT id, 'snapshot', to_jsonb(users), 'system' FROM users;
@@ -0,0 +1,27 @@
+-- Append-only record of every UserService call. Each entry carries the hash
+-- of its predecessor and its own hash over both, so changing, inserting or
+-- removing an entry breaks the chain from that point on.
+CREATE TABLE IF NOT EXISTS audit_log (
+    seq bigint PRIMARY KEY,
+    recorded_at timestamptz NOT NULL,
+    principal text NOT NULL,
+    method text NOT NULL,
+    target_ids bigint[] NOT NULL DEFAULT '{}',
+    code text NOT NULL,
+    request_id text NOT NULL,
+    prev_hash bytea NOT NULL,
+    hash bytea NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_principal_idx ON audit_log (principal, seq);
+CREATE INDEX IF NOT EXISTS audit_log_target_ids_idx ON audit_log USING gin (target_ids);
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS user_history_notify ON user_history;
+DROP FUNCTION IF EXISTS user_history_notify();
+DROP INDEX IF EXISTS user_history_txid_id_idx;
+ALTER TABLE user_history DROP COLUMN IF EXISTS txid;
@@ -0,0 +1,21 @@
+-- Position of each change in the change feed. A change becomes visible to
+-- readers only when its transaction commits, so the feed is ordered by
+-- transaction id and read up to the oldest transaction still running; see
+-- GetChanges. Existing rows take the id of this migration.
+ALTER TABLE user_history ADD COLUMN IF NOT EXISTS txid xid8 NOT NULL DEFAULT pg_current_xact_id();
+
+CREATE INDEX IF NOT EXISTS user_history_txid_id_idx ON user_history (txid, id);
+
+-- Wake up watchers when changes commit. Notifications are only a hint:
+-- watchers also poll, so a lost notification delays a change but never
+-- drops it.
+CREATE OR REPLACE FUNCTION user_history_notify() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('user_changes', '');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS user_history_notify ON user_history;
+CREATE TRIGGER user_history_notify AFTER INSERT ON user_history
+    FOR EACH STATEMENT EXECUTE FUNCTION user_history_notify();
@@ -0,0 +1,24 @@
+-- User lifecycle events waiting to be published, written by the same
+-- statement as the change they announce. The relay claims due events by
+-- pushing next_attempt_at past a lease, so an event whose relay dies is
+-- claimed again once the lease runs out.
+CREATE TABLE IF NOT EXISTS outbox (
+    id bigserial PRIMARY KEY,
+    event_type text NOT NULL CHECK (event_type IN ('UserCreated', 'UserUpdated', 'UserDeleted')),
+    user_id bigint NOT NULL,
+    data jsonb NOT NULL,
+    actor text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL DEFAULT now(),
+    last_error text NOT NULL DEFAULT '',
+    published_at timestamptz,
+    dead_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (user_id, id)
+    WHERE published_at IS NULL AND dead_at IS NULL;
+CREATE INDEX IF NOT EXISTS outbox_published_at_idx ON outbox (published_at)
+    WHERE published_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS outbox_dead_at_idx ON outbox (dead_at)
+    WHERE dead_at IS NOT NULL;
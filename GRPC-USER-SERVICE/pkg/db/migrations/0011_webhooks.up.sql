@@ -0,0 +1,37 @@
+-- Endpoints told about user lifecycle events. An empty event_types list
+-- subscribes to every type. secret is the HMAC key deliveries are signed
+-- with, so it has to be kept as is.
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id bigserial PRIMARY KEY,
+    url text NOT NULL,
+    event_types text[] NOT NULL DEFAULT '{}',
+    secret text NOT NULL,
+    created_by text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+-- One delivery per event and subscription, kept after it settled as the
+-- delivery log of the subscription. Claims work like those of the outbox.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id bigserial PRIMARY KEY,
+    subscription_id bigint NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+    event_id bigint NOT NULL,
+    event_type text NOT NULL,
+    user_id bigint NOT NULL,
+    payload jsonb NOT NULL,
+    status text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'failed')),
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL DEFAULT now(),
+    last_attempt_at timestamptz,
+    response_code integer NOT NULL DEFAULT 0,
+    last_error text NOT NULL DEFAULT '',
+    created_at timestamptz NOT NULL DEFAULT now(),
+    delivered_at timestamptz,
+    UNIQUE (subscription_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_subscription_id_idx ON webhook_deliveries (subscription_id, id);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_pending_idx ON webhook_deliveries (subscription_id, user_id, id)
+    WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_settled_idx ON webhook_deliveries (coalesce(delivered_at, last_attempt_at))
+    WHERE status <> 'pending';
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS users_updated_at_idx;
+DROP INDEX IF EXISTS users_created_at_idx;
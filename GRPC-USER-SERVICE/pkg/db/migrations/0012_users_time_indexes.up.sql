@@ -0,0 +1,4 @@
+-- SearchUsers filters and orders by created_at and updated_at in SQL, ties
+-- broken by id.
+CREATE INDEX IF NOT EXISTS users_created_at_idx ON users (created_at, id);
+CREATE INDEX IF NOT EXISTS users_updated_at_idx ON users (updated_at, id);
@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+	"grpc-user-service/pkg/phone"
+	"log/slog"
+
+	"gorm.io/gorm"
+)
+
+// NormalizePhones converts phone numbers stored before the service kept them
+// in E.164 form. Numbers without a leading + are read as national numbers of
+// region; rows that still cannot be parsed are logged and left unchanged so
+// they can be fixed by hand. It returns the number of rows rewritten and is
+// safe to run on every start.
+func NormalizePhones(ctx context.Context, db *gorm.DB, region string) (int, error) {
+	var rows []struct {
+		ID    int64
+		Phone string
+	}
+	if err := db.WithContext(ctx).Raw(`SELECT id, phone FROM users WHERE phone NOT LIKE '+%'`).Scan(&rows).Error; err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	converted := 0
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, row := range rows {
+			number, err := phone.Normalize(row.Phone, region)
+			if err != nil {
+				slog.WarnContext(ctx, "cannot normalize phone number", "user_id", row.ID, "error", err)
+				continue
+			}
+			if err := tx.Exec(`UPDATE users SET phone=$1 WHERE id=$2`, number, row.ID).Error; err != nil {
+				return err
+			}
+			converted++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return converted, nil
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func Test_NormalizePhones(t *testing.T) {
+	selectQuery := regexp.QuoteMeta(`SELECT id, phone FROM users WHERE phone NOT LIKE '+%'`)
+	updateQuery := regexp.QuoteMeta(`UPDATE users SET phone=$1 WHERE id=$2`)
+
+	tests := []struct {
+		name    string
+		stub    func(mockSQL sqlmock.Sqlmock)
+		want    int
+		wantErr error
+	}{
+		{
+			name: "converts national numbers and skips invalid ones",
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				mockSQL.ExpectQuery(selectQuery).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "phone"}).
+						AddRow(1, "9087678564").
+						AddRow(2, "123").
+						AddRow(3, "0 90876 78565"))
+				mockSQL.ExpectBegin()
+				mockSQL.ExpectExec(updateQuery).WithArgs("+919087678564", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockSQL.ExpectExec(updateQuery).WithArgs("+919087678565", 3).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockSQL.ExpectCommit()
+			},
+			want: 2,
+		},
+		{
+			name: "nothing to convert",
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				mockSQL.ExpectQuery(selectQuery).WillReturnRows(sqlmock.NewRows([]string{"id", "phone"}))
+			},
+			want: 0,
+		},
+		{
+			name: "update error rolls back",
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				mockSQL.ExpectQuery(selectQuery).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "phone"}).AddRow(1, "9087678564"))
+				mockSQL.ExpectBegin()
+				mockSQL.ExpectExec(updateQuery).WithArgs("+919087678564", 1).WillReturnError(errors.New("error"))
+				mockSQL.ExpectRollback()
+			},
+			want:    0,
+			wantErr: errors.New("error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, _ := sqlmock.New()
+			defer mockDB.Close()
+			gormDB, _ := gorm.Open(postgres.New(postgres.Config{
+				Conn: mockDB,
+			}), &gorm.Config{})
+			tt.stub(mockSQL)
+
+			converted, err := NormalizePhones(context.Background(), gormDB, "IN")
+
+			assert.Equal(t, tt.want, converted)
+			assert.Equal(t, tt.wantErr, err)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
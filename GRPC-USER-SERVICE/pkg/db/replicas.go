@@ -0,0 +1,232 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"grpc-user-service/pkg/metrics"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+)
+
+const replicasPluginName = "replicas"
+
+// lagQuery returns how far a replica is behind its primary. A replica that
+// has replayed everything it received is not lagging even if the last
+// replayed transaction is old, and a server that is not a standby reports no
+// lag at all.
+const lagQuery = `SELECT CASE
+	WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+END`
+
+type primaryKey struct{}
+
+// WithPrimary marks ctx so that reads made with it go to the primary, for
+// instance to read a write that replicas may not have applied yet.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// PrimaryRequested reports whether ctx was marked by WithPrimary.
+func PrimaryRequested(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
+
+type replica struct {
+	name    string
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// Replicas is a gorm plugin sending reads made outside a transaction to read
+// replicas. Replicas are checked every interval and only those answering with
+// a replication lag of at most maxLag receive reads; when none qualifies, or
+// the context asks for the primary, reads stay on the primary. Writes always
+// go to the primary.
+type Replicas struct {
+	replicas []*replica
+	maxLag   time.Duration
+	interval time.Duration
+	next     atomic.Uint64
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewReplicas opens a connection pool per replica DSN. configure is applied
+// to every pool.
+func NewReplicas(dsns []string, maxLag, interval time.Duration, configure func(*sql.DB)) (*Replicas, error) {
+	r := &Replicas{maxLag: maxLag, interval: interval, done: make(chan struct{})}
+	for i, dsn := range dsns {
+		sqlDB, err := sql.Open("pgx", dsn)
+		if err != nil {
+			r.closeDBs()
+			return nil, err
+		}
+		configure(sqlDB)
+		r.replicas = append(r.replicas, &replica{name: replicaName(dsn, i), db: sqlDB})
+	}
+	return r, nil
+}
+
+func replicaName(dsn string, i int) string {
+	if cfg, err := pgconn.ParseConfig(dsn); err == nil {
+		return fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+	}
+	return fmt.Sprintf("replica-%d", i)
+}
+
+func (r *Replicas) Name() string {
+	return replicasPluginName
+}
+
+// Initialize checks the replicas once, starts the periodic checks and
+// installs the routing callbacks.
+func (r *Replicas) Initialize(db *gorm.DB) error {
+	r.check()
+	r.wg.Add(1)
+	go r.run()
+	return errors.Join(
+		db.Callback().Query().Before("gorm:query").Register("replicas:route_query", r.route),
+		db.Callback().Row().Before("gorm:row").Register("replicas:route_row", r.route),
+	)
+}
+
+func (r *Replicas) route(db *gorm.DB) {
+	if _, inTx := db.Statement.ConnPool.(gorm.TxCommitter); inTx {
+		return
+	}
+	if PrimaryRequested(db.Statement.Context) {
+		return
+	}
+	// Locking reads take row locks, which only the primary can. Queries
+	// built by GORM carry the lock as a clause, their SQL is only built
+	// after this callback.
+	if _, locking := db.Statement.Clauses["FOR"]; locking {
+		return
+	}
+	// Raw statements run through the row callback too, only plain SELECTs
+	// may go to a replica.
+	if db.Statement.SQL.Len() > 0 && !isReadOnlySQL(db.Statement.SQL.String()) {
+		return
+	}
+	if pool := r.pick(); pool != nil {
+		db.Statement.ConnPool = pool
+	}
+}
+
+// ForRead returns gormDB bound to a healthy replica, for read-only
+// transactions, which the routing callbacks leave on the primary. It returns
+// gormDB itself when no replica qualifies or ctx asks for the primary.
+func ForRead(ctx context.Context, gormDB *gorm.DB) *gorm.DB {
+	replicas, ok := gormDB.Config.Plugins[replicasPluginName].(*Replicas)
+	if !ok || PrimaryRequested(ctx) {
+		return gormDB
+	}
+	pool := replicas.pick()
+	if pool == nil {
+		return gormDB
+	}
+	tx := gormDB.Session(&gorm.Session{})
+	tx.Statement.ConnPool = pool
+	return tx
+}
+
+// lockingClause matches the row locking clauses of a SELECT.
+var lockingClause = regexp.MustCompile(`(?i)\bfor\s+(update|no\s+key\s+update|share|key\s+share)\b`)
+
+func isReadOnlySQL(query string) bool {
+	query = strings.TrimSpace(query)
+	return len(query) >= 6 && strings.EqualFold(query[:6], "select") && !lockingClause.MatchString(query)
+}
+
+// pick returns the next healthy replica, or nil when reads should stay on
+// the primary.
+func (r *Replicas) pick() *sql.DB {
+	n := len(r.replicas)
+	start := int(r.next.Add(1))
+	for i := 0; i < n; i++ {
+		if rep := r.replicas[(start+i)%n]; rep.healthy.Load() {
+			return rep.db
+		}
+	}
+	return nil
+}
+
+func (r *Replicas) run() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			r.check()
+		}
+	}
+}
+
+func (r *Replicas) check() {
+	for _, rep := range r.replicas {
+		lag, err := r.lag(rep)
+		healthy := err == nil && lag <= r.maxLag
+		if was := rep.healthy.Swap(healthy); was != healthy {
+			level := slog.LevelInfo
+			if !healthy {
+				level = slog.LevelWarn
+			}
+			slog.Log(context.Background(), level, "replica health changed", "replica", rep.name, "healthy", healthy, "lag", lag, "error", err)
+		}
+		metrics.ObserveReplica(rep.name, lag, healthy)
+	}
+}
+
+func (r *Replicas) lag(rep *replica) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.interval)
+	defer cancel()
+	var seconds float64
+	if err := rep.db.QueryRowContext(ctx, lagQuery).Scan(&seconds); err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// Close stops the checks and closes the replica pools.
+func (r *Replicas) Close() error {
+	close(r.done)
+	r.wg.Wait()
+	return r.closeDBs()
+}
+
+func (r *Replicas) closeDBs() error {
+	var errs []error
+	for _, rep := range r.replicas {
+		errs = append(errs, rep.db.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes the database opened by Open together with its replicas.
+func Close(db *gorm.DB) error {
+	var errs []error
+	if replicas, ok := db.Config.Plugins[replicasPluginName].(*Replicas); ok {
+		errs = append(errs, replicas.Close())
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	errs = append(errs, sqlDB.Close())
+	return errors.Join(errs...)
+}
@@ -0,0 +1,232 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"grpc-user-service/pkg/config"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func Test_ReplicaRouting(t *testing.T) {
+	selectQuery := regexp.QuoteMeta(`SELECT * FROM "users"`)
+	lagCheck := regexp.QuoteMeta("SELECT CASE")
+	rows := func() *sqlmock.Rows { return sqlmock.NewRows([]string{"id"}).AddRow(1) }
+
+	tests := []struct {
+		name    string
+		lag     func(replica sqlmock.Sqlmock)
+		query   func(db *gorm.DB) error
+		primary func(primary sqlmock.Sqlmock)
+		replica func(replica sqlmock.Sqlmock)
+	}{
+		{
+			name: "reads go to a healthy replica",
+			lag: func(replica sqlmock.Sqlmock) {
+				replica.ExpectQuery(lagCheck).WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(0.5))
+			},
+			query: func(db *gorm.DB) error {
+				var users []map[string]interface{}
+				return db.Table("users").Find(&users).Error
+			},
+			replica: func(replica sqlmock.Sqlmock) {
+				replica.ExpectQuery(selectQuery).WillReturnRows(rows())
+			},
+		},
+		{
+			name: "raw selects go to a healthy replica",
+			lag: func(replica sqlmock.Sqlmock) {
+				replica.ExpectQuery(lagCheck).WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(0))
+			},
+			query: func(db *gorm.DB) error {
+				var id int
+				return db.Raw(`SELECT id FROM users WHERE phone = $1`, "+919087678564").Scan(&id).Error
+			},
+			replica: func(replica sqlmock.Sqlmock) {
+				replica.ExpectQuery(regexp.QuoteMeta("SELECT id FROM users")).WillReturnRows(rows())
+			},
+		},
+		{
+			name: "raw writes stay on the primary",
+			lag: func(replica sqlmock.Sqlmock) {
+				replica.ExpectQuery(lagCheck).WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(0))
+			},
+			query: func(db *gorm.DB) error {
+				var id int
+				return db.Raw(`UPDATE users SET city = $1 RETURNING id`, "Kannur").Scan(&id).Error
+			},
+			primary: func(primary sqlmock.Sqlmock) {
+				primary.ExpectQuery(regexp.QuoteMeta("UPDATE users")).WillReturnRows(rows())
+			},
+		},
+		{
+			name: "locking reads stay on the primary",
+			lag: func(replica sqlmock.Sqlmock) {
+				replica.ExpectQuery(lagCheck).WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(0))
+			},
+			query: func(db *gorm.DB) error {
+				var users []map[string]interface{}
+				return db.Table("users").Clauses(clause.Locking{Strength: "UPDATE"}).Find(&users).Error
+			},
+			primary: func(primary sqlmock.Sqlmock) {
+				primary.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" FOR UPDATE`)).WillReturnRows(rows())
+			},
+		},
+		{
+			name: "raw locking reads stay on the primary",
+			lag: func(replica sqlmock.Sqlmock) {
+				replica.ExpectQuery(lagCheck).WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(0))
+			},
+			query: func(db *gorm.DB) error {
+				var id int
+				return db.Raw("SELECT id FROM users WHERE phone = $1\n\tFOR NO KEY UPDATE", "+919087678564").Scan(&id).Error
+			},
+			primary: func(primary sqlmock.Sqlmock) {
+				primary.ExpectQuery(regexp.QuoteMeta("SELECT id FROM users")).WillReturnRows(rows())
+			},
+		},
+		{
+			name: "lagging replica falls back to the primary",
+			lag: func(replica sqlmock.Sqlmock) {
+				replica.ExpectQuery(lagCheck).WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(30))
+			},
+			query: func(db *gorm.DB) error {
+				var users []map[string]interface{}
+				return db.Table("users").Find(&users).Error
+			},
+			primary: func(primary sqlmock.Sqlmock) {
+				primary.ExpectQuery(selectQuery).WillReturnRows(rows())
+			},
+		},
+		{
+			name: "unreachable replica falls back to the primary",
+			lag: func(replica sqlmock.Sqlmock) {
+				replica.ExpectQuery(lagCheck).WillReturnError(errors.New("connection refused"))
+			},
+			query: func(db *gorm.DB) error {
+				var users []map[string]interface{}
+				return db.Table("users").Find(&users).Error
+			},
+			primary: func(primary sqlmock.Sqlmock) {
+				primary.ExpectQuery(selectQuery).WillReturnRows(rows())
+			},
+		},
+		{
+			name: "read your writes stays on the primary",
+			lag: func(replica sqlmock.Sqlmock) {
+				replica.ExpectQuery(lagCheck).WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(0))
+			},
+			query: func(db *gorm.DB) error {
+				var users []map[string]interface{}
+				return db.WithContext(WithPrimary(context.Background())).Table("users").Find(&users).Error
+			},
+			primary: func(primary sqlmock.Sqlmock) {
+				primary.ExpectQuery(selectQuery).WillReturnRows(rows())
+			},
+		},
+		{
+			name: "reads inside a transaction stay on the primary",
+			lag: func(replica sqlmock.Sqlmock) {
+				replica.ExpectQuery(lagCheck).WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(0))
+			},
+			query: func(db *gorm.DB) error {
+				return db.Transaction(func(tx *gorm.DB) error {
+					var users []map[string]interface{}
+					return tx.Table("users").Find(&users).Error
+				})
+			},
+			primary: func(primary sqlmock.Sqlmock) {
+				primary.ExpectBegin()
+				primary.ExpectQuery(selectQuery).WillReturnRows(rows())
+				primary.ExpectCommit()
+			},
+		},
+		{
+			name: "read-only transactions begin on a replica",
+			lag: func(replica sqlmock.Sqlmock) {
+				replica.ExpectQuery(lagCheck).WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(0))
+			},
+			query: func(db *gorm.DB) error {
+				return ForRead(context.Background(), db).Transaction(func(tx *gorm.DB) error {
+					var users []map[string]interface{}
+					return tx.Table("users").Find(&users).Error
+				})
+			},
+			replica: func(replica sqlmock.Sqlmock) {
+				replica.ExpectBegin()
+				replica.ExpectQuery(selectQuery).WillReturnRows(rows())
+				replica.ExpectCommit()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primaryDB, primarySQL, _ := sqlmock.New()
+			defer primaryDB.Close()
+			replicaDB, replicaSQL, _ := sqlmock.New()
+			gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: primaryDB}), &gorm.Config{})
+			require.NoError(t, err)
+
+			tt.lag(replicaSQL)
+			if tt.primary != nil {
+				tt.primary(primarySQL)
+			}
+			if tt.replica != nil {
+				tt.replica(replicaSQL)
+			}
+			replicaSQL.ExpectClose()
+
+			replicas := &Replicas{
+				replicas: []*replica{{name: "replica", db: replicaDB}},
+				maxLag:   10 * time.Second,
+				interval: time.Hour,
+				done:     make(chan struct{}),
+			}
+			require.NoError(t, gormDB.Use(replicas))
+
+			assert.NoError(t, tt.query(gormDB))
+			assert.NoError(t, replicas.Close())
+			assert.NoError(t, primarySQL.ExpectationsWereMet())
+			assert.NoError(t, replicaSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_ReplicaDSNs(t *testing.T) {
+	cfg := config.Config{DBReplicaDSNs: "host=replica-1 dbname=users, postgres://svc@replica-2/users ,"}
+	assert.Equal(t, []string{"host=replica-1 dbname=users", "postgres://svc@replica-2/users"}, ReplicaDSNs(cfg))
+	assert.Empty(t, ReplicaDSNs(config.Config{}))
+}
+
+func Test_isReadOnlySQL(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{query: "SELECT id FROM users", want: true},
+		{query: "  select id from users", want: true},
+		{query: "SELECT id FROM users WHERE city = 'forupdate'", want: true},
+		{query: "SELECT id FROM users FOR UPDATE", want: false},
+		{query: "SELECT id FROM users FOR UPDATE SKIP LOCKED", want: false},
+		{query: "select id from users for no key update", want: false},
+		{query: "SELECT id FROM users FOR SHARE", want: false},
+		{query: "SELECT id FROM users FOR KEY SHARE", want: false},
+		{query: "SELECT id FROM users\nFOR\n\tUPDATE", want: false},
+		{query: "UPDATE users SET city = 'Kannur'", want: false},
+		{query: "WITH moved AS (DELETE FROM users RETURNING *) SELECT * FROM moved", want: false},
+		{query: "sel", want: false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, isReadOnlySQL(tt.query), tt.query)
+	}
+}
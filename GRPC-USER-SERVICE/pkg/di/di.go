@@ -3,10 +3,16 @@ package di
 import (
 	server "grpc-user-service/pkg/api"
 	"grpc-user-service/pkg/api/service"
+	"grpc-user-service/pkg/audit"
+	"grpc-user-service/pkg/cache"
 	"grpc-user-service/pkg/config"
 	"grpc-user-service/pkg/db"
+	"grpc-user-service/pkg/metrics"
+	"grpc-user-service/pkg/outbox"
 	"grpc-user-service/pkg/repository"
 	"grpc-user-service/pkg/usecase"
+	"grpc-user-service/pkg/webhook"
+	"strings"
 )
 
 func InitializeAPI(cfg config.Config) (*server.Server, error) {
@@ -14,13 +20,93 @@ func InitializeAPI(cfg config.Config) (*server.Server, error) {
 	if err != nil {
 		return nil, err
 	}
-	userRepository := repository.NewUserRepository(gormDB)
-	userUseCase := usecase.NewUserUseCase(userRepository)
+	sqlDB, err := gormDB.DB()
+	if err != nil {
+		return nil, err
+	}
+	if err := metrics.RegisterDBStats(sqlDB, cfg.DBName); err != nil {
+		return nil, err
+	}
+	userRepository := repository.NewUserRepository(gormDB, cfg.DBQueryTimeout)
+	if cfg.CacheSize > 0 {
+		userRepository = repository.NewCachedUserRepository(userRepository, cache.NewLRU(cfg.CacheSize), cfg.CacheTTL)
+	}
+	changes := db.NewListener(sqlDB, repository.UserChangesChannel, cfg.WatchPollInterval)
+	userUseCase := usecase.NewUserUseCase(userRepository, changes, cfg.DefaultPhoneRegion)
+
+	auditRepository := repository.NewAuditRepository(gormDB, cfg.DBQueryTimeout)
+	auditUseCase := usecase.NewAuditUseCase(auditRepository)
+	var recorder *audit.Recorder
+	if cfg.AuditEnabled {
+		recorder = audit.NewRecorder(auditRepository)
+	}
+
+	publisher, err := outbox.NewPublisher(cfg)
+	if err != nil {
+		changes.Close()
+		if recorder != nil {
+			recorder.Close()
+		}
+		return &server.Server{}, err
+	}
+	webhookRepository := repository.NewWebhookRepository(gormDB, cfg.DBQueryTimeout)
+	webhookUseCase := usecase.NewWebhookUseCase(webhookRepository)
+	var publishers []outbox.Publisher
+	if publisher != nil {
+		publishers = append(publishers, publisher)
+	}
+	var dispatcher *webhook.Dispatcher
+	if cfg.WebhooksEnabled {
+		dispatcher = webhook.NewDispatcher(webhookRepository, changes, webhook.Options{
+			BatchSize:   cfg.WebhookBatchSize,
+			Lease:       cfg.WebhookLease,
+			Timeout:     cfg.WebhookTimeout,
+			MaxAttempts: cfg.WebhookMaxAttempts,
+			MinBackoff:  cfg.WebhookMinBackoff,
+			MaxBackoff:  cfg.WebhookMaxBackoff,
+			Retention:   cfg.WebhookRetention,
+		})
+		publishers = append(publishers, webhook.NewPublisher(webhookRepository, dispatcher))
+	}
+	var relay *outbox.Relay
+	if len(publishers) > 0 {
+		relay = outbox.NewRelay(repository.NewOutboxRepository(gormDB, cfg.DBQueryTimeout), outbox.Fanout(publishers...), changes, outbox.RelayOptions{
+			BatchSize:   cfg.OutboxBatchSize,
+			Lease:       cfg.OutboxLease,
+			MaxAttempts: cfg.OutboxMaxAttempts,
+			MinBackoff:  cfg.OutboxMinBackoff,
+			MaxBackoff:  cfg.OutboxMaxBackoff,
+			Retention:   cfg.OutboxRetention,
+		})
+	}
 
 	ServiceServer := service.NewAuthServer(userUseCase)
-	grpcServer, err := server.NewGRPCServer(cfg, ServiceServer)
+	AuditServer := service.NewAuditServer(auditUseCase, splitList(cfg.AuditAdmins))
+	WebhookServer := service.NewWebhookServer(webhookUseCase, splitList(cfg.WebhookAdmins))
+	grpcServer, err := server.NewGRPCServer(cfg, ServiceServer, AuditServer, WebhookServer, recorder, changes, relay, dispatcher, gormDB)
 	if err != nil {
+		changes.Close()
+		if recorder != nil {
+			recorder.Close()
+		}
+		if relay != nil {
+			relay.Close()
+		}
+		if dispatcher != nil {
+			dispatcher.Close()
+		}
 		return &server.Server{}, err
 	}
 	return grpcServer, nil
 }
+
+// splitList splits a comma separated list, dropping empty items.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
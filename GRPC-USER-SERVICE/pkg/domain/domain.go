@@ -1,10 +1,18 @@
 package domain
 
+import "time"
+
 type User struct {
 	ID      int64   `json:"id" gorm:"uniquekey; not null"`
 	Fname   string  `json:"fname" gorm:"validate:required"`
 	City    string  `json:"city" gorm:"validate:required"`
-	Phone   int64   `json:"phone" gorm:"validate:required"`
+	Phone   string  `json:"phone" gorm:"validate:required"`
 	Height  float32 `json:"height" gorm:"validate:required"`
 	Married bool    `json:"married" gorm:"validate:required"`
+	Version int64   `json:"version" gorm:"not null;default:1"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"not null;default:now()"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"not null;default:now()"`
+	CreatedBy string    `json:"created_by" gorm:"not null;default:''"`
+	UpdatedBy string    `json:"updated_by" gorm:"not null;default:''"`
 }
@@ -0,0 +1,43 @@
+package domain
+
+import "errors"
+
+// ErrUserNotFound is returned when a requested user does not exist.
+var ErrUserNotFound = errors.New("user doesn't exist")
+
+// ErrUserAlreadyExists is returned when a user with the same phone number is
+// already stored. Uniqueness is enforced by the users_phone_key index.
+var ErrUserAlreadyExists = errors.New("user with this phone is already exists")
+
+// ErrVersionMismatch is returned when an update or delete names a version of
+// the user other than the current one, meaning someone else wrote it since
+// the caller read it.
+var ErrVersionMismatch = errors.New("user was modified by another request")
+
+// ErrInvalidPageToken is returned for a page token that was not produced by
+// a previous page of the same listing.
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// ErrInvalidResumeToken is returned for a resume token that was not produced
+// by the change feed.
+var ErrInvalidResumeToken = errors.New("invalid resume token")
+
+// ErrShuttingDown is returned by long running calls that are cut short
+// because the service is stopping. They can be resumed on another instance.
+var ErrShuttingDown = errors.New("service is shutting down")
+
+// ErrSubscriptionNotFound is returned when a webhook subscription does not
+// exist.
+var ErrSubscriptionNotFound = errors.New("webhook subscription doesn't exist")
+
+// ErrDeliveryNotFound is returned when a webhook delivery does not exist or
+// belongs to another subscription.
+var ErrDeliveryNotFound = errors.New("webhook delivery doesn't exist")
+
+// ErrInvalidWebhookURL is returned for a webhook URL that is not an absolute
+// http or https URL of a public host.
+var ErrInvalidWebhookURL = errors.New("webhook url must be an absolute http or https url of a public host")
+
+// ErrInvalidEventType is returned for an event type that is not written to
+// the outbox.
+var ErrInvalidEventType = errors.New("unknown event type")
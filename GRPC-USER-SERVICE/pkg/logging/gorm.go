@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// GormLogger sends GORM's logs to slog. Queries are logged with their
+// placeholders only, so bound values such as phone numbers never reach the
+// logs. Failed queries are logged as errors, queries slower than
+// SlowThreshold as warnings and everything else at debug level.
+type GormLogger struct {
+	Logger        *slog.Logger
+	SlowThreshold time.Duration
+}
+
+func NewGormLogger(logger *slog.Logger) *GormLogger {
+	return &GormLogger{Logger: logger, SlowThreshold: 200 * time.Millisecond}
+}
+
+func (l *GormLogger) LogMode(gormlogger.LogLevel) gormlogger.Interface {
+	return l
+}
+
+func (l *GormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	l.Logger.InfoContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (l *GormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	l.Logger.WarnContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (l *GormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	l.Logger.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+	level := slog.LevelDebug
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		level = slog.LevelError
+	case elapsed > l.SlowThreshold:
+		level = slog.LevelWarn
+	}
+	if !l.Logger.Enabled(ctx, level) {
+		return
+	}
+	sql, rows := fc()
+	attrs := []slog.Attr{
+		slog.String("sql", sql),
+		slog.Int64("rows", rows),
+		slog.Duration("duration", elapsed),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	l.Logger.LogAttrs(ctx, level, "sql query", attrs...)
+}
+
+// ParamsFilter drops the bound values so that Trace receives the SQL with
+// its placeholders instead of the interpolated values.
+func (l *GormLogger) ParamsFilter(_ context.Context, sql string, _ ...interface{}) (string, []interface{}) {
+	return sql, nil
+}
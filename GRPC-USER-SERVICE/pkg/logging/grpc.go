@@ -0,0 +1,131 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// RequestIDHeader is the metadata key used to propagate request ids.
+const RequestIDHeader = "x-request-id"
+
+// UnaryServerInterceptor takes the request id from the incoming metadata (or
+// generates one), stores it in the context, echoes it back in the response
+// header and writes one access log line per RPC. Request payloads are logged
+// at debug level only, after passing through the redaction policy.
+func UnaryServerInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx = incomingRequestID(ctx)
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		attrs := accessAttrs(info.FullMethod, start, err)
+		if m, ok := req.(proto.Message); ok && logger.Enabled(ctx, slog.LevelDebug) {
+			attrs = append(attrs, Message("request", m))
+		}
+		logger.LogAttrs(ctx, accessLevel(err), "grpc request", attrs...)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of UnaryServerInterceptor.
+func StreamServerInterceptor(logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := incomingRequestID(ss.Context())
+		start := time.Now()
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		logger.LogAttrs(ctx, accessLevel(err), "grpc stream", accessAttrs(info.FullMethod, start, err)...)
+		return err
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func incomingRequestID(ctx context.Context) context.Context {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestIDHeader); len(values) > 0 && ValidRequestID(values[0]) {
+			id = values[0]
+		}
+	}
+	if id == "" {
+		id = NewRequestID()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, id))
+	return WithRequestID(ctx, id)
+}
+
+func accessAttrs(method string, start time.Time, err error) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("method", method),
+		slog.String("code", status.Code(err).String()),
+		slog.Duration("duration", time.Since(start)),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", status.Convert(err).Message()))
+	}
+	return attrs
+}
+
+func accessLevel(err error) slog.Level {
+	switch status.Code(err) {
+	case codes.OK:
+		return slog.LevelInfo
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+		return slog.LevelError
+	default:
+		return slog.LevelWarn
+	}
+}
+
+// Message renders a protobuf message as a log group so that the redaction
+// policy also applies to its fields.
+func Message(key string, m proto.Message) slog.Attr {
+	return slog.Attr{Key: key, Value: slog.GroupValue(messageAttrs(m.ProtoReflect())...)}
+}
+
+func messageAttrs(m protoreflect.Message) []slog.Attr {
+	var attrs []slog.Attr
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		attrs = append(attrs, fieldAttr(string(fd.Name()), fd, v))
+		return true
+	})
+	return attrs
+}
+
+func fieldAttr(key string, fd protoreflect.FieldDescriptor, v protoreflect.Value) slog.Attr {
+	switch {
+	case fd.IsList():
+		list := v.List()
+		var items []slog.Attr
+		for i := 0; i < list.Len(); i++ {
+			items = append(items, valueAttr(strconv.Itoa(i), fd, list.Get(i)))
+		}
+		return slog.Attr{Key: key, Value: slog.GroupValue(items...)}
+	case fd.IsMap():
+		return slog.Int(key, v.Map().Len())
+	default:
+		return valueAttr(key, fd, v)
+	}
+}
+
+func valueAttr(key string, fd protoreflect.FieldDescriptor, v protoreflect.Value) slog.Attr {
+	if fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind {
+		return slog.Attr{Key: key, Value: slog.GroupValue(messageAttrs(v.Message())...)}
+	}
+	return slog.Any(key, v.Interface())
+}
@@ -0,0 +1,121 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// record logs one record through a handler using policy and returns it
+// decoded.
+func record(t *testing.T, policy string, log func(*slog.Logger)) map[string]interface{} {
+	var buf bytes.Buffer
+	handler := slog.NewJSONHandler(&buf, &slog.HandlerOptions{ReplaceAttr: ParsePolicy(policy).ReplaceAttr})
+	log(slog.New(contextHandler{handler}))
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	return got
+}
+
+func Test_Redaction(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		log    func(*slog.Logger)
+		want   map[string]interface{}
+	}{
+		{
+			name:   "top level",
+			policy: DefaultPolicy,
+			log: func(l *slog.Logger) {
+				l.Info("msg", "phone", "+919876543210", "fname", "Akhil", "city", "Kannur")
+			},
+			want: map[string]interface{}{"phone": "***********10", "fname": "[REDACTED]", "city": "Kannur"},
+		},
+		{
+			name:   "group",
+			policy: DefaultPolicy,
+			log: func(l *slog.Logger) {
+				l.Info("msg", slog.Group("user", "phone", "+919876543210", "name", "Akhil", "id", 7))
+			},
+			want: map[string]interface{}{"user": map[string]interface{}{"phone": "***********10", "name": "[REDACTED]", "id": float64(7)}},
+		},
+		{
+			name:   "nested groups",
+			policy: DefaultPolicy,
+			log: func(l *slog.Logger) {
+				l.Info("msg", slog.Group("request", slog.Group("user", "Phone", "+919876543210", "FName", "Akhil")))
+			},
+			want: map[string]interface{}{"request": map[string]interface{}{"user": map[string]interface{}{"Phone": "***********10", "FName": "[REDACTED]"}}},
+		},
+		{
+			name:   "WithGroup and With",
+			policy: DefaultPolicy,
+			log: func(l *slog.Logger) {
+				l.WithGroup("request").With("fname", "Akhil").Info("msg", "phone", "+919876543210")
+			},
+			want: map[string]interface{}{"request": map[string]interface{}{"fname": "[REDACTED]", "phone": "***********10"}},
+		},
+		{
+			name:   "group named like a key",
+			policy: "user",
+			log: func(l *slog.Logger) {
+				l.Info("msg", slog.Group("user", "phone", "+919876543210"))
+			},
+			want: map[string]interface{}{"user": map[string]interface{}{"phone": "+919876543210"}},
+		},
+		{
+			name:   "drop and keep",
+			policy: "phone=drop,name=keep",
+			log: func(l *slog.Logger) {
+				l.Info("msg", "phone", "+919876543210", "name", "Akhil")
+			},
+			want: map[string]interface{}{"name": "Akhil"},
+		},
+		{
+			name:   "short value",
+			policy: "phone=mask",
+			log: func(l *slog.Logger) {
+				l.Info("msg", "phone", "12")
+			},
+			want: map[string]interface{}{"phone": "**"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := record(t, tt.policy, tt.log)
+			for _, key := range []string{"time", "level", "msg"} {
+				delete(got, key)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_RequestIDAttribute(t *testing.T) {
+	got := record(t, DefaultPolicy, func(l *slog.Logger) {
+		l.InfoContext(WithRequestID(context.Background(), "abc"), "msg")
+	})
+	assert.Equal(t, "abc", got[RequestIDKey])
+}
+
+// Test_SameAsGatewayCopy fails when logging.go no longer matches the copy in
+// the gateway module. It is skipped where the gateway sources are absent,
+// such as in the Docker build.
+func Test_SameAsGatewayCopy(t *testing.T) {
+	gateway, err := os.ReadFile("../../../GRPC-API-GATEWAY/pkg/logging/logging.go")
+	if os.IsNotExist(err) {
+		t.Skip("gateway sources not found")
+	}
+	require.NoError(t, err)
+	own, err := os.ReadFile("logging.go")
+	require.NoError(t, err)
+	assert.Equal(t, string(own), string(gateway), "change pkg/logging/logging.go in both modules")
+}
@@ -0,0 +1,155 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Labels are limited to the gRPC method, the status code, the repository
+// method, the configured replicas, the cache lookup and the audit, outbox and
+// webhook results, all of which come from a fixed set, so cardinality stays
+// bounded.
+var (
+	grpcHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_server_handled_total",
+		Help: "Total number of RPCs completed on the server, regardless of success or failure.",
+	}, []string{"grpc_method", "grpc_code"})
+
+	grpcHandlingSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "grpc_server_handling_seconds",
+		Help:    "Histogram of response latency of RPCs handled by the server.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"grpc_method"})
+
+	dbQuerySeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Histogram of database query latency per repository method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"})
+
+	userCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "user_cache_requests_total",
+		Help: "User cache lookups by lookup kind (id, phone) and result (hit, miss).",
+	}, []string{"lookup", "result"})
+
+	dbReplicaLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "db_replica_lag_seconds",
+		Help: "Replication lag of each read replica as of its last health check.",
+	}, []string{"replica"})
+
+	dbReplicaHealthy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "db_replica_healthy",
+		Help: "Whether each read replica currently receives reads (1) or not (0).",
+	}, []string{"replica"})
+
+	auditRecords = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "audit_records_total",
+		Help: "Calls recorded in the audit log by result (ok, failed).",
+	}, []string{"result"})
+
+	outboxEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "outbox_events_total",
+		Help: "Attempts to publish outbox events by result (published, retried, dead, expired).",
+	}, []string{"result"})
+
+	webhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_deliveries_total",
+		Help: "Attempts to deliver webhooks by result (delivered, retried, failed, expired).",
+	}, []string{"result"})
+)
+
+// UnaryServerInterceptor records the count and latency of unary RPCs.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		observeRPC(info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor records the count and latency of streaming RPCs.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		observeRPC(info.FullMethod, start, err)
+		return err
+	}
+}
+
+func observeRPC(method string, start time.Time, err error) {
+	grpcHandled.WithLabelValues(method, status.Code(err).String()).Inc()
+	grpcHandlingSeconds.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// ObserveQuery records the duration of a repository method. It is meant to be
+// deferred at the top of the method: defer metrics.ObserveQuery("GetUserByID", time.Now()).
+func ObserveQuery(method string, start time.Time) {
+	dbQuerySeconds.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// ObserveCache records a user cache lookup.
+func ObserveCache(lookup string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	userCacheRequests.WithLabelValues(lookup, result).Inc()
+}
+
+// ObserveReplica records the outcome of a replica health check.
+func ObserveReplica(replica string, lag time.Duration, healthy bool) {
+	dbReplicaLag.WithLabelValues(replica).Set(lag.Seconds())
+	value := 0.0
+	if healthy {
+		value = 1
+	}
+	dbReplicaHealthy.WithLabelValues(replica).Set(value)
+}
+
+// ObserveAudit records the outcome of writing an audit log entry.
+func ObserveAudit(err error) {
+	result := "ok"
+	if err != nil {
+		result = "failed"
+	}
+	auditRecords.WithLabelValues(result).Inc()
+}
+
+// ObserveOutbox records the result of an attempt to publish an outbox event.
+func ObserveOutbox(result string) {
+	outboxEvents.WithLabelValues(result).Inc()
+}
+
+// ObserveWebhook records the result of an attempt to deliver a webhook.
+func ObserveWebhook(result string) {
+	webhookDeliveries.WithLabelValues(result).Inc()
+}
+
+// RegisterDBStats exports the connection pool statistics of db.
+func RegisterDBStats(db *sql.DB, name string) error {
+	err := prometheus.Register(collectors.NewDBStatsCollector(db, name))
+	var already prometheus.AlreadyRegisteredError
+	if errors.As(err, &already) {
+		return nil
+	}
+	return err
+}
+
+// NewServer returns an HTTP server exposing /metrics on addr.
+func NewServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	return &http.Server{Addr: addr, Handler: mux}
+}
@@ -0,0 +1,50 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"grpc-user-service/pkg/utils/models"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type kafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+// NewKafkaPublisher publishes events to topic, keyed by user id so that the
+// events of a user land in one partition in order. Writes wait for all
+// in-sync replicas. The event id and type are sent as the event-id and
+// event-type headers.
+func NewKafkaPublisher(brokers []string, topic string) Publisher {
+	return &kafkaPublisher{writer: &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		// The relay publishes a batch at a time and waits for each event,
+		// so there is little to gain from holding writes back.
+		BatchTimeout: 10 * time.Millisecond,
+	}}
+}
+
+func (p *kafkaPublisher) Publish(ctx context.Context, event models.OutboxEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return Permanent(err)
+	}
+	return p.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(strconv.FormatInt(event.User.ID, 10)),
+		Value: data,
+		Headers: []kafka.Header{
+			{Key: "event-id", Value: []byte(strconv.FormatInt(event.ID, 10))},
+			{Key: "event-type", Value: []byte(event.Type)},
+		},
+	})
+}
+
+func (p *kafkaPublisher) Close() error {
+	return p.writer.Close()
+}
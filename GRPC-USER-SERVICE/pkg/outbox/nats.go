@@ -0,0 +1,50 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"grpc-user-service/pkg/utils/models"
+	"strconv"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+type natsPublisher struct {
+	conn    *nats.Conn
+	js      jetstream.JetStream
+	subject string
+}
+
+// NewNATSPublisher publishes events to JetStream on "<subject>.<type>", for
+// instance users.UserCreated. A stream has to capture these subjects: the
+// publisher waits for the stream's acknowledgement, and the event id is sent
+// as Nats-Msg-Id so that the stream drops duplicates within its window.
+func NewNATSPublisher(url, subject string) (Publisher, error) {
+	conn, err := nats.Connect(url, nats.Name("grpc-user-service"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, err
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &natsPublisher{conn: conn, js: js, subject: subject}, nil
+}
+
+func (p *natsPublisher) Publish(ctx context.Context, event models.OutboxEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return Permanent(err)
+	}
+	msg := nats.NewMsg(p.subject + "." + event.Type)
+	msg.Data = data
+	_, err = p.js.PublishMsg(ctx, msg, jetstream.WithMsgID(strconv.FormatInt(event.ID, 10)))
+	return err
+}
+
+func (p *natsPublisher) Close() error {
+	p.conn.Close()
+	return nil
+}
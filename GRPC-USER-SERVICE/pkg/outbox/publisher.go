@@ -0,0 +1,167 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"grpc-user-service/pkg/config"
+	"grpc-user-service/pkg/utils/models"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Publisher delivers outbox events to another system. Publish returns nil
+// only once the event has been accepted, so an event is never lost, but it
+// may be delivered again after a failure; consumers drop duplicates by the
+// event id. Publish is called concurrently, though never for two events of
+// the same user.
+type Publisher interface {
+	Publish(ctx context.Context, event models.OutboxEvent) error
+	Close() error
+}
+
+// permanentError marks a failure that retrying cannot fix.
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so that the relay dead-letters the event right away
+// instead of retrying it.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// IsPermanent reports whether err was wrapped by Permanent.
+func IsPermanent(err error) bool {
+	return errors.As(err, &permanentError{})
+}
+
+// NewPublisher returns the publisher selected by OUTBOX_PUBLISHER: "log",
+// "file" (appending to OUTBOX_FILE), "webhook" (posting to
+// OUTBOX_WEBHOOK_URL), "nats" (JetStream), "kafka" or "none", for which it
+// returns nil and events stay in the outbox.
+func NewPublisher(cfg config.Config) (Publisher, error) {
+	switch cfg.OutboxPublisher {
+	case "", "none":
+		return nil, nil
+	case "log":
+		return NewLogPublisher(slog.Default()), nil
+	case "file":
+		return NewFilePublisher(cfg.OutboxFile)
+	case "webhook":
+		return NewWebhookPublisher(cfg.OutboxWebhookURL, cfg.OutboxWebhookTimeout), nil
+	case "nats":
+		return NewNATSPublisher(cfg.OutboxNATSURL, cfg.OutboxNATSSubject)
+	case "kafka":
+		return NewKafkaPublisher(splitList(cfg.OutboxKafkaBrokers), cfg.OutboxKafkaTopic), nil
+	default:
+		return nil, fmt.Errorf("unknown outbox publisher %q", cfg.OutboxPublisher)
+	}
+}
+
+type fanout []Publisher
+
+// Fanout returns a publisher publishing every event through each of
+// publishers. An event counts as published once all of them accepted it, so
+// after a failure it is published again through all of them. It is rejected
+// for good only when all of them rejected it for good.
+func Fanout(publishers ...Publisher) Publisher {
+	if len(publishers) == 1 {
+		return publishers[0]
+	}
+	return fanout(publishers)
+}
+
+func (f fanout) Publish(ctx context.Context, event models.OutboxEvent) error {
+	var errs []error
+	permanent := true
+	for _, p := range f {
+		if err := p.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+			permanent = permanent && IsPermanent(err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	err := errors.Join(errs...)
+	if permanent {
+		return Permanent(err)
+	}
+	// Hide the permanent errors from IsPermanent.
+	return errors.New(err.Error())
+}
+
+func (f fanout) Close() error {
+	var errs []error
+	for _, p := range f {
+		errs = append(errs, p.Close())
+	}
+	return errors.Join(errs...)
+}
+
+type logPublisher struct {
+	logger *slog.Logger
+}
+
+// NewLogPublisher logs events instead of sending them anywhere, which is
+// mostly useful in development. The user is left out of the log.
+func NewLogPublisher(logger *slog.Logger) Publisher {
+	return &logPublisher{logger: logger}
+}
+
+func (p *logPublisher) Publish(ctx context.Context, event models.OutboxEvent) error {
+	p.logger.InfoContext(ctx, "user event", "event_id", event.ID, "type", event.Type, "user_id", event.User.ID, "actor", event.Actor)
+	return nil
+}
+
+func (p *logPublisher) Close() error {
+	return nil
+}
+
+type filePublisher struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFilePublisher appends events to the file at path as JSON lines. Every
+// event is synced to disk before it counts as published.
+func NewFilePublisher(path string) (Publisher, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &filePublisher{file: file}, nil
+}
+
+func (p *filePublisher) Publish(ctx context.Context, event models.OutboxEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return Permanent(err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return p.file.Sync()
+}
+
+func (p *filePublisher) Close() error {
+	return p.file.Close()
+}
+
+// splitList splits a comma separated list, dropping empty items.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
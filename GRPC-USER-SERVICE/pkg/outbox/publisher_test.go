@@ -0,0 +1,113 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"grpc-user-service/pkg/config"
+	"grpc-user-service/pkg/utils/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testEvent = models.OutboxEvent{
+	ID:         42,
+	Type:       models.OutboxUserCreated,
+	User:       models.Users{ID: 7, Fname: "Akhil", City: "Kochi", Phone: "+919087678564", Version: 1},
+	Actor:      "alice",
+	OccurredAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+	Attempts:   3,
+	LastError:  "timeout",
+}
+
+func Test_NewPublisher(t *testing.T) {
+	publisher, err := NewPublisher(config.Config{OutboxPublisher: "none"})
+	assert.NoError(t, err)
+	assert.Nil(t, publisher)
+
+	publisher, err = NewPublisher(config.Config{OutboxPublisher: "log"})
+	assert.NoError(t, err)
+	assert.NotNil(t, publisher)
+
+	_, err = NewPublisher(config.Config{OutboxPublisher: "carrier-pigeon"})
+	assert.Error(t, err)
+}
+
+func Test_FilePublisher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	publisher, err := NewFilePublisher(path)
+	require.NoError(t, err)
+
+	second := testEvent
+	second.ID, second.Type = 43, models.OutboxUserDeleted
+	assert.NoError(t, publisher.Publish(context.Background(), testEvent))
+	assert.NoError(t, publisher.Publish(context.Background(), second))
+	assert.NoError(t, publisher.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	require.Len(t, lines, 2)
+	var got models.OutboxEvent
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &got))
+	want := testEvent
+	want.Attempts, want.LastError = 0, ""
+	assert.Equal(t, want, got)
+	assert.Contains(t, lines[1], `"type":"UserDeleted"`)
+}
+
+func Test_IsPermanent(t *testing.T) {
+	err := Permanent(errors.New("rejected"))
+
+	assert.True(t, IsPermanent(err))
+	assert.True(t, IsPermanent(errors.Join(errors.New("publishing"), err)))
+	assert.False(t, IsPermanent(errors.New("unavailable")))
+	assert.EqualError(t, err, "rejected")
+}
+
+func Test_Fanout(t *testing.T) {
+	unavailable := errors.New("unavailable")
+	rejected := Permanent(errors.New("rejected"))
+
+	tests := []struct {
+		name          string
+		errs          []error
+		wantErr       bool
+		wantPermanent bool
+	}{
+		{name: "all accept", errs: []error{nil, nil}},
+		{name: "one fails", errs: []error{nil, unavailable}, wantErr: true},
+		{name: "one rejects", errs: []error{rejected, nil}, wantErr: true, wantPermanent: true},
+		{name: "one rejects, one fails", errs: []error{rejected, unavailable}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var publishers []Publisher
+			var fakes []*fakePublisher
+			for _, err := range tt.errs {
+				fake := &fakePublisher{errs: map[int64]error{testEvent.ID: err}}
+				fakes = append(fakes, fake)
+				publishers = append(publishers, fake)
+			}
+			publisher := Fanout(publishers...)
+
+			err := publisher.Publish(context.Background(), testEvent)
+
+			assert.Equal(t, tt.wantErr, err != nil, "error %v", err)
+			assert.Equal(t, tt.wantPermanent, IsPermanent(err))
+			assert.NoError(t, publisher.Close())
+			for _, fake := range fakes {
+				assert.Equal(t, []int64{testEvent.ID}, fake.published)
+				assert.True(t, fake.closed)
+			}
+		})
+	}
+}
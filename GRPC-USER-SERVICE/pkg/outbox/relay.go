@@ -0,0 +1,189 @@
+package outbox
+
+import (
+	"context"
+	"grpc-user-service/pkg/metrics"
+	interfaces "grpc-user-service/pkg/repository/interface"
+	"grpc-user-service/pkg/utils/models"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// markTimeout bounds recording the outcome of an attempt.
+	markTimeout = 10 * time.Second
+	// pruneInterval is how often published events past their retention are
+	// deleted.
+	pruneInterval = time.Hour
+)
+
+// RelayOptions tune a Relay.
+type RelayOptions struct {
+	// BatchSize bounds the events claimed at once.
+	BatchSize int
+	// Lease is how long claimed events stay with the relay. Publishing a
+	// batch is cut off when it runs out, as another relay may claim the
+	// events then; events cut off are not counted as attempted.
+	Lease time.Duration
+	// MaxAttempts is the number of attempts after which an event is
+	// dead-lettered.
+	MaxAttempts int
+	// MinBackoff is the delay before the first retry, doubled for each
+	// further one up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Retention is how long published events are kept, 0 keeps them.
+	Retention time.Duration
+}
+
+// Relay publishes the events of the outbox. It looks for due events whenever
+// the notifier wakes it up, and keeps going without waiting while it finds
+// full batches. Every event is published at least once: it is marked as
+// published only after the publisher accepted it, and an event whose
+// outcome could not be recorded is claimed again after its lease.
+type Relay struct {
+	repo      interfaces.OutboxRepository
+	publisher Publisher
+	notifier  interfaces.ChangeNotifier
+	opts      RelayOptions
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewRelay starts a relay publishing the events of repo through publisher.
+func NewRelay(repo interfaces.OutboxRepository, publisher Publisher, notifier interfaces.ChangeNotifier, opts RelayOptions) *Relay {
+	r := &Relay{
+		repo:      repo,
+		publisher: publisher,
+		notifier:  notifier,
+		opts:      opts,
+		done:      make(chan struct{}),
+	}
+	r.wg.Add(1)
+	go r.run()
+	return r
+}
+
+// Close stops the relay after the batch being published and closes the
+// publisher.
+func (r *Relay) Close() error {
+	close(r.done)
+	r.wg.Wait()
+	return r.publisher.Close()
+}
+
+func (r *Relay) run() {
+	defer r.wg.Done()
+	wake, unsubscribe := r.notifier.Subscribe()
+	defer unsubscribe()
+	prune := time.NewTicker(pruneInterval)
+	defer prune.Stop()
+	for {
+		n, err := r.relay()
+		if err != nil {
+			slog.Warn("relaying outbox events failed", "error", err)
+		}
+		if err == nil && n == r.opts.BatchSize {
+			select {
+			case <-r.done:
+				return
+			default:
+				continue
+			}
+		}
+		select {
+		case <-r.done:
+			return
+		case _, ok := <-wake:
+			if !ok {
+				return
+			}
+		case <-prune.C:
+			r.prune()
+		}
+	}
+}
+
+// relay claims a batch and publishes its events concurrently. A batch holds
+// at most one event of each user, so this keeps the events of a user in
+// order.
+func (r *Relay) relay() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.opts.Lease)
+	defer cancel()
+	events, err := r.repo.Claim(ctx, r.opts.BatchSize, r.opts.Lease)
+	if err != nil {
+		return 0, err
+	}
+	var wg sync.WaitGroup
+	for _, event := range events {
+		wg.Add(1)
+		go func(event models.OutboxEvent) {
+			defer wg.Done()
+			r.publish(ctx, event)
+		}(event)
+	}
+	wg.Wait()
+	return len(events), nil
+}
+
+func (r *Relay) publish(ctx context.Context, event models.OutboxEvent) {
+	err := r.publisher.Publish(ctx, event)
+	if err != nil && ctx.Err() != nil {
+		// The lease ran out, so another relay may be publishing the event
+		// already. Whatever failed, the attempt was cut short: leave the
+		// event to be claimed again instead of counting it against
+		// MaxAttempts.
+		slog.Warn("outbox lease expired while publishing", "event_id", event.ID, "type", event.Type, "error", err)
+		metrics.ObserveOutbox("expired")
+		return
+	}
+	markCtx, cancel := context.WithTimeout(context.Background(), markTimeout)
+	defer cancel()
+	var markErr error
+	switch {
+	case err == nil:
+		markErr = r.repo.MarkPublished(markCtx, event.ID)
+		metrics.ObserveOutbox("published")
+	case IsPermanent(err) || event.Attempts+1 >= r.opts.MaxAttempts:
+		slog.Error("dead-lettering outbox event", "event_id", event.ID, "type", event.Type, "attempts", event.Attempts+1, "error", err)
+		markErr = r.repo.DeadLetter(markCtx, event.ID, err.Error())
+		metrics.ObserveOutbox("dead")
+	default:
+		delay := Backoff(event.Attempts, r.opts.MinBackoff, r.opts.MaxBackoff)
+		slog.Warn("publishing outbox event failed, retrying", "event_id", event.ID, "type", event.Type, "attempts", event.Attempts+1, "retry_in", delay, "error", err)
+		markErr = r.repo.Retry(markCtx, event.ID, err.Error(), delay)
+		metrics.ObserveOutbox("retried")
+	}
+	if markErr != nil {
+		slog.Warn("recording outbox attempt failed", "event_id", event.ID, "error", markErr)
+	}
+}
+
+// Backoff returns the delay before retrying after attempts earlier failed
+// attempts and the one that just failed: base, doubled for every earlier
+// attempt, up to limit.
+func Backoff(attempts int, base, limit time.Duration) time.Duration {
+	delay := base
+	for i := 0; i < attempts && delay < limit; i++ {
+		delay *= 2
+	}
+	if delay > limit {
+		delay = limit
+	}
+	return delay
+}
+
+func (r *Relay) prune() {
+	if r.opts.Retention <= 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), markTimeout)
+	defer cancel()
+	n, err := r.repo.Prune(ctx, r.opts.Retention)
+	if err != nil {
+		slog.Warn("pruning outbox failed", "error", err)
+		return
+	}
+	slog.Debug("pruned outbox", "deleted", n)
+}
@@ -0,0 +1,182 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	mock_repository "grpc-user-service/pkg/repository/mock"
+	"grpc-user-service/pkg/utils/models"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePublisher returns the error set for each event id.
+type fakePublisher struct {
+	errs map[int64]error
+
+	mu        sync.Mutex
+	published []int64
+	closed    bool
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, event models.OutboxEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.published = append(p.published, event.ID)
+	return p.errs[event.ID]
+}
+
+func (p *fakePublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	return nil
+}
+
+var relayOptions = RelayOptions{
+	BatchSize:   10,
+	Lease:       time.Minute,
+	MaxAttempts: 10,
+	MinBackoff:  time.Second,
+	MaxBackoff:  time.Minute,
+}
+
+func Test_RelayOutcomes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := mock_repository.NewMockOutboxRepository(ctrl)
+	mockNotifier := mock_repository.NewMockChangeNotifier(ctrl)
+	wake := make(chan struct{})
+	mockNotifier.EXPECT().Subscribe().Return((<-chan struct{})(wake), func() {})
+	publisher := &fakePublisher{errs: map[int64]error{
+		2: errors.New("unavailable"),
+		3: errors.New("unavailable"),
+		4: Permanent(errors.New("rejected")),
+	}}
+
+	var wg sync.WaitGroup
+	wg.Add(4)
+	mockRepo.EXPECT().Claim(gomock.Any(), 10, time.Minute).Return([]models.OutboxEvent{
+		{ID: 1, Type: models.OutboxUserCreated},
+		{ID: 2, Type: models.OutboxUserUpdated, Attempts: 2},
+		{ID: 3, Type: models.OutboxUserUpdated, Attempts: 9},
+		{ID: 4, Type: models.OutboxUserDeleted},
+	}, nil)
+	mockRepo.EXPECT().MarkPublished(gomock.Any(), int64(1)).
+		Do(func(ctx context.Context, id int64) { wg.Done() })
+	mockRepo.EXPECT().Retry(gomock.Any(), int64(2), "unavailable", 4*time.Second).
+		Do(func(ctx context.Context, id int64, lastErr string, delay time.Duration) { wg.Done() })
+	dead := func(ctx context.Context, id int64, lastErr string) { wg.Done() }
+	mockRepo.EXPECT().DeadLetter(gomock.Any(), int64(3), "unavailable").Do(dead)
+	mockRepo.EXPECT().DeadLetter(gomock.Any(), int64(4), "rejected").Do(dead)
+
+	r := NewRelay(mockRepo, publisher, mockNotifier, relayOptions)
+	wg.Wait()
+	assert.NoError(t, r.Close())
+
+	assert.ElementsMatch(t, []int64{1, 2, 3, 4}, publisher.published)
+	assert.True(t, publisher.closed)
+}
+
+func Test_RelayWaits(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := mock_repository.NewMockOutboxRepository(ctrl)
+	mockNotifier := mock_repository.NewMockChangeNotifier(ctrl)
+	wake := make(chan struct{})
+	mockNotifier.EXPECT().Subscribe().Return((<-chan struct{})(wake), func() {})
+	publisher := &fakePublisher{}
+	options := relayOptions
+	options.BatchSize = 1
+
+	claimed := make(chan struct{})
+	gomock.InOrder(
+		// A full batch is followed by the next one right away.
+		mockRepo.EXPECT().Claim(gomock.Any(), 1, time.Minute).Return([]models.OutboxEvent{{ID: 1}}, nil),
+		mockRepo.EXPECT().MarkPublished(gomock.Any(), int64(1)).Return(nil),
+		mockRepo.EXPECT().Claim(gomock.Any(), 1, time.Minute).Return(nil, errors.New("error")),
+		// After a failure the relay waits to be woken up.
+		mockRepo.EXPECT().Claim(gomock.Any(), 1, time.Minute).DoAndReturn(
+			func(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEvent, error) {
+				close(claimed)
+				return nil, nil
+			}),
+	)
+
+	r := NewRelay(mockRepo, publisher, mockNotifier, options)
+	select {
+	case <-claimed:
+		t.Fatal("claimed without a wake-up")
+	case <-time.After(50 * time.Millisecond):
+	}
+	wake <- struct{}{}
+	<-claimed
+	assert.NoError(t, r.Close())
+	assert.Equal(t, []int64{1}, publisher.published)
+}
+
+// stallingPublisher blocks until the lease runs out and then fails with err.
+type stallingPublisher struct {
+	err    error
+	called chan struct{}
+}
+
+func (p *stallingPublisher) Publish(ctx context.Context, event models.OutboxEvent) error {
+	<-ctx.Done()
+	close(p.called)
+	if p.err != nil {
+		return p.err
+	}
+	return ctx.Err()
+}
+
+func (p *stallingPublisher) Close() error { return nil }
+
+func Test_RelayLeaseExpired(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "deadline exceeded"},
+		{name: "publisher error after the lease", err: errors.New("connection reset")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockRepo := mock_repository.NewMockOutboxRepository(ctrl)
+			mockNotifier := mock_repository.NewMockChangeNotifier(ctrl)
+			wake := make(chan struct{})
+			mockNotifier.EXPECT().Subscribe().Return((<-chan struct{})(wake), func() {})
+			publisher := &stallingPublisher{err: tt.err, called: make(chan struct{})}
+			options := relayOptions
+			options.Lease = 20 * time.Millisecond
+
+			// Neither Retry nor DeadLetter: the event is claimed again
+			// after its lease without an attempt recorded.
+			mockRepo.EXPECT().Claim(gomock.Any(), 10, options.Lease).Return([]models.OutboxEvent{{ID: 1, Attempts: 9}}, nil)
+
+			r := NewRelay(mockRepo, publisher, mockNotifier, options)
+			<-publisher.called
+			assert.NoError(t, r.Close())
+		})
+	}
+}
+
+func Test_Backoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 0, want: time.Second},
+		{attempts: 1, want: 2 * time.Second},
+		{attempts: 3, want: 8 * time.Second},
+		{attempts: 4, want: 10 * time.Second},
+		{attempts: 100, want: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Backoff(tt.attempts, time.Second, 10*time.Second), "attempts %d", tt.attempts)
+	}
+}
@@ -0,0 +1,66 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"grpc-user-service/pkg/utils/models"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type webhookPublisher struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookPublisher posts every event as JSON to url. Any 2xx response
+// accepts the event; 4xx responses other than 408 and 429 reject it for good.
+// timeout bounds each request.
+func NewWebhookPublisher(url string, timeout time.Duration) Publisher {
+	return &webhookPublisher{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (p *webhookPublisher) Publish(ctx context.Context, event models.OutboxEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return Permanent(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-Id", strconv.FormatInt(event.ID, 10))
+	req.Header.Set("X-Event-Type", event.Type)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return statusError(resp.StatusCode)
+}
+
+func (p *webhookPublisher) Close() error {
+	p.client.CloseIdleConnections()
+	return nil
+}
+
+// statusError returns the error for an HTTP response status, nil for 2xx.
+func statusError(code int) error {
+	if code >= 200 && code < 300 {
+		return nil
+	}
+	err := fmt.Errorf("webhook responded %d %s", code, http.StatusText(code))
+	if code >= 400 && code < 500 && code != http.StatusRequestTimeout && code != http.StatusTooManyRequests {
+		return Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,73 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"grpc-user-service/pkg/utils/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WebhookPublisher(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		wantErr       bool
+		wantPermanent bool
+	}{
+		{name: "accepted", status: http.StatusNoContent},
+		{name: "server error", status: http.StatusBadGateway, wantErr: true},
+		{name: "rate limited", status: http.StatusTooManyRequests, wantErr: true},
+		{name: "rejected", status: http.StatusUnprocessableEntity, wantErr: true, wantPermanent: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *http.Request
+			var body []byte
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r
+				body, _ = io.ReadAll(r.Body)
+				w.WriteHeader(tt.status)
+			}))
+			defer receiver.Close()
+			publisher := NewWebhookPublisher(receiver.URL, time.Second)
+			defer publisher.Close()
+
+			err := publisher.Publish(context.Background(), testEvent)
+
+			assert.Equal(t, tt.wantErr, err != nil, "error %v", err)
+			assert.Equal(t, tt.wantPermanent, IsPermanent(err))
+			require.NotNil(t, got)
+			assert.Equal(t, http.MethodPost, got.Method)
+			assert.Equal(t, "application/json", got.Header.Get("Content-Type"))
+			assert.Equal(t, "42", got.Header.Get("X-Event-Id"))
+			assert.Equal(t, "UserCreated", got.Header.Get("X-Event-Type"))
+			var event models.OutboxEvent
+			require.NoError(t, json.Unmarshal(body, &event))
+			assert.Equal(t, testEvent.User, event.User)
+		})
+	}
+}
+
+func Test_WebhookPublisherTimeout(t *testing.T) {
+	release := make(chan struct{})
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer receiver.Close()
+	defer close(release)
+	publisher := NewWebhookPublisher(receiver.URL, 50*time.Millisecond)
+
+	err := publisher.Publish(context.Background(), testEvent)
+
+	assert.Error(t, err)
+	assert.False(t, IsPermanent(err))
+}
@@ -0,0 +1,33 @@
+package phone
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nyaruka/phonenumbers"
+)
+
+// ErrInvalid is returned for numbers that cannot be parsed or are not valid
+// numbers for their country.
+var ErrInvalid = errors.New("invalid phone number")
+
+// Normalize parses a phone number written in any common format, e.g.
+// "+91 98765 43210", "098765-43210" or "9876543210", and returns it in E.164
+// form ("+919876543210"). Numbers without a leading + or international
+// prefix are read as national numbers of region, an ISO 3166-1 alpha-2 code.
+func Normalize(number, region string) (string, error) {
+	number = strings.TrimSpace(number)
+	if number == "" {
+		return "", fmt.Errorf("%w: empty", ErrInvalid)
+	}
+	region = strings.ToUpper(strings.TrimSpace(region))
+	parsed, err := phonenumbers.Parse(number, region)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if !phonenumbers.IsValidNumber(parsed) {
+		return "", fmt.Errorf("%w: not a valid number for country code +%d", ErrInvalid, parsed.GetCountryCode())
+	}
+	return phonenumbers.Format(parsed, phonenumbers.E164), nil
+}
@@ -0,0 +1,40 @@
+package phone
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Normalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		number  string
+		region  string
+		want    string
+		wantErr bool
+	}{
+		{name: "national number", number: "9876543210", region: "IN", want: "+919876543210"},
+		{name: "national number with trunk prefix", number: "098765-43210", region: "IN", want: "+919876543210"},
+		{name: "international format", number: "+91 98765 43210", region: "", want: "+919876543210"},
+		{name: "region hint is ignored for international numbers", number: "+1 (650) 253-0000", region: "IN", want: "+16502530000"},
+		{name: "lower case region", number: "020 7946 0018", region: "gb", want: "+442079460018"},
+		{name: "leading zero is kept", number: "06 12 34 56 78", region: "FR", want: "+33612345678"},
+		{name: "empty", number: " ", region: "IN", wantErr: true},
+		{name: "letters", number: "not a number", region: "IN", wantErr: true},
+		{name: "national number without region", number: "9876543210", region: "", wantErr: true},
+		{name: "invalid for region", number: "12345", region: "IN", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(tt.number, tt.region)
+			if tt.wantErr {
+				assert.True(t, errors.Is(err, ErrInvalid), "got %v", err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
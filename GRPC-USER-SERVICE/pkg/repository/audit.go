@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"context"
+	"grpc-user-service/pkg/audit"
+	"grpc-user-service/pkg/metrics"
+	interfaces "grpc-user-service/pkg/repository/interface"
+	"grpc-user-service/pkg/utils/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// auditLockKey is the advisory lock serializing appends to the audit log, so
+// that instances sharing the database extend one chain.
+const auditLockKey = 0x61756469
+
+const auditColumns = `seq, recorded_at, principal, method, array_to_string(target_ids, ',') AS target_ids, code, request_id, prev_hash, hash`
+
+type auditRepository struct {
+	DB           *gorm.DB
+	queryTimeout time.Duration
+}
+
+// NewAuditRepository returns the Postgres audit log. Every query is bounded
+// by queryTimeout, 0 leaves only the caller's deadline.
+func NewAuditRepository(DB *gorm.DB, queryTimeout time.Duration) interfaces.AuditRepository {
+	return &auditRepository{
+		DB:           DB,
+		queryTimeout: queryTimeout,
+	}
+}
+
+// auditRow is a row of audit_log with target_ids as a comma separated list.
+type auditRow struct {
+	Seq        int64
+	RecordedAt time.Time
+	Principal  string
+	Method     string
+	TargetIDs  string
+	Code       string
+	RequestID  string
+	PrevHash   []byte
+	Hash       []byte
+}
+
+func (r auditRow) entry() (models.AuditEntry, error) {
+	e := models.AuditEntry{
+		Seq:        r.Seq,
+		RecordedAt: r.RecordedAt,
+		Principal:  r.Principal,
+		Method:     r.Method,
+		TargetIDs:  []int64{},
+		Code:       r.Code,
+		RequestID:  r.RequestID,
+		PrevHash:   r.PrevHash,
+		Hash:       r.Hash,
+	}
+	if r.TargetIDs == "" {
+		return e, nil
+	}
+	for _, s := range strings.Split(r.TargetIDs, ",") {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return models.AuditEntry{}, err
+		}
+		e.TargetIDs = append(e.TargetIDs, id)
+	}
+	return e, nil
+}
+
+func (a *auditRepository) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if a.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, a.queryTimeout)
+}
+
+// Append chains entries to the last one in the log. Recording times are
+// truncated to the microseconds Postgres stores, so that the hash can be
+// recomputed from the stored row.
+func (a *auditRepository) Append(ctx context.Context, entries []models.AuditEntry) error {
+	defer metrics.ObserveQuery("AuditAppend", time.Now())
+	ctx, cancel := a.withTimeout(ctx)
+	defer cancel()
+	return a.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, auditLockKey).Error; err != nil {
+			return err
+		}
+		var last auditRow
+		result := tx.Raw(`SELECT seq, hash FROM audit_log ORDER BY seq DESC LIMIT 1`).Scan(&last)
+		if result.Error != nil {
+			return result.Error
+		}
+		seq, prev := last.Seq, last.Hash
+		if result.RowsAffected == 0 {
+			prev = audit.Genesis
+		}
+		for _, e := range entries {
+			seq++
+			e.Seq = seq
+			e.RecordedAt = e.RecordedAt.UTC().Truncate(time.Microsecond)
+			e.PrevHash = prev
+			e.Hash = audit.Hash(prev, e)
+			err := tx.Exec(`INSERT INTO audit_log (seq, recorded_at, principal, method, target_ids, code, request_id, prev_hash, hash) VALUES ($1, $2, $3, $4, $5::bigint[], $6, $7, $8, $9)`,
+				e.Seq, e.RecordedAt, e.Principal, e.Method, int64Array(e.TargetIDs), e.Code, e.RequestID, e.PrevHash, e.Hash).Error
+			if err != nil {
+				return err
+			}
+			prev = e.Hash
+		}
+		return nil
+	})
+}
+
+func (a *auditRepository) List(ctx context.Context, afterSeq int64, limit int) ([]models.AuditEntry, error) {
+	defer metrics.ObserveQuery("AuditList", time.Now())
+	ctx, cancel := a.withTimeout(ctx)
+	defer cancel()
+	var rows []auditRow
+	err := a.DB.WithContext(ctx).Raw(`SELECT `+auditColumns+` FROM audit_log WHERE seq > $1 ORDER BY seq LIMIT $2`, afterSeq, limit).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return auditEntries(rows)
+}
+
+func (a *auditRepository) Query(ctx context.Context, query models.AuditQuery, before int64, limit int) ([]models.AuditEntry, error) {
+	defer metrics.ObserveQuery("AuditQuery", time.Now())
+	ctx, cancel := a.withTimeout(ctx)
+	defer cancel()
+	var conds []string
+	var args []interface{}
+	where := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, strings.ReplaceAll(cond, "$", "$"+strconv.Itoa(len(args))))
+	}
+	if before > 0 {
+		where("seq < $", before)
+	}
+	if query.Principal != "" {
+		where("principal = $", query.Principal)
+	}
+	if query.Method != "" {
+		where("method = $", query.Method)
+	}
+	if query.TargetID > 0 {
+		// Overlap rather than containment: gorm reads @ as a named argument.
+		where("target_ids && ARRAY[$::bigint]", query.TargetID)
+	}
+	if !query.After.IsZero() {
+		where("recorded_at >= $", query.After)
+	}
+	if !query.Before.IsZero() {
+		where("recorded_at < $", query.Before)
+	}
+	sql := `SELECT ` + auditColumns + ` FROM audit_log`
+	if len(conds) > 0 {
+		sql += ` WHERE ` + strings.Join(conds, " AND ")
+	}
+	args = append(args, limit)
+	sql += ` ORDER BY seq DESC LIMIT $` + strconv.Itoa(len(args))
+
+	var rows []auditRow
+	if err := a.DB.WithContext(ctx).Raw(sql, args...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return auditEntries(rows)
+}
+
+func auditEntries(rows []auditRow) ([]models.AuditEntry, error) {
+	entries := make([]models.AuditEntry, 0, len(rows))
+	for _, row := range rows {
+		e, err := row.entry()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"grpc-user-service/pkg/audit"
+	"grpc-user-service/pkg/utils/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func Test_AuditAppend(t *testing.T) {
+	lockQuery := regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)")
+	lastQuery := regexp.QuoteMeta("SELECT seq, hash FROM audit_log ORDER BY seq DESC LIMIT 1")
+	insertQuery := regexp.QuoteMeta("INSERT INTO audit_log (seq, recorded_at, principal, method, target_ids, code, request_id, prev_hash, hash) VALUES ($1, $2, $3, $4, $5::bigint[], $6, $7, $8, $9)")
+	recordedAt := time.Date(2024, 5, 1, 10, 0, 0, 123456789, time.UTC)
+	entries := []models.AuditEntry{
+		{RecordedAt: recordedAt, Principal: "alice", Method: "/userservice.UserService/GetUserByID", TargetIDs: []int64{1}, Code: "OK"},
+		{RecordedAt: recordedAt, Principal: "bob", Method: "/userservice.UserService/SearchUsers", TargetIDs: []int64{}, Code: "OK"},
+	}
+	// hashes computes the chain of entries following prev, starting at seq.
+	hashes := func(seq int64, prev []byte) [][]byte {
+		var out [][]byte
+		for _, e := range entries {
+			e.Seq, e.RecordedAt, e.PrevHash = seq, recordedAt.Truncate(time.Microsecond), prev
+			prev = audit.Hash(prev, e)
+			out = append(out, prev)
+			seq++
+		}
+		return out
+	}
+	lastHash := []byte("last")
+
+	tests := []struct {
+		name    string
+		stub    func(mockSQL sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name: "empty log",
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				h := hashes(1, audit.Genesis)
+				mockSQL.ExpectBegin()
+				mockSQL.ExpectExec(lockQuery).WithArgs(auditLockKey).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockSQL.ExpectQuery(lastQuery).WillReturnRows(sqlmock.NewRows([]string{"seq", "hash"}))
+				mockSQL.ExpectExec(insertQuery).
+					WithArgs(int64(1), recordedAt.Truncate(time.Microsecond), "alice", entries[0].Method, "{1}", "OK", "", audit.Genesis, h[0]).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mockSQL.ExpectExec(insertQuery).
+					WithArgs(int64(2), recordedAt.Truncate(time.Microsecond), "bob", entries[1].Method, "{}", "OK", "", h[0], h[1]).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mockSQL.ExpectCommit()
+			},
+		},
+		{
+			name: "continues the chain",
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				h := hashes(8, lastHash)
+				mockSQL.ExpectBegin()
+				mockSQL.ExpectExec(lockQuery).WithArgs(auditLockKey).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockSQL.ExpectQuery(lastQuery).WillReturnRows(sqlmock.NewRows([]string{"seq", "hash"}).AddRow(7, lastHash))
+				mockSQL.ExpectExec(insertQuery).
+					WithArgs(int64(8), sqlmock.AnyArg(), "alice", sqlmock.AnyArg(), "{1}", "OK", "", lastHash, h[0]).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mockSQL.ExpectExec(insertQuery).
+					WithArgs(int64(9), sqlmock.AnyArg(), "bob", sqlmock.AnyArg(), "{}", "OK", "", h[0], h[1]).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mockSQL.ExpectCommit()
+			},
+		},
+		{
+			name: "error",
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				mockSQL.ExpectBegin()
+				mockSQL.ExpectExec(lockQuery).WithArgs(auditLockKey).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockSQL.ExpectQuery(lastQuery).WillReturnRows(sqlmock.NewRows([]string{"seq", "hash"}))
+				mockSQL.ExpectExec(insertQuery).WillReturnError(errors.New("error"))
+				mockSQL.ExpectRollback()
+			},
+			wantErr: errors.New("error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, _ := sqlmock.New()
+			defer mockDB.Close()
+			gormDB, _ := gorm.Open(postgres.New(postgres.Config{
+				Conn: mockDB,
+			}), &gorm.Config{})
+			tt.stub(mockSQL)
+			a := NewAuditRepository(gormDB, 0)
+
+			err := a.Append(context.Background(), entries)
+
+			assert.Equal(t, tt.wantErr, err)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_AuditQuery(t *testing.T) {
+	columns := []string{"seq", "recorded_at", "principal", "method", "target_ids", "code", "request_id", "prev_hash", "hash"}
+	recordedAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		query   models.AuditQuery
+		before  int64
+		stub    func(mockSQL sqlmock.Sqlmock)
+		want    []models.AuditEntry
+		wantErr error
+	}{
+		{
+			name: "no filters",
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				mockSQL.ExpectQuery(regexp.QuoteMeta(`FROM audit_log ORDER BY seq DESC LIMIT $1`)).
+					WithArgs(10).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(2, recordedAt, "alice", "/userservice.UserService/GetUsersByIDs", "1,2", "OK", "", []byte("p"), []byte("h")).
+						AddRow(1, recordedAt, "alice", "/userservice.UserService/SearchUsers", "", "OK", "", []byte("g"), []byte("p")))
+			},
+			want: []models.AuditEntry{
+				{Seq: 2, RecordedAt: recordedAt, Principal: "alice", Method: "/userservice.UserService/GetUsersByIDs", TargetIDs: []int64{1, 2}, Code: "OK", PrevHash: []byte("p"), Hash: []byte("h")},
+				{Seq: 1, RecordedAt: recordedAt, Principal: "alice", Method: "/userservice.UserService/SearchUsers", TargetIDs: []int64{}, Code: "OK", PrevHash: []byte("g"), Hash: []byte("p")},
+			},
+		},
+		{
+			name:   "all filters",
+			query:  models.AuditQuery{Principal: "alice", Method: "/userservice.UserService/GetUserByID", TargetID: 3, After: recordedAt, Before: recordedAt.Add(time.Hour)},
+			before: 40,
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				mockSQL.ExpectQuery(regexp.QuoteMeta(`FROM audit_log WHERE seq < $1 AND principal = $2 AND method = $3 AND target_ids && ARRAY[$4::bigint] AND recorded_at >= $5 AND recorded_at < $6 ORDER BY seq DESC LIMIT $7`)).
+					WithArgs(int64(40), "alice", "/userservice.UserService/GetUserByID", int64(3), recordedAt, recordedAt.Add(time.Hour), 10).
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+			want: []models.AuditEntry{},
+		},
+		{
+			name: "error",
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				mockSQL.ExpectQuery(regexp.QuoteMeta(`FROM audit_log`)).WillReturnError(errors.New("error"))
+			},
+			wantErr: errors.New("error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, _ := sqlmock.New()
+			defer mockDB.Close()
+			gormDB, _ := gorm.Open(postgres.New(postgres.Config{
+				Conn: mockDB,
+			}), &gorm.Config{})
+			tt.stub(mockSQL)
+			a := NewAuditRepository(gormDB, 0)
+
+			got, err := a.Query(context.Background(), tt.query, tt.before, 10)
+
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
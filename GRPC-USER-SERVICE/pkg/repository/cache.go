@@ -0,0 +1,271 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"grpc-user-service/pkg/cache"
+	"grpc-user-service/pkg/db"
+	"grpc-user-service/pkg/metrics"
+	interfaces "grpc-user-service/pkg/repository/interface"
+	"grpc-user-service/pkg/utils/models"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+)
+
+// cachedUserRepository serves user lookups by id and phone from a cache in
+// front of the database. Concurrent misses for the same key share a single
+// query and writes invalidate the keys they touch. Reads inside a transaction
+// or asking for the primary bypass the cache so they see the transaction's
+// snapshot and the latest writes.
+type cachedUserRepository struct {
+	interfaces.UserRepository
+	cache cache.Cache
+	ttl   time.Duration
+	group singleflight.Group
+	// generation is bumped by every invalidation. A fill that started before
+	// an invalidation may have read the old row and is not stored.
+	generation atomic.Uint64
+	// mu makes storing a fill after checking its generation, and bumping the
+	// generation with dropping the keys, single steps, so that a fill cannot
+	// be stored between an invalidation's bump and its delete.
+	mu sync.Mutex
+}
+
+// NewCachedUserRepository wraps repository with a read-through cache whose
+// entries live for ttl.
+func NewCachedUserRepository(repository interfaces.UserRepository, c cache.Cache, ttl time.Duration) interfaces.UserRepository {
+	return &cachedUserRepository{
+		UserRepository: repository,
+		cache:          c,
+		ttl:            ttl,
+	}
+}
+
+func userIDKey(id int64) string {
+	return "user:id:" + strconv.FormatInt(id, 10)
+}
+
+func userPhoneKey(phone string) string {
+	return "user:phone:" + phone
+}
+
+func (r *cachedUserRepository) GetUserByID(ctx context.Context, Id int64) (models.Users, error) {
+	if !cacheable(ctx) {
+		return r.UserRepository.GetUserByID(ctx, Id)
+	}
+	var user models.Users
+	err := r.readThrough(ctx, "id", userIDKey(Id), &user, func(ctx context.Context) (interface{}, error) {
+		return r.UserRepository.GetUserByID(ctx, Id)
+	})
+	return user, err
+}
+
+// GetUsersByIDs answers the cached ids from the cache and loads the others
+// in a single query.
+func (r *cachedUserRepository) GetUsersByIDs(ctx context.Context, Ids []int64) ([]models.Users, error) {
+	if !cacheable(ctx) {
+		return r.UserRepository.GetUsersByIDs(ctx, Ids)
+	}
+	byID := make(map[int64]models.Users, len(Ids))
+	seen := make(map[int64]bool, len(Ids))
+	var missing []int64
+	for _, id := range Ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		var user models.Users
+		if r.get(ctx, "id", userIDKey(id), &user) {
+			byID[id] = user
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		generation := r.generation.Load()
+		loaded, err := r.UserRepository.GetUsersByIDs(db.WithPrimary(ctx), missing)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range loaded {
+			byID[user.ID] = user
+			r.set(ctx, userIDKey(user.ID), user, generation)
+		}
+	}
+
+	users := make([]models.Users, 0, len(byID))
+	for _, id := range Ids {
+		if user, ok := byID[id]; ok {
+			users = append(users, user)
+			delete(byID, id)
+		}
+	}
+	return users, nil
+}
+
+// SearchUsers answers searches naming nothing but a phone number from the
+// phone cache. A number belongs to one user at most, so there is nothing to
+// order.
+func (r *cachedUserRepository) SearchUsers(ctx context.Context, search models.SearchUser) ([]models.Users, error) {
+	if search.Phone == "" || search.City != "" || search.Married != nil ||
+		!search.CreatedAfter.IsZero() || !search.CreatedBefore.IsZero() ||
+		!search.UpdatedAfter.IsZero() || !search.UpdatedBefore.IsZero() {
+		return r.UserRepository.SearchUsers(ctx, search)
+	}
+	return r.SearchPhone(ctx, search.Phone)
+}
+
+// SearchPhone caches the ids of the users with phone and resolves them
+// through the id cache. A user whose number changed after the entry was
+// written is dropped from the result and the entry refreshed.
+func (r *cachedUserRepository) SearchPhone(ctx context.Context, phone string) ([]models.Users, error) {
+	if !cacheable(ctx) {
+		return r.UserRepository.SearchPhone(ctx, phone)
+	}
+	var ids []int64
+	err := r.readThrough(ctx, "phone", userPhoneKey(phone), &ids, func(ctx context.Context) (interface{}, error) {
+		generation := r.generation.Load()
+		users, err := r.UserRepository.SearchPhone(ctx, phone)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]int64, len(users))
+		for i, user := range users {
+			ids[i] = user.ID
+			r.set(ctx, userIDKey(user.ID), user, generation)
+		}
+		return ids, nil
+	})
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	users, err := r.GetUsersByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	matching := make([]models.Users, 0, len(users))
+	for _, user := range users {
+		if user.Phone == phone {
+			matching = append(matching, user)
+		}
+	}
+	if len(matching) != len(ids) {
+		r.invalidate(ctx, userPhoneKey(phone))
+	}
+	return matching, nil
+}
+
+func (r *cachedUserRepository) AddUser(ctx context.Context, user models.User) error {
+	err := r.UserRepository.AddUser(ctx, user)
+	if err == nil {
+		r.invalidate(ctx, userPhoneKey(user.Phone))
+	}
+	return err
+}
+
+// UpdateUser invalidates the user and its new number. The entry of the old
+// number is corrected by SearchPhone when it is next read.
+func (r *cachedUserRepository) UpdateUser(ctx context.Context, Id int64, user models.User, version int64) (models.Users, error) {
+	updated, err := r.UserRepository.UpdateUser(ctx, Id, user, version)
+	if err == nil {
+		r.invalidate(ctx, userIDKey(Id), userPhoneKey(updated.Phone))
+	}
+	return updated, err
+}
+
+func (r *cachedUserRepository) DeleteUser(ctx context.Context, Id int64, version int64) error {
+	err := r.UserRepository.DeleteUser(ctx, Id, version)
+	if err == nil {
+		r.invalidate(ctx, userIDKey(Id))
+	}
+	return err
+}
+
+// readThrough decodes the value cached under key into dst, or loads it with
+// load and caches it. Fills read from the primary so that a lagging replica
+// cannot put a row older than the last write back into the cache, and are
+// not bound to the caller: a caller giving up does not fail the others
+// waiting for the same key.
+func (r *cachedUserRepository) readThrough(ctx context.Context, lookup, key string, dst interface{}, load func(ctx context.Context) (interface{}, error)) error {
+	if r.get(ctx, lookup, key, dst) {
+		return nil
+	}
+	fill := r.group.DoChan(key, func() (interface{}, error) {
+		generation := r.generation.Load()
+		value, err := load(db.WithPrimary(context.WithoutCancel(ctx)))
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		r.store(ctx, key, data, generation)
+		return data, nil
+	})
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case result := <-fill:
+		if result.Err != nil {
+			return result.Err
+		}
+		return json.Unmarshal(result.Val.([]byte), dst)
+	}
+}
+
+func (r *cachedUserRepository) get(ctx context.Context, lookup, key string, dst interface{}) bool {
+	data, ok := r.cache.Get(ctx, key)
+	if ok {
+		if err := json.Unmarshal(data, dst); err != nil {
+			slog.WarnContext(ctx, "dropping undecodable cache entry", "key", key, "error", err)
+			r.cache.Delete(ctx, key)
+			ok = false
+		}
+	}
+	metrics.ObserveCache(lookup, ok)
+	return ok
+}
+
+func (r *cachedUserRepository) set(ctx context.Context, key string, value interface{}, generation uint64) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	r.store(ctx, key, data, generation)
+}
+
+func (r *cachedUserRepository) store(ctx context.Context, key string, data []byte, generation uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.generation.Load() != generation {
+		return
+	}
+	r.cache.Set(ctx, key, data, r.ttl)
+}
+
+// invalidate drops keys from the cache. Fills in flight are forgotten so
+// that later callers do not join a query that may predate the write.
+func (r *cachedUserRepository) invalidate(ctx context.Context, keys ...string) {
+	r.mu.Lock()
+	r.generation.Add(1)
+	r.cache.Delete(ctx, keys...)
+	r.mu.Unlock()
+	for _, key := range keys {
+		r.group.Forget(key)
+	}
+}
+
+// cacheable reports whether reads made with ctx may be served from the
+// cache: outside transactions, whose snapshot the cache knows nothing about,
+// and unless the caller asked for the primary to see its latest writes.
+func cacheable(ctx context.Context) bool {
+	_, inTx := ctx.Value(txKey{}).(*gorm.DB)
+	return !inTx && !db.PrimaryRequested(ctx)
+}
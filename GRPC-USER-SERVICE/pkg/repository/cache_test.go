@@ -0,0 +1,251 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"grpc-user-service/pkg/cache"
+	"grpc-user-service/pkg/db"
+	mock_repository "grpc-user-service/pkg/repository/mock"
+	"grpc-user-service/pkg/utils/models"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+var cachedUser = models.Users{ID: 1, Fname: "Akhil", City: "Kannur", Phone: "+919087678564", Height: 157.6, Married: true}
+
+func Test_CachedGetUserByID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mock_repository.NewMockUserRepository(ctrl)
+	lru := cache.NewLRU(10)
+	u := NewCachedUserRepository(mockRepo, lru, time.Minute)
+
+	mockRepo.EXPECT().GetUserByID(gomock.Any(), int64(1)).Return(cachedUser, nil).Times(1)
+	for i := 0; i < 3; i++ {
+		user, err := u.GetUserByID(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, cachedUser, user)
+	}
+
+	mockRepo.EXPECT().GetUserByID(gomock.Any(), int64(2)).Return(models.Users{}, errors.New("error")).Times(2)
+	for i := 0; i < 2; i++ {
+		_, err := u.GetUserByID(context.Background(), 2)
+		assert.EqualError(t, err, "error")
+	}
+
+	assert.Equal(t, uint64(2), lru.Stats().Hits)
+	assert.Equal(t, uint64(3), lru.Stats().Misses)
+}
+
+func Test_CachedGetUserByIDCollapsesMisses(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mock_repository.NewMockUserRepository(ctrl)
+	u := NewCachedUserRepository(mockRepo, cache.NewLRU(10), time.Minute)
+
+	release := make(chan struct{})
+	mockRepo.EXPECT().GetUserByID(gomock.Any(), int64(1)).DoAndReturn(func(ctx context.Context, id int64) (models.Users, error) {
+		<-release
+		return cachedUser, nil
+	}).Times(1)
+
+	const callers = 10
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			user, err := u.GetUserByID(context.Background(), 1)
+			assert.NoError(t, err)
+			assert.Equal(t, cachedUser, user)
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+}
+
+func Test_CachedGetUsersByIDs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mock_repository.NewMockUserRepository(ctrl)
+	u := NewCachedUserRepository(mockRepo, cache.NewLRU(10), time.Minute)
+	other := models.Users{ID: 2, Fname: "Rahul", City: "Kochi", Phone: "+919087678565"}
+
+	mockRepo.EXPECT().GetUserByID(gomock.Any(), int64(1)).Return(cachedUser, nil)
+	_, err := u.GetUserByID(context.Background(), 1)
+	assert.NoError(t, err)
+
+	mockRepo.EXPECT().GetUsersByIDs(gomock.Any(), []int64{2, 3}).Return([]models.Users{other}, nil)
+	users, err := u.GetUsersByIDs(context.Background(), []int64{2, 1, 3, 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Users{other, cachedUser}, users)
+
+	mockRepo.EXPECT().GetUsersByIDs(gomock.Any(), []int64{3}).Return(nil, nil)
+	users, err = u.GetUsersByIDs(context.Background(), []int64{1, 2, 3})
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Users{cachedUser, other}, users)
+}
+
+func Test_CachedSearchPhoneInvalidatedByAddUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mock_repository.NewMockUserRepository(ctrl)
+	u := NewCachedUserRepository(mockRepo, cache.NewLRU(10), time.Minute)
+	ctx := context.Background()
+
+	gomock.InOrder(
+		mockRepo.EXPECT().SearchPhone(gomock.Any(), cachedUser.Phone).Return(nil, nil),
+		mockRepo.EXPECT().AddUser(gomock.Any(), gomock.Any()).Return(nil),
+		mockRepo.EXPECT().SearchPhone(gomock.Any(), cachedUser.Phone).Return([]models.Users{cachedUser}, nil),
+	)
+
+	for i := 0; i < 2; i++ {
+		users, err := u.SearchPhone(ctx, cachedUser.Phone)
+		assert.NoError(t, err)
+		assert.Empty(t, users)
+	}
+	assert.NoError(t, u.AddUser(ctx, models.User{Fname: cachedUser.Fname, Phone: cachedUser.Phone}))
+	for i := 0; i < 2; i++ {
+		users, err := u.SearchPhone(ctx, cachedUser.Phone)
+		assert.NoError(t, err)
+		assert.Equal(t, []models.Users{cachedUser}, users)
+	}
+}
+
+func Test_CachedSearchUsers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mock_repository.NewMockUserRepository(ctrl)
+	u := NewCachedUserRepository(mockRepo, cache.NewLRU(10), time.Minute)
+	ctx := context.Background()
+
+	// A phone alone is answered from the phone cache, anything more by the
+	// database.
+	mockRepo.EXPECT().SearchPhone(gomock.Any(), cachedUser.Phone).Return([]models.Users{cachedUser}, nil).Times(1)
+	for i := 0; i < 2; i++ {
+		users, err := u.SearchUsers(ctx, models.SearchUser{Phone: cachedUser.Phone, SortBy: models.SortByCreatedAt})
+		assert.NoError(t, err)
+		assert.Equal(t, []models.Users{cachedUser}, users)
+	}
+	bounded := models.SearchUser{Phone: cachedUser.Phone, CreatedAfter: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)}
+	mockRepo.EXPECT().SearchUsers(gomock.Any(), bounded).Return(nil, nil).Times(1)
+	users, err := u.SearchUsers(ctx, bounded)
+	assert.NoError(t, err)
+	assert.Empty(t, users)
+}
+
+func Test_CachedReadsInTransaction(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mock_repository.NewMockUserRepository(ctrl)
+	u := NewCachedUserRepository(mockRepo, cache.NewLRU(10), time.Minute)
+
+	txCtx := context.WithValue(context.Background(), txKey{}, &gorm.DB{})
+	mockRepo.EXPECT().GetUserByID(txCtx, int64(1)).Return(cachedUser, nil).Times(2)
+	for i := 0; i < 2; i++ {
+		_, err := u.GetUserByID(txCtx, 1)
+		assert.NoError(t, err)
+	}
+
+	// Reads asking for the primary bypass the cache too, even once the user
+	// is cached.
+	mockRepo.EXPECT().GetUserByID(gomock.Any(), int64(1)).Return(cachedUser, nil).Times(1)
+	_, err := u.GetUserByID(context.Background(), 1)
+	assert.NoError(t, err)
+	primaryCtx := db.WithPrimary(context.Background())
+	mockRepo.EXPECT().GetUserByID(primaryCtx, int64(1)).Return(cachedUser, nil).Times(2)
+	for i := 0; i < 2; i++ {
+		_, err := u.GetUserByID(primaryCtx, 1)
+		assert.NoError(t, err)
+	}
+}
+
+// blockingCache holds Set until release is closed.
+type blockingCache struct {
+	cache.Cache
+	setting chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) {
+	close(c.setting)
+	<-c.release
+	c.Cache.Set(ctx, key, value, ttl)
+}
+
+func Test_CachedStoreRacingInvalidate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	lru := cache.NewLRU(10)
+	c := &blockingCache{Cache: lru, setting: make(chan struct{}), release: make(chan struct{})}
+	u := NewCachedUserRepository(mock_repository.NewMockUserRepository(ctrl), c, time.Minute).(*cachedUserRepository)
+	ctx := context.Background()
+
+	// A fill that passed its generation check is stored before a concurrent
+	// invalidation drops the key, never after.
+	stored := make(chan struct{})
+	go func() {
+		u.store(ctx, userIDKey(1), []byte(`{}`), u.generation.Load())
+		close(stored)
+	}()
+	<-c.setting
+	invalidated := make(chan struct{})
+	go func() {
+		u.invalidate(ctx, userIDKey(1))
+		close(invalidated)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(c.release)
+	<-stored
+	<-invalidated
+
+	_, ok := lru.Get(ctx, userIDKey(1))
+	assert.False(t, ok)
+}
+
+func Test_CachedUpdateAndDeleteInvalidate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockRepo := mock_repository.NewMockUserRepository(ctrl)
+	u := NewCachedUserRepository(mockRepo, cache.NewLRU(10), time.Minute)
+	ctx := context.Background()
+
+	renumbered := cachedUser
+	renumbered.Phone = "+919087678599"
+	renumbered.Version = 2
+
+	gomock.InOrder(
+		mockRepo.EXPECT().SearchPhone(gomock.Any(), cachedUser.Phone).Return([]models.Users{cachedUser}, nil),
+		mockRepo.EXPECT().UpdateUser(gomock.Any(), int64(1), gomock.Any(), int64(1)).Return(renumbered, nil),
+		mockRepo.EXPECT().GetUsersByIDs(gomock.Any(), []int64{1}).Return([]models.Users{renumbered}, nil),
+		mockRepo.EXPECT().SearchPhone(gomock.Any(), cachedUser.Phone).Return(nil, nil),
+		mockRepo.EXPECT().DeleteUser(gomock.Any(), int64(1), int64(2)).Return(nil),
+		mockRepo.EXPECT().GetUserByID(gomock.Any(), int64(1)).Return(models.Users{}, errors.New("user doesn't exist")),
+	)
+
+	users, err := u.SearchPhone(ctx, cachedUser.Phone)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Users{cachedUser}, users)
+
+	_, err = u.UpdateUser(ctx, 1, models.User{Phone: renumbered.Phone}, 1)
+	assert.NoError(t, err)
+
+	// The entry of the old number still names user 1, who no longer has it.
+	users, err = u.SearchPhone(ctx, cachedUser.Phone)
+	assert.NoError(t, err)
+	assert.Empty(t, users)
+	users, err = u.SearchPhone(ctx, cachedUser.Phone)
+	assert.NoError(t, err)
+	assert.Empty(t, users)
+
+	assert.NoError(t, u.DeleteUser(ctx, 1, 2))
+	_, err = u.GetUserByID(ctx, 1)
+	assert.Error(t, err)
+}
@@ -0,0 +1,17 @@
+package interfaces
+
+import (
+	"context"
+	"grpc-user-service/pkg/utils/models"
+)
+
+type AuditRepository interface {
+	// Append adds entries to the end of the audit log in one transaction,
+	// assigning their sequence numbers and hashes.
+	Append(ctx context.Context, entries []models.AuditEntry) error
+	// List returns up to limit entries following afterSeq, oldest first.
+	List(ctx context.Context, afterSeq int64, limit int) ([]models.AuditEntry, error)
+	// Query returns up to limit entries matching query that precede the
+	// entry before, newest first. before 0 starts at the newest entry.
+	Query(ctx context.Context, query models.AuditQuery, before int64, limit int) ([]models.AuditEntry, error)
+}
@@ -0,0 +1,10 @@
+package interfaces
+
+// ChangeNotifier tells watchers of the change feed when to look for new
+// changes.
+type ChangeNotifier interface {
+	// Subscribe returns a channel receiving a value whenever changes may have
+	// been committed, and a function ending the subscription. The channel is
+	// closed when the notifier stops.
+	Subscribe() (<-chan struct{}, func())
+}
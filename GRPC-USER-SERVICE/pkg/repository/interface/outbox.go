@@ -0,0 +1,31 @@
+package interfaces
+
+import (
+	"context"
+	"grpc-user-service/pkg/utils/models"
+	"time"
+)
+
+type OutboxRepository interface {
+	// Claim leases up to limit due events for lease and returns them oldest
+	// first. Only the oldest unpublished event of a user is ever due, so the
+	// events of a user are published in order.
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEvent, error)
+	// MarkPublished records that event id has been published.
+	MarkPublished(ctx context.Context, id int64) error
+	// Retry records a failed attempt to publish event id and makes it due
+	// again after delay.
+	Retry(ctx context.Context, id int64, lastErr string, delay time.Duration) error
+	// DeadLetter records a failed attempt to publish event id and gives up on
+	// it, letting the later events of the user through.
+	DeadLetter(ctx context.Context, id int64, lastErr string) error
+	// ListDead returns up to limit dead-lettered events following afterID,
+	// oldest first.
+	ListDead(ctx context.Context, afterID int64, limit int) ([]models.OutboxEvent, error)
+	// Requeue makes dead-lettered event id due again with its attempts reset,
+	// or every dead-lettered event for id 0, and returns how many it found.
+	Requeue(ctx context.Context, id int64) (int64, error)
+	// Prune deletes events published more than olderThan ago and returns how
+	// many it deleted.
+	Prune(ctx context.Context, olderThan time.Duration) (int64, error)
+}
@@ -0,0 +1,20 @@
+package interfaces
+
+import (
+	"context"
+	"database/sql"
+)
+
+// TxOptions configures a transaction started by a TxManager.
+type TxOptions struct {
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+}
+
+// TxManager runs several repository calls as one unit of work. The
+// transaction travels in the context passed to fn, so repository methods
+// called with that context take part in it. Nested calls join the outer
+// transaction.
+type TxManager interface {
+	WithinTx(ctx context.Context, opts TxOptions, fn func(ctx context.Context) error) error
+}
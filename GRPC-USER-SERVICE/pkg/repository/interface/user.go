@@ -1,15 +1,29 @@
 package interfaces
 
-import "grpc-user-service/pkg/utils/models"
+import (
+	"context"
+	"grpc-user-service/pkg/utils/models"
+	"time"
+)
 
 type UserRepository interface {
-	AddUser(user models.User) error
-	CheckUserExistsByPhone(phone string) bool
-	CheckUserAvailabilityWithUserID(Id int64) bool
-	CheckUserAvailabilityWithUserIDs(Id []int64) bool
-	GetUserByID(Id int64) (models.Users, error)
-	GetUsersByIDs(Ids []int64) ([]models.Users, error)
-	SearchCity(city string) ([]models.Users, error)
-	SearchPhone(phone string) ([]models.Users, error)
-	SearchMarried(married bool) ([]models.Users, error)
+	AddUser(ctx context.Context, user models.User) error
+	UpdateUser(ctx context.Context, Id int64, user models.User, version int64) (models.Users, error)
+	DeleteUser(ctx context.Context, Id int64, version int64) error
+	// GetUserHistory returns up to limit changes of user Id older than the
+	// change before, newest first. before 0 starts at the newest change.
+	GetUserHistory(ctx context.Context, Id int64, before int64, limit int) ([]models.UserChange, error)
+	GetUserAsOf(ctx context.Context, Id int64, asOf time.Time) (models.Users, error)
+	// GetChanges returns up to limit changes following after in the change
+	// feed, leaving out snapshots. Only changes that no running transaction
+	// can precede are returned, so later calls never find changes before the
+	// ones already returned.
+	GetChanges(ctx context.Context, after models.ChangeCursor, limit int) ([]models.UserChange, error)
+	// GetChangeCursor returns the current end of the change feed.
+	GetChangeCursor(ctx context.Context) (models.ChangeCursor, error)
+	GetUserByID(ctx context.Context, Id int64) (models.Users, error)
+	GetUsersByIDs(ctx context.Context, Ids []int64) ([]models.Users, error)
+	// SearchUsers returns the users matching search, ordered as it asks.
+	SearchUsers(ctx context.Context, search models.SearchUser) ([]models.Users, error)
+	SearchPhone(ctx context.Context, phone string) ([]models.Users, error)
 }
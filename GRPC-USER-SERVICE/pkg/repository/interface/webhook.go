@@ -0,0 +1,44 @@
+package interfaces
+
+import (
+	"context"
+	"grpc-user-service/pkg/utils/models"
+	"time"
+)
+
+type WebhookRepository interface {
+	// CreateSubscription stores sub with the principal of ctx as its creator
+	// and returns it with its id and creation time.
+	CreateSubscription(ctx context.Context, sub models.WebhookSubscription) (models.WebhookSubscription, error)
+	// ListSubscriptions returns up to limit subscriptions older than before,
+	// newest first and without their secrets. before 0 starts at the newest.
+	ListSubscriptions(ctx context.Context, before int64, limit int) ([]models.WebhookSubscription, error)
+	// DeleteSubscription deletes subscription id with its deliveries.
+	DeleteSubscription(ctx context.Context, id int64) error
+	// Enqueue adds a delivery of event with payload for every subscription
+	// to its type and returns how many it added. Enqueuing an event again
+	// adds nothing.
+	Enqueue(ctx context.Context, event models.OutboxEvent, payload []byte) (int64, error)
+	// Claim leases up to limit due deliveries for lease, with the URL and
+	// secret of their subscriptions. Only the oldest pending delivery of a
+	// user to a subscription is ever due, so a subscription receives the
+	// events of a user in order.
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	// MarkDelivered records the attempt that delivered delivery id.
+	MarkDelivered(ctx context.Context, id int64, responseCode int) error
+	// Retry records a failed attempt and makes delivery id due again after
+	// delay.
+	Retry(ctx context.Context, id int64, responseCode int, lastErr string, delay time.Duration) error
+	// Fail records a failed attempt and gives up on delivery id.
+	Fail(ctx context.Context, id int64, responseCode int, lastErr string) error
+	// ListDeliveries returns up to limit deliveries to subscription
+	// subscriptionID older than before, newest first and without payloads.
+	ListDeliveries(ctx context.Context, subscriptionID int64, before int64, limit int) ([]models.WebhookDelivery, error)
+	// Replay makes the deliveries to subscription subscriptionID created at
+	// or after since due again with their attempts reset, only delivery
+	// deliveryID unless it is 0, and returns how many it found.
+	Replay(ctx context.Context, subscriptionID int64, deliveryID int64, since time.Time) (int64, error)
+	// Prune deletes deliveries that settled more than olderThan ago and
+	// returns how many it deleted.
+	Prune(ctx context.Context, olderThan time.Duration) (int64, error)
+}
@@ -0,0 +1,49 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: pkg\repository\interface\notifier.go
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockChangeNotifier is a mock of ChangeNotifier interface.
+type MockChangeNotifier struct {
+	ctrl     *gomock.Controller
+	recorder *MockChangeNotifierMockRecorder
+}
+
+// MockChangeNotifierMockRecorder is the mock recorder for MockChangeNotifier.
+type MockChangeNotifierMockRecorder struct {
+	mock *MockChangeNotifier
+}
+
+// NewMockChangeNotifier creates a new mock instance.
+func NewMockChangeNotifier(ctrl *gomock.Controller) *MockChangeNotifier {
+	mock := &MockChangeNotifier{ctrl: ctrl}
+	mock.recorder = &MockChangeNotifierMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockChangeNotifier) EXPECT() *MockChangeNotifierMockRecorder {
+	return m.recorder
+}
+
+// Subscribe mocks base method.
+func (m *MockChangeNotifier) Subscribe() (<-chan struct{}, func()) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Subscribe")
+	ret0, _ := ret[0].(<-chan struct{})
+	ret1, _ := ret[1].(func())
+	return ret0, ret1
+}
+
+// Subscribe indicates an expected call of Subscribe.
+func (mr *MockChangeNotifierMockRecorder) Subscribe() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subscribe", reflect.TypeOf((*MockChangeNotifier)(nil).Subscribe))
+}
@@ -5,8 +5,10 @@
 package mock
 
 import (
+	context "context"
 	models "grpc-user-service/pkg/utils/models"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -35,132 +37,164 @@ func (m *MockUserRepository) EXPECT() *MockUserRepositoryMockRecorder {
 }
 
 // AddUser mocks base method.
-func (m *MockUserRepository) AddUser(user models.User) error {
+func (m *MockUserRepository) AddUser(ctx context.Context, user models.User) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddUser", user)
+	ret := m.ctrl.Call(m, "AddUser", ctx, user)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // AddUser indicates an expected call of AddUser.
-func (mr *MockUserRepositoryMockRecorder) AddUser(user interface{}) *gomock.Call {
+func (mr *MockUserRepositoryMockRecorder) AddUser(ctx, user interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUser", reflect.TypeOf((*MockUserRepository)(nil).AddUser), user)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUser", reflect.TypeOf((*MockUserRepository)(nil).AddUser), ctx, user)
 }
 
-// CheckUserAvailabilityWithUserID mocks base method.
-func (m *MockUserRepository) CheckUserAvailabilityWithUserID(Id int64) bool {
+// DeleteUser mocks base method.
+func (m *MockUserRepository) DeleteUser(ctx context.Context, Id, version int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CheckUserAvailabilityWithUserID", Id)
-	ret0, _ := ret[0].(bool)
+	ret := m.ctrl.Call(m, "DeleteUser", ctx, Id, version)
+	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// CheckUserAvailabilityWithUserID indicates an expected call of CheckUserAvailabilityWithUserID.
-func (mr *MockUserRepositoryMockRecorder) CheckUserAvailabilityWithUserID(Id interface{}) *gomock.Call {
+// DeleteUser indicates an expected call of DeleteUser.
+func (mr *MockUserRepositoryMockRecorder) DeleteUser(ctx, Id, version interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckUserAvailabilityWithUserID", reflect.TypeOf((*MockUserRepository)(nil).CheckUserAvailabilityWithUserID), Id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockUserRepository)(nil).DeleteUser), ctx, Id, version)
 }
 
-// CheckUserAvailabilityWithUserIDs mocks base method.
-func (m *MockUserRepository) CheckUserAvailabilityWithUserIDs(Id []int64) bool {
+// GetChangeCursor mocks base method.
+func (m *MockUserRepository) GetChangeCursor(ctx context.Context) (models.ChangeCursor, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CheckUserAvailabilityWithUserIDs", Id)
-	ret0, _ := ret[0].(bool)
-	return ret0
+	ret := m.ctrl.Call(m, "GetChangeCursor", ctx)
+	ret0, _ := ret[0].(models.ChangeCursor)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// CheckUserAvailabilityWithUserIDs indicates an expected call of CheckUserAvailabilityWithUserIDs.
-func (mr *MockUserRepositoryMockRecorder) CheckUserAvailabilityWithUserIDs(Id interface{}) *gomock.Call {
+// GetChangeCursor indicates an expected call of GetChangeCursor.
+func (mr *MockUserRepositoryMockRecorder) GetChangeCursor(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckUserAvailabilityWithUserIDs", reflect.TypeOf((*MockUserRepository)(nil).CheckUserAvailabilityWithUserIDs), Id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetChangeCursor", reflect.TypeOf((*MockUserRepository)(nil).GetChangeCursor), ctx)
 }
 
-// CheckUserExistsByPhone mocks base method.
-func (m *MockUserRepository) CheckUserExistsByPhone(phone string) bool {
+// GetChanges mocks base method.
+func (m *MockUserRepository) GetChanges(ctx context.Context, after models.ChangeCursor, limit int) ([]models.UserChange, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CheckUserExistsByPhone", phone)
-	ret0, _ := ret[0].(bool)
-	return ret0
+	ret := m.ctrl.Call(m, "GetChanges", ctx, after, limit)
+	ret0, _ := ret[0].([]models.UserChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetChanges indicates an expected call of GetChanges.
+func (mr *MockUserRepositoryMockRecorder) GetChanges(ctx, after, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetChanges", reflect.TypeOf((*MockUserRepository)(nil).GetChanges), ctx, after, limit)
+}
+
+// GetUserAsOf mocks base method.
+func (m *MockUserRepository) GetUserAsOf(ctx context.Context, Id int64, asOf time.Time) (models.Users, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserAsOf", ctx, Id, asOf)
+	ret0, _ := ret[0].(models.Users)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// CheckUserExistsByPhone indicates an expected call of CheckUserExistsByPhone.
-func (mr *MockUserRepositoryMockRecorder) CheckUserExistsByPhone(phone interface{}) *gomock.Call {
+// GetUserAsOf indicates an expected call of GetUserAsOf.
+func (mr *MockUserRepositoryMockRecorder) GetUserAsOf(ctx, Id, asOf interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckUserExistsByPhone", reflect.TypeOf((*MockUserRepository)(nil).CheckUserExistsByPhone), phone)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserAsOf", reflect.TypeOf((*MockUserRepository)(nil).GetUserAsOf), ctx, Id, asOf)
 }
 
 // GetUserByID mocks base method.
-func (m *MockUserRepository) GetUserByID(Id int64) (models.Users, error) {
+func (m *MockUserRepository) GetUserByID(ctx context.Context, Id int64) (models.Users, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserByID", Id)
+	ret := m.ctrl.Call(m, "GetUserByID", ctx, Id)
 	ret0, _ := ret[0].(models.Users)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetUserByID indicates an expected call of GetUserByID.
-func (mr *MockUserRepositoryMockRecorder) GetUserByID(Id interface{}) *gomock.Call {
+func (mr *MockUserRepositoryMockRecorder) GetUserByID(ctx, Id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockUserRepository)(nil).GetUserByID), Id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockUserRepository)(nil).GetUserByID), ctx, Id)
+}
+
+// GetUserHistory mocks base method.
+func (m *MockUserRepository) GetUserHistory(ctx context.Context, Id, before int64, limit int) ([]models.UserChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserHistory", ctx, Id, before, limit)
+	ret0, _ := ret[0].([]models.UserChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserHistory indicates an expected call of GetUserHistory.
+func (mr *MockUserRepositoryMockRecorder) GetUserHistory(ctx, Id, before, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserHistory", reflect.TypeOf((*MockUserRepository)(nil).GetUserHistory), ctx, Id, before, limit)
 }
 
 // GetUsersByIDs mocks base method.
-func (m *MockUserRepository) GetUsersByIDs(Ids []int64) ([]models.Users, error) {
+func (m *MockUserRepository) GetUsersByIDs(ctx context.Context, Ids []int64) ([]models.Users, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUsersByIDs", Ids)
+	ret := m.ctrl.Call(m, "GetUsersByIDs", ctx, Ids)
 	ret0, _ := ret[0].([]models.Users)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetUsersByIDs indicates an expected call of GetUsersByIDs.
-func (mr *MockUserRepositoryMockRecorder) GetUsersByIDs(Ids interface{}) *gomock.Call {
+func (mr *MockUserRepositoryMockRecorder) GetUsersByIDs(ctx, Ids interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsersByIDs", reflect.TypeOf((*MockUserRepository)(nil).GetUsersByIDs), Ids)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsersByIDs", reflect.TypeOf((*MockUserRepository)(nil).GetUsersByIDs), ctx, Ids)
 }
 
-// SearchCity mocks base method.
-func (m *MockUserRepository) SearchCity(city string) ([]models.Users, error) {
+// SearchPhone mocks base method.
+func (m *MockUserRepository) SearchPhone(ctx context.Context, phone string) ([]models.Users, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SearchCity", city)
+	ret := m.ctrl.Call(m, "SearchPhone", ctx, phone)
 	ret0, _ := ret[0].([]models.Users)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// SearchCity indicates an expected call of SearchCity.
-func (mr *MockUserRepositoryMockRecorder) SearchCity(city interface{}) *gomock.Call {
+// SearchPhone indicates an expected call of SearchPhone.
+func (mr *MockUserRepositoryMockRecorder) SearchPhone(ctx, phone interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchCity", reflect.TypeOf((*MockUserRepository)(nil).SearchCity), city)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchPhone", reflect.TypeOf((*MockUserRepository)(nil).SearchPhone), ctx, phone)
 }
 
-// SearchMarried mocks base method.
-func (m *MockUserRepository) SearchMarried(married bool) ([]models.Users, error) {
+// SearchUsers mocks base method.
+func (m *MockUserRepository) SearchUsers(ctx context.Context, search models.SearchUser) ([]models.Users, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SearchMarried", married)
+	ret := m.ctrl.Call(m, "SearchUsers", ctx, search)
 	ret0, _ := ret[0].([]models.Users)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// SearchMarried indicates an expected call of SearchMarried.
-func (mr *MockUserRepositoryMockRecorder) SearchMarried(married interface{}) *gomock.Call {
+// SearchUsers indicates an expected call of SearchUsers.
+func (mr *MockUserRepositoryMockRecorder) SearchUsers(ctx, search interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchMarried", reflect.TypeOf((*MockUserRepository)(nil).SearchMarried), married)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchUsers", reflect.TypeOf((*MockUserRepository)(nil).SearchUsers), ctx, search)
 }
 
-// SearchPhone mocks base method.
-func (m *MockUserRepository) SearchPhone(phone string) ([]models.Users, error) {
+// UpdateUser mocks base method.
+func (m *MockUserRepository) UpdateUser(ctx context.Context, Id int64, user models.User, version int64) (models.Users, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SearchPhone", phone)
-	ret0, _ := ret[0].([]models.Users)
+	ret := m.ctrl.Call(m, "UpdateUser", ctx, Id, user, version)
+	ret0, _ := ret[0].(models.Users)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// SearchPhone indicates an expected call of SearchPhone.
-func (mr *MockUserRepositoryMockRecorder) SearchPhone(phone interface{}) *gomock.Call {
+// UpdateUser indicates an expected call of UpdateUser.
+func (mr *MockUserRepositoryMockRecorder) UpdateUser(ctx, Id, user, version interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchPhone", reflect.TypeOf((*MockUserRepository)(nil).SearchPhone), phone)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockUserRepository)(nil).UpdateUser), ctx, Id, user, version)
 }
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"grpc-user-service/pkg/db"
+	"grpc-user-service/pkg/metrics"
+	interfaces "grpc-user-service/pkg/repository/interface"
+	"grpc-user-service/pkg/utils/models"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const outboxColumns = `id, event_type, data, actor, created_at, attempts, last_error`
+
+type outboxRepository struct {
+	DB           *gorm.DB
+	queryTimeout time.Duration
+}
+
+// NewOutboxRepository returns the Postgres outbox. Every query is bounded by
+// queryTimeout, 0 leaves only the caller's deadline.
+func NewOutboxRepository(DB *gorm.DB, queryTimeout time.Duration) interfaces.OutboxRepository {
+	return &outboxRepository{
+		DB:           DB,
+		queryTimeout: queryTimeout,
+	}
+}
+
+// outboxRow is a row of outbox, data being the JSON form of the users row.
+type outboxRow struct {
+	ID        int64
+	EventType string
+	Data      []byte
+	Actor     string
+	CreatedAt time.Time
+	Attempts  int
+	LastError string
+}
+
+func (r outboxRow) event() (models.OutboxEvent, error) {
+	user, err := decodeUser(r.Data)
+	if err != nil {
+		return models.OutboxEvent{}, err
+	}
+	event := models.OutboxEvent{
+		ID:         r.ID,
+		Type:       r.EventType,
+		Actor:      r.Actor,
+		OccurredAt: r.CreatedAt,
+		Attempts:   r.Attempts,
+		LastError:  r.LastError,
+	}
+	if user != nil {
+		event.User = *user
+	}
+	return event, nil
+}
+
+func (o *outboxRepository) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, o.queryTimeout)
+}
+
+// Claim takes the due events with SKIP LOCKED, so that relays running at the
+// same time claim different events. The lease is measured on the database
+// clock, like every other time of the outbox.
+func (o *outboxRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEvent, error) {
+	defer metrics.ObserveQuery("OutboxClaim", time.Now())
+	ctx, cancel := o.withTimeout(ctx)
+	defer cancel()
+	var rows []outboxRow
+	err := o.DB.WithContext(ctx).Raw(`UPDATE outbox SET next_attempt_at = now() + $2::float8 * interval '1 second'
+	WHERE id IN (
+		SELECT id FROM outbox o WHERE published_at IS NULL AND dead_at IS NULL AND next_attempt_at <= now()
+		AND NOT EXISTS (SELECT 1 FROM outbox p WHERE p.user_id = o.user_id AND p.id < o.id AND p.published_at IS NULL AND p.dead_at IS NULL)
+		ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED
+	)
+	RETURNING `+outboxColumns, limit, lease.Seconds()).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	events, err := outboxEvents(rows)
+	if err != nil {
+		return nil, err
+	}
+	// RETURNING follows no particular order.
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events, nil
+}
+
+func (o *outboxRepository) MarkPublished(ctx context.Context, id int64) error {
+	defer metrics.ObserveQuery("OutboxMarkPublished", time.Now())
+	ctx, cancel := o.withTimeout(ctx)
+	defer cancel()
+	return o.DB.WithContext(ctx).Exec(`UPDATE outbox SET published_at = now(), attempts = attempts + 1, last_error = '' WHERE id = $1`, id).Error
+}
+
+func (o *outboxRepository) Retry(ctx context.Context, id int64, lastErr string, delay time.Duration) error {
+	defer metrics.ObserveQuery("OutboxRetry", time.Now())
+	ctx, cancel := o.withTimeout(ctx)
+	defer cancel()
+	return o.DB.WithContext(ctx).Exec(`UPDATE outbox SET attempts = attempts + 1, last_error = $2, next_attempt_at = now() + $3::float8 * interval '1 second' WHERE id = $1`,
+		id, lastErr, delay.Seconds()).Error
+}
+
+func (o *outboxRepository) DeadLetter(ctx context.Context, id int64, lastErr string) error {
+	defer metrics.ObserveQuery("OutboxDeadLetter", time.Now())
+	ctx, cancel := o.withTimeout(ctx)
+	defer cancel()
+	return o.DB.WithContext(ctx).Exec(`UPDATE outbox SET attempts = attempts + 1, last_error = $2, dead_at = now() WHERE id = $1`, id, lastErr).Error
+}
+
+func (o *outboxRepository) ListDead(ctx context.Context, afterID int64, limit int) ([]models.OutboxEvent, error) {
+	defer metrics.ObserveQuery("OutboxListDead", time.Now())
+	ctx, cancel := o.withTimeout(ctx)
+	defer cancel()
+	var rows []outboxRow
+	err := o.DB.WithContext(db.WithPrimary(ctx)).Raw(`SELECT `+outboxColumns+` FROM outbox WHERE dead_at IS NOT NULL AND id > $1 ORDER BY id LIMIT $2`, afterID, limit).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return outboxEvents(rows)
+}
+
+func (o *outboxRepository) Requeue(ctx context.Context, id int64) (int64, error) {
+	defer metrics.ObserveQuery("OutboxRequeue", time.Now())
+	ctx, cancel := o.withTimeout(ctx)
+	defer cancel()
+	result := o.DB.WithContext(ctx).Exec(`UPDATE outbox SET dead_at = NULL, attempts = 0, last_error = '', next_attempt_at = now() WHERE dead_at IS NOT NULL AND ($1::bigint = 0 OR id = $1::bigint)`, id)
+	return result.RowsAffected, result.Error
+}
+
+func (o *outboxRepository) Prune(ctx context.Context, olderThan time.Duration) (int64, error) {
+	defer metrics.ObserveQuery("OutboxPrune", time.Now())
+	ctx, cancel := o.withTimeout(ctx)
+	defer cancel()
+	result := o.DB.WithContext(ctx).Exec(`DELETE FROM outbox WHERE published_at < now() - $1::float8 * interval '1 second'`, olderThan.Seconds())
+	return result.RowsAffected, result.Error
+}
+
+func outboxEvents(rows []outboxRow) ([]models.OutboxEvent, error) {
+	events := make([]models.OutboxEvent, 0, len(rows))
+	for _, row := range rows {
+		event, err := row.event()
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"grpc-user-service/pkg/utils/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func newOutboxMock(t *testing.T) (*outboxRepository, sqlmock.Sqlmock) {
+	mockDB, mockSQL, _ := sqlmock.New()
+	t.Cleanup(func() { mockDB.Close() })
+	gormDB, _ := gorm.Open(postgres.New(postgres.Config{
+		Conn: mockDB,
+	}), &gorm.Config{})
+	return NewOutboxRepository(gormDB, 0).(*outboxRepository), mockSQL
+}
+
+func Test_OutboxClaim(t *testing.T) {
+	claimQuery := `(?s)` + regexp.QuoteMeta(`UPDATE outbox SET next_attempt_at = now() + $2::float8 * interval '1 second'`) +
+		`.*` + regexp.QuoteMeta(`ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED`) + `.*` + regexp.QuoteMeta(`RETURNING `+outboxColumns)
+	columns := []string{"id", "event_type", "data", "actor", "created_at", "attempts", "last_error"}
+	createdAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		stub    func(mockSQL sqlmock.Sqlmock)
+		want    []models.OutboxEvent
+		wantErr error
+	}{
+		{
+			name: "oldest first",
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				mockSQL.ExpectQuery(claimQuery).WithArgs(10, 30.0).WillReturnRows(sqlmock.NewRows(columns).
+					AddRow(8, "UserUpdated", []byte(`{"id":2,"fname":"Ravi","version":2}`), "bob", createdAt, 3, "timeout").
+					AddRow(5, "UserCreated", []byte(`{"id":1,"fname":"Akhil","version":1}`), "alice", createdAt, 0, ""))
+			},
+			want: []models.OutboxEvent{
+				{ID: 5, Type: "UserCreated", User: models.Users{ID: 1, Fname: "Akhil", Version: 1}, Actor: "alice", OccurredAt: createdAt},
+				{ID: 8, Type: "UserUpdated", User: models.Users{ID: 2, Fname: "Ravi", Version: 2}, Actor: "bob", OccurredAt: createdAt, Attempts: 3, LastError: "timeout"},
+			},
+		},
+		{
+			name: "nothing due",
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				mockSQL.ExpectQuery(claimQuery).WithArgs(10, 30.0).WillReturnRows(sqlmock.NewRows(columns))
+			},
+			want: []models.OutboxEvent{},
+		},
+		{
+			name: "error",
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				mockSQL.ExpectQuery(claimQuery).WithArgs(10, 30.0).WillReturnError(errors.New("error"))
+			},
+			wantErr: errors.New("error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, mockSQL := newOutboxMock(t)
+			tt.stub(mockSQL)
+
+			events, err := o.Claim(context.Background(), 10, 30*time.Second)
+
+			assert.Equal(t, tt.want, events)
+			assert.Equal(t, tt.wantErr, err)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_OutboxAttempts(t *testing.T) {
+	o, mockSQL := newOutboxMock(t)
+	mockSQL.ExpectExec(regexp.QuoteMeta(`UPDATE outbox SET published_at = now(), attempts = attempts + 1, last_error = '' WHERE id = $1`)).
+		WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mockSQL.ExpectExec(regexp.QuoteMeta(`UPDATE outbox SET attempts = attempts + 1, last_error = $2, next_attempt_at = now() + $3::float8 * interval '1 second' WHERE id = $1`)).
+		WithArgs(6, "timeout", 4.0).WillReturnResult(sqlmock.NewResult(0, 1))
+	mockSQL.ExpectExec(regexp.QuoteMeta(`UPDATE outbox SET attempts = attempts + 1, last_error = $2, dead_at = now() WHERE id = $1`)).
+		WithArgs(7, "rejected").WillReturnResult(sqlmock.NewResult(0, 1))
+	mockSQL.ExpectExec(regexp.QuoteMeta(`UPDATE outbox SET dead_at = NULL, attempts = 0, last_error = '', next_attempt_at = now() WHERE dead_at IS NOT NULL AND ($1::bigint = 0 OR id = $1::bigint)`)).
+		WithArgs(0).WillReturnResult(sqlmock.NewResult(0, 3))
+	mockSQL.ExpectExec(regexp.QuoteMeta(`DELETE FROM outbox WHERE published_at < now() - $1::float8 * interval '1 second'`)).
+		WithArgs(3600.0).WillReturnResult(sqlmock.NewResult(0, 2))
+	ctx := context.Background()
+
+	assert.NoError(t, o.MarkPublished(ctx, 5))
+	assert.NoError(t, o.Retry(ctx, 6, "timeout", 4*time.Second))
+	assert.NoError(t, o.DeadLetter(ctx, 7, "rejected"))
+	requeued, err := o.Requeue(ctx, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), requeued)
+	pruned, err := o.Prune(ctx, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), pruned)
+	assert.NoError(t, mockSQL.ExpectationsWereMet())
+}
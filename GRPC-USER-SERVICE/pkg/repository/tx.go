@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"grpc-user-service/pkg/db"
+	interfaces "grpc-user-service/pkg/repository/interface"
+	"log/slog"
+	"math/rand"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+const (
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+)
+
+type txKey struct{}
+
+type txManager struct {
+	DB         *gorm.DB
+	maxRetries int
+	backoff    time.Duration
+}
+
+// NewTxManager returns a transaction manager for DB. Transactions failing
+// with a serialization failure or a deadlock are retried up to maxRetries
+// times with a jittered exponential backoff.
+func NewTxManager(DB *gorm.DB, maxRetries int) interfaces.TxManager {
+	return &txManager{
+		DB:         DB,
+		maxRetries: maxRetries,
+		backoff:    10 * time.Millisecond,
+	}
+}
+
+func (m *txManager) WithinTx(ctx context.Context, opts interfaces.TxOptions, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+
+	txOpts := &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly}
+	for attempt := 0; ; attempt++ {
+		err := m.begin(ctx, opts).Transaction(func(tx *gorm.DB) error {
+			return fn(context.WithValue(ctx, txKey{}, tx))
+		}, txOpts)
+		if err == nil || !isRetryable(err) || attempt >= m.maxRetries {
+			return err
+		}
+
+		delay := m.backoff << attempt
+		delay += time.Duration(rand.Int63n(int64(delay)))
+		slog.WarnContext(ctx, "retrying transaction", "attempt", attempt+1, "delay", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// begin returns the database a transaction with opts starts on. Read-only
+// transactions run on a replica when one is healthy; hot standbys do not
+// support serializable isolation, so those stay on the primary.
+func (m *txManager) begin(ctx context.Context, opts interfaces.TxOptions) *gorm.DB {
+	if opts.ReadOnly && opts.Isolation != sql.LevelSerializable {
+		return db.ForRead(ctx, m.DB).WithContext(ctx)
+	}
+	return m.DB.WithContext(ctx)
+}
+
+// conn returns the transaction carried by ctx, or gormDB outside a transaction.
+func conn(ctx context.Context, gormDB *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx
+	}
+	return gormDB
+}
+
+// isRetryable reports whether err aborted a transaction that may succeed
+// when run again.
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == serializationFailure || pgErr.Code == deadlockDetected)
+}
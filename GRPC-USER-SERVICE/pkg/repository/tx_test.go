@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	interfaces "grpc-user-service/pkg/repository/interface"
+	"grpc-user-service/pkg/utils/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func Test_WithinTx(t *testing.T) {
+	insertQuery := regexp.QuoteMeta("INSERT INTO users (fname, city, phone, height, married, created_by, updated_by) VALUES ($1, $2, $3, $4, $5, $6, $6) RETURNING *")
+	phoneQuery := regexp.QuoteMeta("SELECT id, fname, city, phone, height, married, version, created_at, updated_at, created_by, updated_by FROM users WHERE phone=$1")
+	user := models.User{Fname: "Akhil", City: "City", Phone: "+919087678564", Height: 157.6, Married: true}
+
+	tests := []struct {
+		name     string
+		stub     func(mockSQL sqlmock.Sqlmock)
+		fn       func(u interfaces.UserRepository, m interfaces.TxManager) func(ctx context.Context) error
+		wantErr  error
+		attempts int
+	}{
+		{
+			name: "commit",
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				mockSQL.ExpectBegin()
+				mockSQL.ExpectQuery(phoneQuery).WithArgs(user.Phone).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mockSQL.ExpectExec(insertQuery).WillReturnResult(sqlmock.NewResult(1, 1))
+				mockSQL.ExpectCommit()
+			},
+			fn: func(u interfaces.UserRepository, m interfaces.TxManager) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					if _, err := u.SearchPhone(ctx, user.Phone); err != nil {
+						return err
+					}
+					return u.AddUser(ctx, user)
+				}
+			},
+			attempts: 1,
+		},
+		{
+			name: "nested calls join the outer transaction",
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				mockSQL.ExpectBegin()
+				mockSQL.ExpectExec(insertQuery).WillReturnResult(sqlmock.NewResult(1, 1))
+				mockSQL.ExpectCommit()
+			},
+			fn: func(u interfaces.UserRepository, m interfaces.TxManager) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					return m.WithinTx(ctx, interfaces.TxOptions{}, func(ctx context.Context) error {
+						return u.AddUser(ctx, user)
+					})
+				}
+			},
+			attempts: 1,
+		},
+		{
+			name: "retry after serialization failure",
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				mockSQL.ExpectBegin()
+				mockSQL.ExpectExec(insertQuery).WillReturnError(&pgconn.PgError{Code: "40001"})
+				mockSQL.ExpectRollback()
+				mockSQL.ExpectBegin()
+				mockSQL.ExpectExec(insertQuery).WillReturnResult(sqlmock.NewResult(1, 1))
+				mockSQL.ExpectCommit()
+			},
+			fn: func(u interfaces.UserRepository, m interfaces.TxManager) func(ctx context.Context) error {
+				return func(ctx context.Context) error { return u.AddUser(ctx, user) }
+			},
+			attempts: 2,
+		},
+		{
+			name: "retries exhausted",
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				for i := 0; i < 3; i++ {
+					mockSQL.ExpectBegin()
+					mockSQL.ExpectExec(insertQuery).WillReturnError(&pgconn.PgError{Code: "40P01"})
+					mockSQL.ExpectRollback()
+				}
+			},
+			fn: func(u interfaces.UserRepository, m interfaces.TxManager) func(ctx context.Context) error {
+				return func(ctx context.Context) error { return u.AddUser(ctx, user) }
+			},
+			wantErr:  &pgconn.PgError{Code: "40P01"},
+			attempts: 3,
+		},
+		{
+			name: "other errors roll back without retry",
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				mockSQL.ExpectBegin()
+				mockSQL.ExpectExec(insertQuery).WillReturnError(errors.New("error"))
+				mockSQL.ExpectRollback()
+			},
+			fn: func(u interfaces.UserRepository, m interfaces.TxManager) func(ctx context.Context) error {
+				return func(ctx context.Context) error { return u.AddUser(ctx, user) }
+			},
+			wantErr:  errors.New("error"),
+			attempts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockSQL, _ := sqlmock.New()
+			defer mockDB.Close()
+			gormDB, _ := gorm.Open(postgres.New(postgres.Config{
+				Conn: mockDB,
+			}), &gorm.Config{})
+			tt.stub(mockSQL)
+			u := NewUserRepository(gormDB, 0)
+			m := &txManager{DB: gormDB, maxRetries: 2, backoff: time.Millisecond}
+
+			attempts := 0
+			fn := tt.fn(u, m)
+			err := m.WithinTx(context.Background(), interfaces.TxOptions{Isolation: sql.LevelSerializable}, func(ctx context.Context) error {
+				attempts++
+				return fn(ctx)
+			})
+
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.attempts, attempts)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
@@ -1,102 +1,416 @@
 package repository
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"grpc-user-service/pkg/auth"
+	"grpc-user-service/pkg/db"
+	"grpc-user-service/pkg/domain"
+	"grpc-user-service/pkg/metrics"
 	interfaces "grpc-user-service/pkg/repository/interface"
 	"grpc-user-service/pkg/utils/models"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
 )
 
+const (
+	uniqueViolation  = "23505"
+	phoneUniqueIndex = "users_phone_key"
+)
+
 type userRepository struct {
-	DB *gorm.DB
+	DB           *gorm.DB
+	queryTimeout time.Duration
 }
 
-func NewUserRepository(DB *gorm.DB) interfaces.UserRepository {
+// NewUserRepository returns the Postgres user repository. Every query is
+// bounded by queryTimeout, 0 leaves only the caller's deadline.
+func NewUserRepository(DB *gorm.DB, queryTimeout time.Duration) interfaces.UserRepository {
 	return &userRepository{
-		DB: DB,
+		DB:           DB,
+		queryTimeout: queryTimeout,
+	}
+}
+
+// withTimeout derives the context of a single query. A shorter deadline or a
+// cancellation of the incoming RPC still applies.
+func (u *userRepository) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if u.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
 	}
+	return context.WithTimeout(ctx, u.queryTimeout)
 }
 
-func (u *userRepository) GetUserByID(Id int64) (models.Users, error) {
+func (u *userRepository) GetUserByID(ctx context.Context, Id int64) (models.Users, error) {
+	defer metrics.ObserveQuery("GetUserByID", time.Now())
+	ctx, cancel := u.withTimeout(ctx)
+	defer cancel()
 	var user models.Users
-	err := u.DB.Raw(`SELECT id, fname, city, phone, height, married FROM users WHERE id=$1`, Id).Scan(&user).Error
-	if err != nil {
-		return models.Users{}, err
+	result := conn(ctx, u.DB).WithContext(ctx).Raw(`SELECT id, fname, city, phone, height, married, version, created_at, updated_at, created_by, updated_by FROM users WHERE id=$1`, Id).Scan(&user)
+	if result.Error != nil {
+		return models.Users{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return models.Users{}, domain.ErrUserNotFound
 	}
 	return user, nil
 }
-func (u *userRepository) GetUsersByIDs(Ids []int64) ([]models.Users, error) {
-	var users []models.Users
+
+// GetUsersByIDs loads the users with the given ids in a single query. Users
+// are returned once each in the order their ids were requested; ids without a
+// user are skipped.
+func (u *userRepository) GetUsersByIDs(ctx context.Context, Ids []int64) ([]models.Users, error) {
+	defer metrics.ObserveQuery("GetUsersByIDs", time.Now())
+	ctx, cancel := u.withTimeout(ctx)
+	defer cancel()
+	var rows []models.Users
+	err := conn(ctx, u.DB).WithContext(ctx).Raw(`SELECT id, fname, city, phone, height, married, version, created_at, updated_at, created_by, updated_by FROM users WHERE id = ANY($1::bigint[])`, int64Array(Ids)).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]models.Users, len(rows))
+	for _, user := range rows {
+		byID[user.ID] = user
+	}
+	users := make([]models.Users, 0, len(rows))
 	for _, id := range Ids {
-		var user models.Users
-		err := u.DB.Raw(`SELECT id, fname, city, phone, height, married FROM users WHERE id=$1`, id).Scan(&user).Error
-		if err == nil {
+		if user, ok := byID[id]; ok {
 			users = append(users, user)
+			delete(byID, id)
 		}
 	}
 	return users, nil
 }
 
-func (u *userRepository) SearchCity(city string) ([]models.Users, error) {
-	var users []models.Users
-	err := u.DB.Raw(`SELECT id, fname, city, phone, height, married FROM users WHERE city ILIKE '%' || $1 || '%'`, city).Scan(&users).Error
-	if err != nil {
-		return []models.Users{}, err
-	}
-	return users, nil
+// searchOrder maps the sort orders of SearchUsers to their column.
+var searchOrder = map[models.SortBy]string{
+	models.SortByID:        "id",
+	models.SortByCreatedAt: "created_at",
+	models.SortByUpdatedAt: "updated_at",
 }
-func (u *userRepository) SearchPhone(phone string) ([]models.Users, error) {
+
+// SearchUsers returns the users matching any of the city, phone and married
+// criteria of search that are set, or all users when none is, within its
+// time bounds and in its order. search.Phone must already be normalized.
+func (u *userRepository) SearchUsers(ctx context.Context, search models.SearchUser) ([]models.Users, error) {
+	defer metrics.ObserveQuery("SearchUsers", time.Now())
+	ctx, cancel := u.withTimeout(ctx)
+	defer cancel()
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+	var criteria, where []string
+	if search.City != "" {
+		criteria = append(criteria, "city ILIKE '%' || "+arg(search.City)+" || '%'")
+	}
+	if search.Phone != "" {
+		criteria = append(criteria, "phone = "+arg(search.Phone))
+	}
+	if search.Married != nil {
+		criteria = append(criteria, "married = "+arg(*search.Married))
+	}
+	if len(criteria) > 0 {
+		where = append(where, "("+strings.Join(criteria, " OR ")+")")
+	}
+	bounds := []struct {
+		condition string
+		t         time.Time
+	}{
+		{"created_at >= ", search.CreatedAfter},
+		{"created_at < ", search.CreatedBefore},
+		{"updated_at >= ", search.UpdatedAfter},
+		{"updated_at < ", search.UpdatedBefore},
+	}
+	for _, b := range bounds {
+		if !b.t.IsZero() {
+			where = append(where, b.condition+arg(b.t))
+		}
+	}
+
+	query := `SELECT id, fname, city, phone, height, married, version, created_at, updated_at, created_by, updated_by FROM users`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	column, ok := searchOrder[search.SortBy]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort order %d", search.SortBy)
+	}
+	direction := " ASC"
+	if search.Descending {
+		direction = " DESC"
+	}
+	query += " ORDER BY " + column + direction
+	if column != "id" {
+		query += ", id" + direction
+	}
+
 	var users []models.Users
-	err := u.DB.Raw(`SELECT id, fname, city, phone, height, married FROM users WHERE phone=$1`, phone).Scan(&users).Error
-	if err != nil {
-		return []models.Users{}, err
+	if err := conn(ctx, u.DB).WithContext(ctx).Raw(query, args...).Scan(&users).Error; err != nil {
+		return nil, err
 	}
 	return users, nil
 }
 
-func (u *userRepository) SearchMarried(married bool) ([]models.Users, error) {
+func (u *userRepository) SearchPhone(ctx context.Context, phone string) ([]models.Users, error) {
+	defer metrics.ObserveQuery("SearchPhone", time.Now())
+	ctx, cancel := u.withTimeout(ctx)
+	defer cancel()
 	var users []models.Users
-	err := u.DB.Raw(`SELECT id, fname, city, phone, height, married FROM users WHERE married=$1`, married).Scan(&users).Error
+	err := conn(ctx, u.DB).WithContext(ctx).Raw(`SELECT id, fname, city, phone, height, married, version, created_at, updated_at, created_by, updated_by FROM users WHERE phone=$1`, phone).Scan(&users).Error
 	if err != nil {
 		return []models.Users{}, err
 	}
 	return users, nil
 }
 
-func (u *userRepository) AddUser(user models.User) error {
-	err := u.DB.Exec(`INSERT INTO users (fname, city, phone, height, married) VALUES ($1, $2, $3, $4, $5)`,
-		user.Fname, user.City, user.Phone, user.Height, user.Married).Error
+// AddUser inserts user with the principal of ctx as its creator, adding the
+// new row to the user's history and a UserCreated event to the outbox.
+func (u *userRepository) AddUser(ctx context.Context, user models.User) error {
+	defer metrics.ObserveQuery("AddUser", time.Now())
+	ctx, cancel := u.withTimeout(ctx)
+	defer cancel()
+	err := conn(ctx, u.DB).WithContext(ctx).Exec(`WITH inserted AS (
+		INSERT INTO users (fname, city, phone, height, married, created_by, updated_by) VALUES ($1, $2, $3, $4, $5, $6, $6) RETURNING *
+	), history AS (
+		INSERT INTO user_history (user_id, operation, new_values, actor) SELECT id, 'insert', to_jsonb(inserted), $6 FROM inserted
+	)
+	INSERT INTO outbox (event_type, user_id, data, actor) SELECT 'UserCreated', id, to_jsonb(inserted), $6 FROM inserted`,
+		user.Fname, user.City, user.Phone, user.Height, user.Married, auth.Principal(ctx)).Error
+	if isUniqueViolation(err, phoneUniqueIndex) {
+		return domain.ErrUserAlreadyExists
+	}
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (ur *userRepository) CheckUserExistsByPhone(phone string) bool {
-	var count int
-	if err := ur.DB.Raw("SELECT count(*) FROM users WHERE phone = ?", phone).Scan(&count).Error; err != nil {
-		return false
+// UpdateUser replaces the fields of user Id if it is still at version and
+// returns the stored user with its new version. The principal of ctx is
+// recorded as the last writer, the change is added to the user's history and
+// a UserUpdated event to the outbox.
+func (u *userRepository) UpdateUser(ctx context.Context, Id int64, user models.User, version int64) (models.Users, error) {
+	defer metrics.ObserveQuery("UpdateUser", time.Now())
+	ctx, cancel := u.withTimeout(ctx)
+	defer cancel()
+	var updated models.Users
+	result := conn(ctx, u.DB).WithContext(ctx).Raw(`WITH old AS (
+		SELECT * FROM users WHERE id=$7 AND version=$8 FOR UPDATE
+	), updated AS (
+		UPDATE users SET fname=$1, city=$2, phone=$3, height=$4, married=$5, version=users.version+1, updated_at=now(), updated_by=$6
+		FROM old WHERE users.id = old.id RETURNING users.*
+	), history AS (
+		INSERT INTO user_history (user_id, operation, old_values, new_values, actor)
+		SELECT updated.id, 'update', to_jsonb(old), to_jsonb(updated), $6 FROM old JOIN updated ON updated.id = old.id
+	), event AS (
+		INSERT INTO outbox (event_type, user_id, data, actor) SELECT 'UserUpdated', id, to_jsonb(updated), $6 FROM updated
+	)
+	SELECT id, fname, city, phone, height, married, version, created_at, updated_at, created_by, updated_by FROM updated`,
+		user.Fname, user.City, user.Phone, user.Height, user.Married, auth.Principal(ctx), Id, version).Scan(&updated)
+	if isUniqueViolation(result.Error, phoneUniqueIndex) {
+		return models.Users{}, domain.ErrUserAlreadyExists
+	}
+	if result.Error != nil {
+		return models.Users{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return models.Users{}, u.writeConflict(ctx, Id)
 	}
-	return count > 0
+	return updated, nil
 }
 
-func (ur *userRepository) CheckUserAvailabilityWithUserID(Id int64) bool {
-	var count int
-	if err := ur.DB.Raw("SELECT count(*) FROM users WHERE id = ?", Id).Scan(&count).Error; err != nil {
-		return false
+// DeleteUser deletes user Id if it is still at version, adds the deleted row
+// to the user's history and a UserDeleted event to the outbox.
+func (u *userRepository) DeleteUser(ctx context.Context, Id int64, version int64) error {
+	defer metrics.ObserveQuery("DeleteUser", time.Now())
+	ctx, cancel := u.withTimeout(ctx)
+	defer cancel()
+	result := conn(ctx, u.DB).WithContext(ctx).Exec(`WITH deleted AS (
+		DELETE FROM users WHERE id=$1 AND version=$2 RETURNING *
+	), history AS (
+		INSERT INTO user_history (user_id, operation, old_values, actor) SELECT id, 'delete', to_jsonb(deleted), $3 FROM deleted
+	)
+	INSERT INTO outbox (event_type, user_id, data, actor) SELECT 'UserDeleted', id, to_jsonb(deleted), $3 FROM deleted`,
+		Id, version, auth.Principal(ctx))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return u.writeConflict(ctx, Id)
+	}
+	return nil
+}
+
+// historyRow is a row of user_history, the values being the JSON form of the
+// users row.
+type historyRow struct {
+	ID        int64
+	UserID    int64
+	Operation string
+	OldValues []byte
+	NewValues []byte
+	Actor     string
+	ChangedAt time.Time
+	// TxID is the xid8 of the writing transaction as text, selected by the
+	// change feed only.
+	TxID string
+}
+
+func (h historyRow) change() (models.UserChange, error) {
+	change := models.UserChange{ID: h.ID, UserID: h.UserID, Operation: h.Operation, Actor: h.Actor, ChangedAt: h.ChangedAt}
+	var err error
+	if h.TxID != "" {
+		if change.TxID, err = strconv.ParseUint(h.TxID, 10, 64); err != nil {
+			return models.UserChange{}, fmt.Errorf("decoding user history: %w", err)
+		}
+	}
+	if change.Old, err = decodeUser(h.OldValues); err != nil {
+		return models.UserChange{}, err
+	}
+	if change.New, err = decodeUser(h.NewValues); err != nil {
+		return models.UserChange{}, err
+	}
+	return change, nil
+}
+
+func decodeUser(values []byte) (*models.Users, error) {
+	if values == nil {
+		return nil, nil
+	}
+	var user models.Users
+	if err := json.Unmarshal(values, &user); err != nil {
+		return nil, fmt.Errorf("decoding user history: %w", err)
 	}
-	return count > 0
+	return &user, nil
 }
 
-func (u *userRepository) CheckUserAvailabilityWithUserIDs(ids []int64) bool {
-	for _, id := range ids {
-		var count int
-		if err := u.DB.Raw("SELECT count(*) FROM users WHERE id = ?", id).Scan(&count).Error; err != nil {
-			return false
+func (u *userRepository) GetUserHistory(ctx context.Context, Id int64, before int64, limit int) ([]models.UserChange, error) {
+	defer metrics.ObserveQuery("GetUserHistory", time.Now())
+	ctx, cancel := u.withTimeout(ctx)
+	defer cancel()
+	var rows []historyRow
+	err := conn(ctx, u.DB).WithContext(ctx).Raw(`SELECT id, user_id, operation, old_values, new_values, actor, changed_at FROM user_history WHERE user_id=$1 AND ($2::bigint = 0 OR id < $2::bigint) ORDER BY id DESC LIMIT $3`,
+		Id, before, limit).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]models.UserChange, 0, len(rows))
+	for _, row := range rows {
+		change, err := row.change()
+		if err != nil {
+			return nil, err
 		}
-		if count == 0 {
-			return false
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// GetUserAsOf returns user Id as it was at asOf, from the last change made
+// at or before then.
+func (u *userRepository) GetUserAsOf(ctx context.Context, Id int64, asOf time.Time) (models.Users, error) {
+	defer metrics.ObserveQuery("GetUserAsOf", time.Now())
+	ctx, cancel := u.withTimeout(ctx)
+	defer cancel()
+	var row historyRow
+	result := conn(ctx, u.DB).WithContext(ctx).Raw(`SELECT id, user_id, operation, old_values, new_values, actor, changed_at FROM user_history WHERE user_id=$1 AND changed_at <= $2 ORDER BY changed_at DESC, id DESC LIMIT 1`,
+		Id, asOf).Scan(&row)
+	if result.Error != nil {
+		return models.Users{}, result.Error
+	}
+	if result.RowsAffected == 0 || row.Operation == models.ChangeDelete {
+		return models.Users{}, domain.ErrUserNotFound
+	}
+	change, err := row.change()
+	if err != nil {
+		return models.Users{}, err
+	}
+	return *change.New, nil
+}
+
+// UserChangesChannel is notified by migration 0009 whenever user history is
+// written.
+const UserChangesChannel = "user_changes"
+
+// GetChanges reads the change feed from the primary. The feed is ordered by
+// transaction id because ids are drawn before commit: a change with a lower id
+// may commit after one with a higher id. Every transaction that has not
+// committed yet is at least as new as the oldest running transaction (the
+// snapshot's xmin), so reading only changes of older transactions means no
+// change can turn up behind the cursor later.
+func (u *userRepository) GetChanges(ctx context.Context, after models.ChangeCursor, limit int) ([]models.UserChange, error) {
+	defer metrics.ObserveQuery("GetChanges", time.Now())
+	ctx, cancel := u.withTimeout(ctx)
+	defer cancel()
+	var rows []historyRow
+	err := conn(ctx, u.DB).WithContext(db.WithPrimary(ctx)).Raw(`SELECT id, user_id, operation, old_values, new_values, actor, changed_at, txid::text AS tx_id FROM user_history WHERE (txid, id) > ($1::text::xid8, $2) AND txid < pg_snapshot_xmin(pg_current_snapshot()) AND operation <> 'snapshot' ORDER BY txid, id LIMIT $3`,
+		strconv.FormatUint(after.TxID, 10), after.ID, limit).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]models.UserChange, 0, len(rows))
+	for _, row := range rows {
+		change, err := row.change()
+		if err != nil {
+			return nil, err
 		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// GetChangeCursor returns the position before the changes of the oldest
+// running transaction. Changes committed by newer transactions that have
+// already finished lie after it too, so a feed started here may begin with
+// changes made just before.
+func (u *userRepository) GetChangeCursor(ctx context.Context) (models.ChangeCursor, error) {
+	ctx, cancel := u.withTimeout(ctx)
+	defer cancel()
+	var xmin string
+	err := conn(ctx, u.DB).WithContext(db.WithPrimary(ctx)).Raw(`SELECT pg_snapshot_xmin(pg_current_snapshot())::text`).Scan(&xmin).Error
+	if err != nil {
+		return models.ChangeCursor{}, err
+	}
+	txID, err := strconv.ParseUint(xmin, 10, 64)
+	if err != nil {
+		return models.ChangeCursor{}, err
+	}
+	return models.ChangeCursor{TxID: txID}, nil
+}
+
+// writeConflict explains why a versioned write of user Id matched no row:
+// the user is gone, or it is at another version.
+func (u *userRepository) writeConflict(ctx context.Context, Id int64) error {
+	var count int
+	err := conn(ctx, u.DB).WithContext(db.WithPrimary(ctx)).Raw(`SELECT count(*) FROM users WHERE id=$1`, Id).Scan(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return domain.ErrUserNotFound
 	}
-	return true
+	return domain.ErrVersionMismatch
+}
+
+// int64Array formats ids as a Postgres array literal.
+func int64Array(ids []int64) string {
+	elems := make([]string, len(ids))
+	for i, id := range ids {
+		elems[i] = strconv.FormatInt(id, 10)
+	}
+	return "{" + strings.Join(elems, ",") + "}"
+}
+
+// isUniqueViolation reports whether err is a Postgres unique violation of
+// the given constraint or index.
+func isUniqueViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation && pgErr.ConstraintName == constraint
 }
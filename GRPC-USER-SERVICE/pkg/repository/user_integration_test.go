@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+
+	"grpc-user-service/pkg/db"
+	"grpc-user-service/pkg/domain"
+	"grpc-user-service/pkg/utils/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// openTestDB connects to the database named by TEST_DATABASE_DSN and applies
+// the migrations. Tests using it are skipped when the variable is not set.
+func openTestDB(t *testing.T) *gorm.DB {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+	gormDB, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	migrator, err := db.NewMigrator(gormDB, "IN")
+	require.NoError(t, err)
+	require.NoError(t, migrator.Up(context.Background()))
+	return gormDB
+}
+
+func Test_AddUserConcurrentSamePhone(t *testing.T) {
+	gormDB := openTestDB(t)
+	const phone = "+919000000036"
+	cleanup := func() { gormDB.Exec(`DELETE FROM users WHERE phone=$1`, phone) }
+	cleanup()
+	t.Cleanup(cleanup)
+
+	u := NewUserRepository(gormDB, 0)
+	const callers = 20
+	errs := make([]error, callers)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			errs[i] = u.AddUser(context.Background(), models.User{Fname: "Akhil", City: "Kannur", Phone: phone, Height: 170, Married: true})
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		assert.True(t, errors.Is(err, domain.ErrUserAlreadyExists), "unexpected error %v", err)
+	}
+	assert.Equal(t, 1, succeeded)
+
+	var count int
+	require.NoError(t, gormDB.Raw(`SELECT count(*) FROM users WHERE phone=$1`, phone).Scan(&count).Error)
+	assert.Equal(t, 1, count)
+}
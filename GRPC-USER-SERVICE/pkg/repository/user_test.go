@@ -1,13 +1,19 @@
 package repository
 
 import (
+	"context"
+	"database/sql/driver"
 	"errors"
 	"regexp"
 	"testing"
+	"time"
 
+	"grpc-user-service/pkg/auth"
+	"grpc-user-service/pkg/domain"
 	"grpc-user-service/pkg/utils/models"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -25,7 +31,7 @@ func Test_GetUserByID(t *testing.T) {
 			name: "success",
 			args: 1,
 			stub: func(mockSQL sqlmock.Sqlmock) {
-				mockSQL.ExpectQuery(`SELECT id, fname, city, phone, height, married FROM users WHERE id=\$1`).
+				mockSQL.ExpectQuery(`SELECT id, fname, city, phone, height, married, version, created_at, updated_at, created_by, updated_by FROM users WHERE id=\$1`).
 					WithArgs(1).
 					WillReturnRows(sqlmock.NewRows([]string{"id", "fname", "city", "phone", "height", "married"}).
 						AddRow(1, "akhil", "bangalore", "9087678564", 157.9, true))
@@ -44,13 +50,24 @@ func Test_GetUserByID(t *testing.T) {
 			name: "error",
 			args: 1,
 			stub: func(mockSQL sqlmock.Sqlmock) {
-				mockSQL.ExpectQuery(`SELECT id, fname, city, phone, height, married FROM users WHERE id=\$1`).
+				mockSQL.ExpectQuery(`SELECT id, fname, city, phone, height, married, version, created_at, updated_at, created_by, updated_by FROM users WHERE id=\$1`).
 					WithArgs(1).
 					WillReturnError(errors.New("error"))
 			},
 			want:    models.Users{},
 			wantErr: errors.New("error"),
 		},
+		{
+			name: "not found",
+			args: 2,
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				mockSQL.ExpectQuery(`SELECT id, fname, city, phone, height, married, version, created_at, updated_at, created_by, updated_by FROM users WHERE id=\$1`).
+					WithArgs(2).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "fname", "city", "phone", "height", "married"}))
+			},
+			want:    models.Users{},
+			wantErr: domain.ErrUserNotFound,
+		},
 	}
 
 	for _, tt := range tests {
@@ -61,9 +78,9 @@ func Test_GetUserByID(t *testing.T) {
 				Conn: mockDB,
 			}), &gorm.Config{})
 			tt.stub(mockSQL)
-			u := NewUserRepository(gormDB)
+			u := NewUserRepository(gormDB, 0)
 
-			result, err := u.GetUserByID(tt.args)
+			result, err := u.GetUserByID(context.Background(), tt.args)
 
 			assert.Equal(t, tt.want, result)
 			assert.Equal(t, tt.wantErr, err)
@@ -72,6 +89,7 @@ func Test_GetUserByID(t *testing.T) {
 }
 
 func Test_GetUsersByIDs(t *testing.T) {
+	expectQuery := regexp.QuoteMeta(`SELECT id, fname, city, phone, height, married, version, created_at, updated_at, created_by, updated_by FROM users WHERE id = ANY($1::bigint[])`)
 	tests := []struct {
 		name    string
 		args    []int64
@@ -80,27 +98,16 @@ func Test_GetUsersByIDs(t *testing.T) {
 		wantErr error
 	}{
 		{
-			name: "success",
-			args: []int64{1, 2},
+			name: "success in request order",
+			args: []int64{2, 1},
 			stub: func(mockSQL sqlmock.Sqlmock) {
-				mockSQL.ExpectQuery(`SELECT id, fname, city, phone, height, married FROM users WHERE id=\$1`).
-					WithArgs(1).
-					WillReturnRows(sqlmock.NewRows([]string{"id", "fname", "city", "phone", "height", "married"}).
-						AddRow(1, "akhil", "bangalore", "9087678564", 165.9, true))
-				mockSQL.ExpectQuery(`SELECT id, fname, city, phone, height, married FROM users WHERE id=\$1`).
-					WithArgs(2).
+				mockSQL.ExpectQuery(expectQuery).
+					WithArgs("{2,1}").
 					WillReturnRows(sqlmock.NewRows([]string{"id", "fname", "city", "phone", "height", "married"}).
+						AddRow(1, "akhil", "bangalore", "9087678564", 165.9, true).
 						AddRow(2, "rahul", "mumbai", "9076543210", 157.8, false))
 			},
 			want: []models.Users{
-				{
-					ID:      1,
-					Fname:   "akhil",
-					City:    "bangalore",
-					Phone:   "9087678564",
-					Height:  165.9,
-					Married: true,
-				},
 				{
 					ID:      2,
 					Fname:   "rahul",
@@ -109,20 +116,25 @@ func Test_GetUsersByIDs(t *testing.T) {
 					Height:  157.8,
 					Married: false,
 				},
+				{
+					ID:      1,
+					Fname:   "akhil",
+					City:    "bangalore",
+					Phone:   "9087678564",
+					Height:  165.9,
+					Married: true,
+				},
 			},
 			wantErr: nil,
 		},
 		{
-			name: "partial success",
-			args: []int64{1, 3},
+			name: "missing and duplicate ids",
+			args: []int64{1, 3, 1},
 			stub: func(mockSQL sqlmock.Sqlmock) {
-				mockSQL.ExpectQuery(`SELECT id, fname, city, phone, height, married FROM users WHERE id=\$1`).
-					WithArgs(1).
+				mockSQL.ExpectQuery(expectQuery).
+					WithArgs("{1,3,1}").
 					WillReturnRows(sqlmock.NewRows([]string{"id", "fname", "city", "phone", "height", "married"}).
 						AddRow(1, "akhil", "bangalore", "9087678564", 157.9, true))
-				mockSQL.ExpectQuery(`SELECT id, fname, city, phone, height, married FROM users WHERE id=\$1`).
-					WithArgs(3).
-					WillReturnError(errors.New("error"))
 			},
 			want: []models.Users{
 				{
@@ -136,6 +148,17 @@ func Test_GetUsersByIDs(t *testing.T) {
 			},
 			wantErr: nil,
 		},
+		{
+			name: "error",
+			args: []int64{1, 3},
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				mockSQL.ExpectQuery(expectQuery).
+					WithArgs("{1,3}").
+					WillReturnError(errors.New("error"))
+			},
+			want:    nil,
+			wantErr: errors.New("error"),
+		},
 	}
 
 	for _, tt := range tests {
@@ -146,52 +169,60 @@ func Test_GetUsersByIDs(t *testing.T) {
 				Conn: mockDB,
 			}), &gorm.Config{})
 			tt.stub(mockSQL)
-			u := NewUserRepository(gormDB)
+			u := NewUserRepository(gormDB, 0)
 
-			result, err := u.GetUsersByIDs(tt.args)
+			result, err := u.GetUsersByIDs(context.Background(), tt.args)
 
 			assert.Equal(t, tt.want, result)
 			assert.Equal(t, tt.wantErr, err)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
 		})
 	}
 }
 
-func Test_SearchCity(t *testing.T) {
+func Test_SearchUsers(t *testing.T) {
+	const columns = `SELECT id, fname, city, phone, height, married, version, created_at, updated_at, created_by, updated_by FROM users`
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC) }
+	married, unmarried := true, false
 	tests := []struct {
-		name    string
-		args    string
-		stub    func(mockSQL sqlmock.Sqlmock)
-		want    []models.Users
-		wantErr error
+		name   string
+		search models.SearchUser
+		query  string
+		args   []driver.Value
 	}{
 		{
-			name: "success",
-			args: "City",
-			stub: func(mockSQL sqlmock.Sqlmock) {
-				expectQuery := `SELECT id, fname, city, phone, height, married FROM users WHERE city ILIKE '%' || \$1 || '%'`
-				mockSQL.ExpectQuery(expectQuery).WithArgs("City").WillReturnRows(sqlmock.NewRows([]string{"id", "fname", "city", "phone", "height", "married"}).AddRow(1, "Akhil", "City", "1234567890", 157.6, true))
-			},
-			want: []models.Users{
-				{
-					ID:      1,
-					Fname:   "Akhil",
-					City:    "City",
-					Phone:   "1234567890",
-					Height:  157.6,
-					Married: true,
-				},
-			},
-			wantErr: nil,
+			name:   "any criterion",
+			search: models.SearchUser{City: "City", Phone: "+919087678564", Married: &married},
+			query:  columns + ` WHERE (city ILIKE '%' || $1 || '%' OR phone = $2 OR married = $3) ORDER BY id ASC`,
+			args:   []driver.Value{"City", "+919087678564", true},
 		},
 		{
-			name: "error",
-			args: "City",
-			stub: func(mockSQL sqlmock.Sqlmock) {
-				expectQuery := `SELECT id, fname, city, phone, height, married FROM users WHERE city ILIKE '%' || \$1 || '%'`
-				mockSQL.ExpectQuery(expectQuery).WithArgs("City").WillReturnError(errors.New("error"))
-			},
-			want:    []models.Users{},
-			wantErr: errors.New("error"),
+			name:  "everyone",
+			query: columns + ` ORDER BY id ASC`,
+		},
+		{
+			name:   "created after without married",
+			search: models.SearchUser{CreatedAfter: day(3)},
+			query:  columns + ` WHERE created_at >= $1 ORDER BY id ASC`,
+			args:   []driver.Value{day(3)},
+		},
+		{
+			name:   "unmarried in a created range by created_at",
+			search: models.SearchUser{Married: &unmarried, CreatedAfter: day(3), CreatedBefore: day(5), SortBy: models.SortByCreatedAt},
+			query:  columns + ` WHERE (married = $1) AND created_at >= $2 AND created_at < $3 ORDER BY created_at ASC, id ASC`,
+			args:   []driver.Value{false, day(3), day(5)},
+		},
+		{
+			name:   "updated range by updated_at descending",
+			search: models.SearchUser{UpdatedAfter: day(4), UpdatedBefore: day(6), SortBy: models.SortByUpdatedAt, Descending: true},
+			query:  columns + ` WHERE updated_at >= $1 AND updated_at < $2 ORDER BY updated_at DESC, id DESC`,
+			args:   []driver.Value{day(4), day(6)},
+		},
+		{
+			name:   "by id descending",
+			search: models.SearchUser{City: "City", Descending: true},
+			query:  columns + ` WHERE (city ILIKE '%' || $1 || '%') ORDER BY id DESC`,
+			args:   []driver.Value{"City"},
 		},
 	}
 
@@ -202,15 +233,26 @@ func Test_SearchCity(t *testing.T) {
 			gormDB, _ := gorm.Open(postgres.New(postgres.Config{
 				Conn: mockDB,
 			}), &gorm.Config{})
-			tt.stub(mockSQL)
-			u := NewUserRepository(gormDB)
+			mockSQL.ExpectQuery("^" + regexp.QuoteMeta(tt.query) + "$").WithArgs(tt.args...).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "fname", "city", "phone", "height", "married"}).AddRow(1, "Akhil", "City", "+919087678564", 157.6, true))
+			u := NewUserRepository(gormDB, 0)
 
-			result, err := u.SearchCity(tt.args)
+			result, err := u.SearchUsers(context.Background(), tt.search)
 
-			assert.Equal(t, tt.want, result)
-			assert.Equal(t, tt.wantErr, err)
+			assert.NoError(t, err)
+			assert.Equal(t, []models.Users{{ID: 1, Fname: "Akhil", City: "City", Phone: "+919087678564", Height: 157.6, Married: true}}, result)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
 		})
 	}
+
+	mockDB, mockSQL, _ := sqlmock.New()
+	defer mockDB.Close()
+	gormDB, _ := gorm.Open(postgres.New(postgres.Config{
+		Conn: mockDB,
+	}), &gorm.Config{})
+	mockSQL.ExpectQuery(regexp.QuoteMeta(columns)).WillReturnError(errors.New("error"))
+	_, err := NewUserRepository(gormDB, 0).SearchUsers(context.Background(), models.SearchUser{})
+	assert.Equal(t, errors.New("error"), err)
 }
 
 func Test_SearchPhone(t *testing.T) {
@@ -225,7 +267,7 @@ func Test_SearchPhone(t *testing.T) {
 			name: "success",
 			args: "1234567890",
 			stub: func(mockSQL sqlmock.Sqlmock) {
-				expectQuery := `SELECT id, fname, city, phone, height, married FROM users WHERE phone=\$1`
+				expectQuery := `SELECT id, fname, city, phone, height, married, version, created_at, updated_at, created_by, updated_by FROM users WHERE phone=\$1`
 				mockSQL.ExpectQuery(expectQuery).WithArgs("1234567890").WillReturnRows(sqlmock.NewRows([]string{"id", "fname", "city", "phone", "height", "married"}).AddRow(1, "Akhil", "City", "1234567890", 157.6, true))
 			},
 			want: []models.Users{
@@ -244,7 +286,7 @@ func Test_SearchPhone(t *testing.T) {
 			name: "error",
 			args: "1234567890",
 			stub: func(mockSQL sqlmock.Sqlmock) {
-				expectQuery := `SELECT id, fname, city, phone, height, married FROM users WHERE phone=\$1`
+				expectQuery := `SELECT id, fname, city, phone, height, married, version, created_at, updated_at, created_by, updated_by FROM users WHERE phone=\$1`
 				mockSQL.ExpectQuery(expectQuery).WithArgs("1234567890").WillReturnError(errors.New("error"))
 			},
 			want:    []models.Users{},
@@ -260,9 +302,9 @@ func Test_SearchPhone(t *testing.T) {
 				Conn: mockDB,
 			}), &gorm.Config{})
 			tt.stub(mockSQL)
-			u := NewUserRepository(gormDB)
+			u := NewUserRepository(gormDB, 0)
 
-			result, err := u.SearchPhone(tt.args)
+			result, err := u.SearchPhone(context.Background(), tt.args)
 
 			assert.Equal(t, tt.want, result)
 			assert.Equal(t, tt.wantErr, err)
@@ -270,43 +312,59 @@ func Test_SearchPhone(t *testing.T) {
 	}
 }
 
-func Test_SearchMarried(t *testing.T) {
+func Test_AddUser(t *testing.T) {
 	tests := []struct {
 		name    string
-		args    bool
+		args    models.User
 		stub    func(mockSQL sqlmock.Sqlmock)
-		want    []models.Users
 		wantErr error
 	}{
 		{
 			name: "success",
-			args: true,
-			stub: func(mockSQL sqlmock.Sqlmock) {
-				expectQuery := `SELECT id, fname, city, phone, height, married FROM users WHERE married=\$1`
-				mockSQL.ExpectQuery(expectQuery).WithArgs(true).WillReturnRows(sqlmock.NewRows([]string{"id", "fname", "city", "phone", "height", "married"}).AddRow(1, "Akhil", "City", "1234567890", 157.6, true))
+			args: models.User{
+				Fname:   "Akhil",
+				City:    "City",
+				Phone:   "1234567890",
+				Height:  157.6,
+				Married: true,
 			},
-			want: []models.Users{
-				{
-					ID:      1,
-					Fname:   "Akhil",
-					City:    "City",
-					Phone:   "1234567890",
-					Height:  157.6,
-					Married: true,
-				},
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				expectQuery := `(?s)` + regexp.QuoteMeta("INSERT INTO users (fname, city, phone, height, married, created_by, updated_by) VALUES ($1, $2, $3, $4, $5, $6, $6) RETURNING *") + `.*` + regexp.QuoteMeta("INSERT INTO user_history") + `.*` + regexp.QuoteMeta("INSERT INTO outbox (event_type, user_id, data, actor) SELECT 'UserCreated'")
+				mockSQL.ExpectExec(expectQuery).WithArgs("Akhil", "City", "1234567890", sqlmock.AnyArg(), true, "alice").WillReturnResult(sqlmock.NewResult(1, 1))
 			},
 			wantErr: nil,
 		},
 		{
 			name: "error",
-			args: true,
+			args: models.User{
+				Fname:   "Akhil",
+				City:    "City",
+				Phone:   "1234567890",
+				Height:  157.6,
+				Married: true,
+			},
 			stub: func(mockSQL sqlmock.Sqlmock) {
-				expectQuery := `SELECT id, fname, city, phone, height, married FROM users WHERE married=\$1`
-				mockSQL.ExpectQuery(expectQuery).WithArgs(true).WillReturnError(errors.New("error"))
+				expectQuery := `(?s)` + regexp.QuoteMeta("INSERT INTO users (fname, city, phone, height, married, created_by, updated_by) VALUES ($1, $2, $3, $4, $5, $6, $6) RETURNING *") + `.*` + regexp.QuoteMeta("INSERT INTO user_history") + `.*` + regexp.QuoteMeta("INSERT INTO outbox (event_type, user_id, data, actor) SELECT 'UserCreated'")
+				mockSQL.ExpectExec(expectQuery).WithArgs("Akhil", "City", "1234567890", sqlmock.AnyArg(), true, "alice").WillReturnError(errors.New("error"))
 			},
-			want:    []models.Users{},
 			wantErr: errors.New("error"),
 		},
+		{
+			name: "duplicate phone",
+			args: models.User{
+				Fname:   "Akhil",
+				City:    "City",
+				Phone:   "1234567890",
+				Height:  157.6,
+				Married: true,
+			},
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				expectQuery := `(?s)` + regexp.QuoteMeta("INSERT INTO users (fname, city, phone, height, married, created_by, updated_by) VALUES ($1, $2, $3, $4, $5, $6, $6) RETURNING *") + `.*` + regexp.QuoteMeta("INSERT INTO user_history") + `.*` + regexp.QuoteMeta("INSERT INTO outbox (event_type, user_id, data, actor) SELECT 'UserCreated'")
+				mockSQL.ExpectExec(expectQuery).WithArgs("Akhil", "City", "1234567890", sqlmock.AnyArg(), true, "alice").
+					WillReturnError(&pgconn.PgError{Code: "23505", ConstraintName: "users_phone_key"})
+			},
+			wantErr: domain.ErrUserAlreadyExists,
+		},
 	}
 
 	for _, tt := range tests {
@@ -317,52 +375,66 @@ func Test_SearchMarried(t *testing.T) {
 				Conn: mockDB,
 			}), &gorm.Config{})
 			tt.stub(mockSQL)
-			u := NewUserRepository(gormDB)
+			u := NewUserRepository(gormDB, 0)
 
-			result, err := u.SearchMarried(tt.args)
+			err := u.AddUser(auth.WithPrincipal(context.Background(), "alice"), tt.args)
 
-			assert.Equal(t, tt.want, result)
 			assert.Equal(t, tt.wantErr, err)
 		})
 	}
 }
 
-func Test_AddUser(t *testing.T) {
+func Test_UpdateUser(t *testing.T) {
+	updateQuery := `(?s)` + regexp.QuoteMeta(`SELECT * FROM users WHERE id=$7 AND version=$8 FOR UPDATE`) +
+		`.*` + regexp.QuoteMeta(`UPDATE users SET fname=$1, city=$2, phone=$3, height=$4, married=$5, version=users.version+1, updated_at=now(), updated_by=$6`) +
+		`.*` + regexp.QuoteMeta(`INSERT INTO user_history`) +
+		`.*` + regexp.QuoteMeta(`INSERT INTO outbox (event_type, user_id, data, actor) SELECT 'UserUpdated'`)
+	countQuery := regexp.QuoteMeta(`SELECT count(*) FROM users WHERE id=$1`)
+	user := models.User{Fname: "Akhil", City: "Kochi", Phone: "+919087678564", Height: 157.6, Married: true}
+	columns := []string{"id", "fname", "city", "phone", "height", "married", "version", "created_at", "updated_at", "created_by", "updated_by"}
+	createdAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	updatedAt := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+
 	tests := []struct {
 		name    string
-		args    models.User
 		stub    func(mockSQL sqlmock.Sqlmock)
+		want    models.Users
 		wantErr error
 	}{
 		{
 			name: "success",
-			args: models.User{
-				Fname:   "Akhil",
-				City:    "City",
-				Phone:   "1234567890",
-				Height:  157.6,
-				Married: true,
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				mockSQL.ExpectQuery(updateQuery).WithArgs("Akhil", "Kochi", "+919087678564", sqlmock.AnyArg(), true, "alice", 1, 3).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "Akhil", "Kochi", "+919087678564", 157.6, true, 4, createdAt, updatedAt, "bob", "alice"))
 			},
+			want: models.Users{ID: 1, Fname: "Akhil", City: "Kochi", Phone: "+919087678564", Height: 157.6, Married: true, Version: 4,
+				CreatedAt: createdAt, UpdatedAt: updatedAt, CreatedBy: "bob", UpdatedBy: "alice"},
+		},
+		{
+			name: "stale version",
 			stub: func(mockSQL sqlmock.Sqlmock) {
-				expectQuery := regexp.QuoteMeta("INSERT INTO users (fname, city, phone, height, married) VALUES ($1, $2, $3, $4, $5)")
-				mockSQL.ExpectExec(expectQuery).WithArgs("Akhil", "City", "1234567890", sqlmock.AnyArg(), true).WillReturnResult(sqlmock.NewResult(1, 1))
+				mockSQL.ExpectQuery(updateQuery).WithArgs("Akhil", "Kochi", "+919087678564", sqlmock.AnyArg(), true, "alice", 1, 3).
+					WillReturnRows(sqlmock.NewRows(columns))
+				mockSQL.ExpectQuery(countQuery).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 			},
-			wantErr: nil,
+			wantErr: domain.ErrVersionMismatch,
 		},
 		{
-			name: "error",
-			args: models.User{
-				Fname:   "Akhil",
-				City:    "City",
-				Phone:   "1234567890",
-				Height:  157.6,
-				Married: true,
+			name: "not found",
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				mockSQL.ExpectQuery(updateQuery).WithArgs("Akhil", "Kochi", "+919087678564", sqlmock.AnyArg(), true, "alice", 1, 3).
+					WillReturnRows(sqlmock.NewRows(columns))
+				mockSQL.ExpectQuery(countQuery).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 			},
+			wantErr: domain.ErrUserNotFound,
+		},
+		{
+			name: "duplicate phone",
 			stub: func(mockSQL sqlmock.Sqlmock) {
-				expectQuery := regexp.QuoteMeta("INSERT INTO users (fname, city, phone, height, married) VALUES ($1, $2, $3, $4, $5)")
-				mockSQL.ExpectExec(expectQuery).WithArgs("Akhil", "City", "1234567890", sqlmock.AnyArg(), true).WillReturnError(errors.New("error"))
+				mockSQL.ExpectQuery(updateQuery).WithArgs("Akhil", "Kochi", "+919087678564", sqlmock.AnyArg(), true, "alice", 1, 3).
+					WillReturnError(&pgconn.PgError{Code: "23505", ConstraintName: "users_phone_key"})
 			},
-			wantErr: errors.New("error"),
+			wantErr: domain.ErrUserAlreadyExists,
 		},
 	}
 
@@ -374,48 +446,48 @@ func Test_AddUser(t *testing.T) {
 				Conn: mockDB,
 			}), &gorm.Config{})
 			tt.stub(mockSQL)
-			u := NewUserRepository(gormDB)
+			u := NewUserRepository(gormDB, 0)
 
-			err := u.AddUser(tt.args)
+			result, err := u.UpdateUser(auth.WithPrincipal(context.Background(), "alice"), 1, user, 3)
 
+			assert.Equal(t, tt.want, result)
 			assert.Equal(t, tt.wantErr, err)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
 		})
 	}
 }
 
-func Test_CheckUserExistsByPhone(t *testing.T) {
+func Test_DeleteUser(t *testing.T) {
+	deleteQuery := `(?s)` + regexp.QuoteMeta(`DELETE FROM users WHERE id=$1 AND version=$2 RETURNING *`) + `.*` + regexp.QuoteMeta(`INSERT INTO user_history`) +
+		`.*` + regexp.QuoteMeta(`INSERT INTO outbox (event_type, user_id, data, actor) SELECT 'UserDeleted'`)
+	countQuery := regexp.QuoteMeta(`SELECT count(*) FROM users WHERE id=$1`)
+
 	tests := []struct {
-		name string
-		args string
-		stub func(mockSQL sqlmock.Sqlmock)
-		want bool
+		name    string
+		stub    func(mockSQL sqlmock.Sqlmock)
+		wantErr error
 	}{
 		{
-			name: "exists",
-			args: "1234567890",
+			name: "success",
 			stub: func(mockSQL sqlmock.Sqlmock) {
-				expectQuery := regexp.QuoteMeta("SELECT count(*) FROM users WHERE phone = $1")
-				mockSQL.ExpectQuery(expectQuery).WithArgs("1234567890").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+				mockSQL.ExpectExec(deleteQuery).WithArgs(1, 3, "alice").WillReturnResult(sqlmock.NewResult(0, 1))
 			},
-			want: true,
 		},
 		{
-			name: "not exists",
-			args: "1234567890",
+			name: "stale version",
 			stub: func(mockSQL sqlmock.Sqlmock) {
-				expectQuery := regexp.QuoteMeta("SELECT count(*) FROM users WHERE phone = $1")
-				mockSQL.ExpectQuery(expectQuery).WithArgs("1234567890").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				mockSQL.ExpectExec(deleteQuery).WithArgs(1, 3, "alice").WillReturnResult(sqlmock.NewResult(0, 0))
+				mockSQL.ExpectQuery(countQuery).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 			},
-			want: false,
+			wantErr: domain.ErrVersionMismatch,
 		},
 		{
-			name: "error",
-			args: "1234567890",
+			name: "not found",
 			stub: func(mockSQL sqlmock.Sqlmock) {
-				expectQuery := regexp.QuoteMeta("SELECT count(*) FROM users WHERE phone = ?")
-				mockSQL.ExpectQuery(expectQuery).WithArgs("1234567890").WillReturnError(errors.New("error"))
+				mockSQL.ExpectExec(deleteQuery).WithArgs(1, 3, "alice").WillReturnResult(sqlmock.NewResult(0, 0))
+				mockSQL.ExpectQuery(countQuery).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 			},
-			want: false,
+			wantErr: domain.ErrUserNotFound,
 		},
 	}
 
@@ -427,48 +499,68 @@ func Test_CheckUserExistsByPhone(t *testing.T) {
 				Conn: mockDB,
 			}), &gorm.Config{})
 			tt.stub(mockSQL)
-			u := NewUserRepository(gormDB)
+			u := NewUserRepository(gormDB, 0)
 
-			result := u.CheckUserExistsByPhone(tt.args)
+			err := u.DeleteUser(auth.WithPrincipal(context.Background(), "alice"), 1, 3)
 
-			assert.Equal(t, tt.want, result)
+			assert.Equal(t, tt.wantErr, err)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
 		})
 	}
 }
 
-func Test_CheckUserAvailabilityWithUserID(t *testing.T) {
+func Test_GetUserHistory(t *testing.T) {
+	historyQuery := regexp.QuoteMeta(`SELECT id, user_id, operation, old_values, new_values, actor, changed_at FROM user_history WHERE user_id=$1 AND ($2::bigint = 0 OR id < $2::bigint) ORDER BY id DESC LIMIT $3`)
+	columns := []string{"id", "user_id", "operation", "old_values", "new_values", "actor", "changed_at"}
+	changedAt := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	mockDB, mockSQL, _ := sqlmock.New()
+	defer mockDB.Close()
+	gormDB, _ := gorm.Open(postgres.New(postgres.Config{
+		Conn: mockDB,
+	}), &gorm.Config{})
+	u := NewUserRepository(gormDB, 0)
+
+	mockSQL.ExpectQuery(historyQuery).WithArgs(1, 40, 2).WillReturnRows(sqlmock.NewRows(columns).
+		AddRow(31, 1, "delete", []byte(`{"id": 1, "city": "Kochi", "version": 2}`), nil, "alice", changedAt).
+		AddRow(30, 1, "update", []byte(`{"id": 1, "city": "Kannur", "version": 1}`), []byte(`{"id": 1, "city": "Kochi", "version": 2, "updated_at": "2024-06-01T10:00:00Z"}`), "bob", changedAt))
+
+	changes, err := u.GetUserHistory(context.Background(), 1, 40, 2)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []models.UserChange{
+		{ID: 31, UserID: 1, Operation: models.ChangeDelete, Old: &models.Users{ID: 1, City: "Kochi", Version: 2}, Actor: "alice", ChangedAt: changedAt},
+		{ID: 30, UserID: 1, Operation: models.ChangeUpdate, Old: &models.Users{ID: 1, City: "Kannur", Version: 1},
+			New: &models.Users{ID: 1, City: "Kochi", Version: 2, UpdatedAt: changedAt}, Actor: "bob", ChangedAt: changedAt},
+	}, changes)
+	assert.NoError(t, mockSQL.ExpectationsWereMet())
+}
+
+func Test_GetUserAsOf(t *testing.T) {
+	asOfQuery := regexp.QuoteMeta(`SELECT id, user_id, operation, old_values, new_values, actor, changed_at FROM user_history WHERE user_id=$1 AND changed_at <= $2 ORDER BY changed_at DESC, id DESC LIMIT 1`)
+	columns := []string{"id", "user_id", "operation", "old_values", "new_values", "actor", "changed_at"}
+	asOf := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
 	tests := []struct {
-		name string
-		args int64
-		stub func(mockSQL sqlmock.Sqlmock)
-		want bool
+		name    string
+		rows    *sqlmock.Rows
+		want    models.Users
+		wantErr error
 	}{
 		{
-			name: "exists",
-			args: 1,
-			stub: func(mockSQL sqlmock.Sqlmock) {
-				expectQuery := `SELECT count\(\*\) FROM users WHERE id = \$1`
-				mockSQL.ExpectQuery(expectQuery).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
-			},
-			want: true,
+			name: "updated before",
+			rows: sqlmock.NewRows(columns).AddRow(30, 1, "update", []byte(`{"id": 1}`), []byte(`{"id": 1, "city": "Kannur", "married": true}`), "bob", asOf),
+			want: models.Users{ID: 1, City: "Kannur", Married: true},
 		},
 		{
-			name: "not exists",
-			args: 1,
-			stub: func(mockSQL sqlmock.Sqlmock) {
-				expectQuery := `SELECT count\(\*\) FROM users WHERE id = \$1`
-				mockSQL.ExpectQuery(expectQuery).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
-			},
-			want: false,
+			name:    "deleted before",
+			rows:    sqlmock.NewRows(columns).AddRow(31, 1, "delete", []byte(`{"id": 1}`), nil, "alice", asOf),
+			wantErr: domain.ErrUserNotFound,
 		},
 		{
-			name: "error",
-			args: 1,
-			stub: func(mockSQL sqlmock.Sqlmock) {
-				expectQuery := `SELECT count\(\*\) FROM users WHERE id = \$1`
-				mockSQL.ExpectQuery(expectQuery).WithArgs(1).WillReturnError(errors.New("error"))
-			},
-			want: false,
+			name:    "not created yet",
+			rows:    sqlmock.NewRows(columns),
+			wantErr: domain.ErrUserNotFound,
 		},
 	}
 
@@ -479,53 +571,41 @@ func Test_CheckUserAvailabilityWithUserID(t *testing.T) {
 			gormDB, _ := gorm.Open(postgres.New(postgres.Config{
 				Conn: mockDB,
 			}), &gorm.Config{})
-			tt.stub(mockSQL)
-			u := NewUserRepository(gormDB)
+			mockSQL.ExpectQuery(asOfQuery).WithArgs(1, asOf).WillReturnRows(tt.rows)
+			u := NewUserRepository(gormDB, 0)
 
-			result := u.CheckUserAvailabilityWithUserID(tt.args)
+			user, err := u.GetUserAsOf(context.Background(), 1, asOf)
 
-			assert.Equal(t, tt.want, result)
+			assert.Equal(t, tt.want, user)
+			assert.Equal(t, tt.wantErr, err)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
 		})
 	}
 }
 
-func Test_CheckUserAvailabilityWithUserIDs(t *testing.T) {
+func Test_QueryTimeout(t *testing.T) {
+	expectQuery := `SELECT id, fname, city, phone, height, married, version, created_at, updated_at, created_by, updated_by FROM users WHERE id=\$1`
+	rows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "fname", "city", "phone", "height", "married"}).
+			AddRow(1, "akhil", "bangalore", "9087678564", 157.9, true)
+	}
+
 	tests := []struct {
-		name string
-		args []int64
-		stub func(mockSQL sqlmock.Sqlmock)
-		want bool
+		name         string
+		queryTimeout time.Duration
+		ctx          func() (context.Context, context.CancelFunc)
 	}{
 		{
-			name: "all exist",
-			args: []int64{1, 2, 3},
-			stub: func(mockSQL sqlmock.Sqlmock) {
-				expectQuery := regexp.QuoteMeta("SELECT count(*) FROM users WHERE id = $1")
-				mockSQL.ExpectQuery(expectQuery).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
-				mockSQL.ExpectQuery(expectQuery).WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
-				mockSQL.ExpectQuery(expectQuery).WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
-			},
-			want: true,
-		},
-		{
-			name: "some exist",
-			args: []int64{1, 2, 3},
-			stub: func(mockSQL sqlmock.Sqlmock) {
-				expectQuery := regexp.QuoteMeta("SELECT count(*) FROM users WHERE id = $1")
-				mockSQL.ExpectQuery(expectQuery).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
-				mockSQL.ExpectQuery(expectQuery).WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
-				mockSQL.ExpectQuery(expectQuery).WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
-			},
-			want: false,
+			name:         "query timeout",
+			queryTimeout: 20 * time.Millisecond,
+			ctx:          func() (context.Context, context.CancelFunc) { return context.WithCancel(context.Background()) },
 		},
 		{
-			name: "error",
-			args: []int64{1, 2, 3},
-			stub: func(mockSQL sqlmock.Sqlmock) {
-				expectQuery := regexp.QuoteMeta("SELECT count(*) FROM users WHERE id = $1")
-				mockSQL.ExpectQuery(expectQuery).WithArgs(1).WillReturnError(errors.New("error"))
+			name:         "caller deadline shorter than query timeout",
+			queryTimeout: time.Minute,
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 20*time.Millisecond)
 			},
-			want: false,
 		},
 	}
 
@@ -536,12 +616,50 @@ func Test_CheckUserAvailabilityWithUserIDs(t *testing.T) {
 			gormDB, _ := gorm.Open(postgres.New(postgres.Config{
 				Conn: mockDB,
 			}), &gorm.Config{})
-			tt.stub(mockSQL)
-			u := NewUserRepository(gormDB)
+			mockSQL.ExpectQuery(expectQuery).WithArgs(1).WillDelayFor(time.Second).WillReturnRows(rows())
+			u := NewUserRepository(gormDB, tt.queryTimeout)
+			ctx, cancel := tt.ctx()
+			defer cancel()
 
-			result := u.CheckUserAvailabilityWithUserIDs(tt.args)
+			start := time.Now()
+			_, err := u.GetUserByID(ctx, 1)
 
-			assert.Equal(t, tt.want, result)
+			assert.Error(t, err)
+			assert.Less(t, time.Since(start), 500*time.Millisecond)
 		})
 	}
 }
+
+func Test_GetChanges(t *testing.T) {
+	changesQuery := regexp.QuoteMeta(`SELECT id, user_id, operation, old_values, new_values, actor, changed_at, txid::text AS tx_id FROM user_history WHERE (txid, id) > ($1::text::xid8, $2) AND txid < pg_snapshot_xmin(pg_current_snapshot()) AND operation <> 'snapshot' ORDER BY txid, id LIMIT $3`)
+	columns := []string{"id", "user_id", "operation", "old_values", "new_values", "actor", "changed_at", "tx_id"}
+	changedAt := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	mockDB, mockSQL, _ := sqlmock.New()
+	defer mockDB.Close()
+	gormDB, _ := gorm.Open(postgres.New(postgres.Config{
+		Conn: mockDB,
+	}), &gorm.Config{})
+	u := NewUserRepository(gormDB, 0)
+
+	mockSQL.ExpectQuery(changesQuery).WithArgs("900", 12, 100).WillReturnRows(sqlmock.NewRows(columns).
+		AddRow(14, 1, "insert", nil, []byte(`{"id": 1, "city": "Kochi", "version": 1}`), "alice", changedAt, "903").
+		AddRow(13, 2, "delete", []byte(`{"id": 2, "city": "Kannur", "version": 4}`), nil, "bob", changedAt, "905"))
+
+	changes, err := u.GetChanges(context.Background(), models.ChangeCursor{TxID: 900, ID: 12}, 100)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []models.UserChange{
+		{ID: 14, UserID: 1, Operation: models.ChangeInsert, New: &models.Users{ID: 1, City: "Kochi", Version: 1}, Actor: "alice", ChangedAt: changedAt, TxID: 903},
+		{ID: 13, UserID: 2, Operation: models.ChangeDelete, Old: &models.Users{ID: 2, City: "Kannur", Version: 4}, Actor: "bob", ChangedAt: changedAt, TxID: 905},
+	}, changes)
+
+	mockSQL.ExpectQuery(regexp.QuoteMeta(`SELECT pg_snapshot_xmin(pg_current_snapshot())::text`)).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_snapshot_xmin"}).AddRow("1204"))
+
+	cursor, err := u.GetChangeCursor(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.ChangeCursor{TxID: 1204}, cursor)
+	assert.NoError(t, mockSQL.ExpectationsWereMet())
+}
@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"context"
+	"grpc-user-service/pkg/auth"
+	"grpc-user-service/pkg/domain"
+	"grpc-user-service/pkg/metrics"
+	interfaces "grpc-user-service/pkg/repository/interface"
+	"grpc-user-service/pkg/utils/models"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	subscriptionColumns = `id, url, array_to_string(event_types, ',') AS event_types, created_by, created_at`
+	deliveryColumns     = `id, subscription_id, event_id, event_type, user_id, status, attempts, response_code, last_error, created_at, next_attempt_at, last_attempt_at, delivered_at`
+)
+
+type webhookRepository struct {
+	DB           *gorm.DB
+	queryTimeout time.Duration
+}
+
+// NewWebhookRepository returns the Postgres webhook subscriptions and
+// deliveries. Every query is bounded by queryTimeout, 0 leaves only the
+// caller's deadline.
+func NewWebhookRepository(DB *gorm.DB, queryTimeout time.Duration) interfaces.WebhookRepository {
+	return &webhookRepository{
+		DB:           DB,
+		queryTimeout: queryTimeout,
+	}
+}
+
+// subscriptionRow is a row of webhook_subscriptions with event_types as a
+// comma separated list.
+type subscriptionRow struct {
+	ID         int64
+	URL        string
+	EventTypes string
+	CreatedBy  string
+	CreatedAt  time.Time
+}
+
+func (r subscriptionRow) subscription() models.WebhookSubscription {
+	sub := models.WebhookSubscription{
+		ID:         r.ID,
+		URL:        r.URL,
+		EventTypes: []string{},
+		CreatedBy:  r.CreatedBy,
+		CreatedAt:  r.CreatedAt,
+	}
+	if r.EventTypes != "" {
+		sub.EventTypes = strings.Split(r.EventTypes, ",")
+	}
+	return sub
+}
+
+func (w *webhookRepository) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if w.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, w.queryTimeout)
+}
+
+func (w *webhookRepository) CreateSubscription(ctx context.Context, sub models.WebhookSubscription) (models.WebhookSubscription, error) {
+	defer metrics.ObserveQuery("WebhookCreateSubscription", time.Now())
+	ctx, cancel := w.withTimeout(ctx)
+	defer cancel()
+	sub.CreatedBy = auth.Principal(ctx)
+	var row subscriptionRow
+	err := w.DB.WithContext(ctx).Raw(`INSERT INTO webhook_subscriptions (url, event_types, secret, created_by) VALUES ($1, $2::text[], $3, $4) RETURNING id, created_at`,
+		sub.URL, textArray(sub.EventTypes), sub.Secret, sub.CreatedBy).Scan(&row).Error
+	if err != nil {
+		return models.WebhookSubscription{}, err
+	}
+	sub.ID, sub.CreatedAt = row.ID, row.CreatedAt
+	return sub, nil
+}
+
+func (w *webhookRepository) ListSubscriptions(ctx context.Context, before int64, limit int) ([]models.WebhookSubscription, error) {
+	defer metrics.ObserveQuery("WebhookListSubscriptions", time.Now())
+	ctx, cancel := w.withTimeout(ctx)
+	defer cancel()
+	var rows []subscriptionRow
+	err := w.DB.WithContext(ctx).Raw(`SELECT `+subscriptionColumns+` FROM webhook_subscriptions WHERE ($1::bigint = 0 OR id < $1::bigint) ORDER BY id DESC LIMIT $2`, before, limit).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	subs := make([]models.WebhookSubscription, 0, len(rows))
+	for _, row := range rows {
+		subs = append(subs, row.subscription())
+	}
+	return subs, nil
+}
+
+func (w *webhookRepository) DeleteSubscription(ctx context.Context, id int64) error {
+	defer metrics.ObserveQuery("WebhookDeleteSubscription", time.Now())
+	ctx, cancel := w.withTimeout(ctx)
+	defer cancel()
+	result := w.DB.WithContext(ctx).Exec(`DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+func (w *webhookRepository) Enqueue(ctx context.Context, event models.OutboxEvent, payload []byte) (int64, error) {
+	defer metrics.ObserveQuery("WebhookEnqueue", time.Now())
+	ctx, cancel := w.withTimeout(ctx)
+	defer cancel()
+	result := w.DB.WithContext(ctx).Exec(`INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, user_id, payload)
+	SELECT id, $1, $2::text, $3, $4::jsonb FROM webhook_subscriptions WHERE cardinality(event_types) = 0 OR $2::text = ANY(event_types)
+	ON CONFLICT (subscription_id, event_id) DO NOTHING`,
+		event.ID, event.Type, event.User.ID, string(payload))
+	return result.RowsAffected, result.Error
+}
+
+// Claim takes the due deliveries with SKIP LOCKED, like the outbox does, and
+// joins their subscriptions for the URL and secret.
+func (w *webhookRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	defer metrics.ObserveQuery("WebhookClaim", time.Now())
+	ctx, cancel := w.withTimeout(ctx)
+	defer cancel()
+	var deliveries []models.WebhookDelivery
+	err := w.DB.WithContext(ctx).Raw(`UPDATE webhook_deliveries d SET next_attempt_at = now() + $2::float8 * interval '1 second'
+	FROM webhook_subscriptions s
+	WHERE s.id = d.subscription_id AND d.id IN (
+		SELECT id FROM webhook_deliveries w WHERE status = 'pending' AND next_attempt_at <= now()
+		AND NOT EXISTS (SELECT 1 FROM webhook_deliveries p WHERE p.subscription_id = w.subscription_id AND p.user_id = w.user_id AND p.id < w.id AND p.status = 'pending')
+		ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED
+	)
+	RETURNING d.id, d.subscription_id, d.event_id, d.event_type, d.user_id, d.payload, d.attempts, s.url, s.secret`, limit, lease.Seconds()).Scan(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+	// RETURNING follows no particular order.
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID < deliveries[j].ID })
+	return deliveries, nil
+}
+
+func (w *webhookRepository) MarkDelivered(ctx context.Context, id int64, responseCode int) error {
+	defer metrics.ObserveQuery("WebhookMarkDelivered", time.Now())
+	ctx, cancel := w.withTimeout(ctx)
+	defer cancel()
+	return w.DB.WithContext(ctx).Exec(`UPDATE webhook_deliveries SET status = 'delivered', attempts = attempts + 1, response_code = $2, last_error = '', last_attempt_at = now(), delivered_at = now() WHERE id = $1`,
+		id, responseCode).Error
+}
+
+func (w *webhookRepository) Retry(ctx context.Context, id int64, responseCode int, lastErr string, delay time.Duration) error {
+	defer metrics.ObserveQuery("WebhookRetry", time.Now())
+	ctx, cancel := w.withTimeout(ctx)
+	defer cancel()
+	return w.DB.WithContext(ctx).Exec(`UPDATE webhook_deliveries SET attempts = attempts + 1, response_code = $2, last_error = $3, last_attempt_at = now(), next_attempt_at = now() + $4::float8 * interval '1 second' WHERE id = $1`,
+		id, responseCode, lastErr, delay.Seconds()).Error
+}
+
+func (w *webhookRepository) Fail(ctx context.Context, id int64, responseCode int, lastErr string) error {
+	defer metrics.ObserveQuery("WebhookFail", time.Now())
+	ctx, cancel := w.withTimeout(ctx)
+	defer cancel()
+	return w.DB.WithContext(ctx).Exec(`UPDATE webhook_deliveries SET status = 'failed', attempts = attempts + 1, response_code = $2, last_error = $3, last_attempt_at = now() WHERE id = $1`,
+		id, responseCode, lastErr).Error
+}
+
+func (w *webhookRepository) ListDeliveries(ctx context.Context, subscriptionID int64, before int64, limit int) ([]models.WebhookDelivery, error) {
+	defer metrics.ObserveQuery("WebhookListDeliveries", time.Now())
+	ctx, cancel := w.withTimeout(ctx)
+	defer cancel()
+	deliveries := []models.WebhookDelivery{}
+	err := w.DB.WithContext(ctx).Raw(`SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE subscription_id = $1 AND ($2::bigint = 0 OR id < $2::bigint) ORDER BY id DESC LIMIT $3`,
+		subscriptionID, before, limit).Scan(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (w *webhookRepository) Replay(ctx context.Context, subscriptionID int64, deliveryID int64, since time.Time) (int64, error) {
+	defer metrics.ObserveQuery("WebhookReplay", time.Now())
+	ctx, cancel := w.withTimeout(ctx)
+	defer cancel()
+	result := w.DB.WithContext(ctx).Exec(`UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = now(), delivered_at = NULL WHERE subscription_id = $1 AND ($2::bigint = 0 OR id = $2::bigint) AND created_at >= $3`,
+		subscriptionID, deliveryID, since)
+	return result.RowsAffected, result.Error
+}
+
+func (w *webhookRepository) Prune(ctx context.Context, olderThan time.Duration) (int64, error) {
+	defer metrics.ObserveQuery("WebhookPrune", time.Now())
+	ctx, cancel := w.withTimeout(ctx)
+	defer cancel()
+	result := w.DB.WithContext(ctx).Exec(`DELETE FROM webhook_deliveries WHERE status <> 'pending' AND coalesce(delivered_at, last_attempt_at) < now() - $1::float8 * interval '1 second'`, olderThan.Seconds())
+	return result.RowsAffected, result.Error
+}
+
+// textArray formats items as a Postgres array literal.
+func textArray(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		item = strings.ReplaceAll(item, `\`, `\\`)
+		quoted[i] = `"` + strings.ReplaceAll(item, `"`, `\"`) + `"`
+	}
+	return "{" + strings.Join(quoted, ",") + "}"
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"grpc-user-service/pkg/domain"
+	"grpc-user-service/pkg/utils/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func newWebhookMock(t *testing.T) (*webhookRepository, sqlmock.Sqlmock) {
+	mockDB, mockSQL, _ := sqlmock.New()
+	t.Cleanup(func() { mockDB.Close() })
+	gormDB, _ := gorm.Open(postgres.New(postgres.Config{
+		Conn: mockDB,
+	}), &gorm.Config{})
+	return NewWebhookRepository(gormDB, 0).(*webhookRepository), mockSQL
+}
+
+func Test_WebhookSubscriptions(t *testing.T) {
+	w, mockSQL := newWebhookMock(t)
+	createdAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	mockSQL.ExpectQuery(regexp.QuoteMeta(`INSERT INTO webhook_subscriptions (url, event_types, secret, created_by) VALUES ($1, $2::text[], $3, $4) RETURNING id, created_at`)).
+		WithArgs("https://example.com/hook", `{"UserCreated","UserDeleted"}`, "whsec_1", "system").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(3, createdAt))
+	mockSQL.ExpectQuery(regexp.QuoteMeta(`SELECT `+subscriptionColumns+` FROM webhook_subscriptions WHERE ($1::bigint = 0 OR id < $1::bigint) ORDER BY id DESC LIMIT $2`)).
+		WithArgs(0, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "url", "event_types", "created_by", "created_at"}).
+			AddRow(3, "https://example.com/hook", "UserCreated,UserDeleted", "alice", createdAt).
+			AddRow(1, "https://example.org/hook", "", "bob", createdAt))
+	mockSQL.ExpectExec(regexp.QuoteMeta(`DELETE FROM webhook_subscriptions WHERE id = $1`)).
+		WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mockSQL.ExpectExec(regexp.QuoteMeta(`DELETE FROM webhook_subscriptions WHERE id = $1`)).
+		WithArgs(4).WillReturnResult(sqlmock.NewResult(0, 0))
+	ctx := context.Background()
+
+	sub, err := w.CreateSubscription(ctx, models.WebhookSubscription{
+		URL:        "https://example.com/hook",
+		EventTypes: []string{models.OutboxUserCreated, models.OutboxUserDeleted},
+		Secret:     "whsec_1",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, models.WebhookSubscription{
+		ID:         3,
+		URL:        "https://example.com/hook",
+		EventTypes: []string{"UserCreated", "UserDeleted"},
+		Secret:     "whsec_1",
+		CreatedBy:  "system",
+		CreatedAt:  createdAt,
+	}, sub)
+
+	subs, err := w.ListSubscriptions(ctx, 0, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.WebhookSubscription{
+		{ID: 3, URL: "https://example.com/hook", EventTypes: []string{"UserCreated", "UserDeleted"}, CreatedBy: "alice", CreatedAt: createdAt},
+		{ID: 1, URL: "https://example.org/hook", EventTypes: []string{}, CreatedBy: "bob", CreatedAt: createdAt},
+	}, subs)
+
+	assert.NoError(t, w.DeleteSubscription(ctx, 3))
+	assert.Equal(t, domain.ErrSubscriptionNotFound, w.DeleteSubscription(ctx, 4))
+	assert.NoError(t, mockSQL.ExpectationsWereMet())
+}
+
+func Test_WebhookClaim(t *testing.T) {
+	claimQuery := `(?s)` + regexp.QuoteMeta(`UPDATE webhook_deliveries d SET next_attempt_at = now() + $2::float8 * interval '1 second'`) +
+		`.*` + regexp.QuoteMeta(`ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED`) + `.*` + regexp.QuoteMeta(`RETURNING d.id, d.subscription_id, d.event_id, d.event_type, d.user_id, d.payload, d.attempts, s.url, s.secret`)
+	columns := []string{"id", "subscription_id", "event_id", "event_type", "user_id", "payload", "attempts", "url", "secret"}
+
+	tests := []struct {
+		name    string
+		stub    func(mockSQL sqlmock.Sqlmock)
+		want    []models.WebhookDelivery
+		wantErr error
+	}{
+		{
+			name: "oldest first",
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				mockSQL.ExpectQuery(claimQuery).WithArgs(10, 30.0).WillReturnRows(sqlmock.NewRows(columns).
+					AddRow(9, 2, 8, "UserUpdated", 2, []byte(`{"id":8}`), 1, "https://example.org/hook", "whsec_2").
+					AddRow(7, 1, 5, "UserCreated", 1, []byte(`{"id":5}`), 0, "https://example.com/hook", "whsec_1"))
+			},
+			want: []models.WebhookDelivery{
+				{ID: 7, SubscriptionID: 1, EventID: 5, EventType: "UserCreated", UserID: 1, Payload: []byte(`{"id":5}`), URL: "https://example.com/hook", Secret: "whsec_1"},
+				{ID: 9, SubscriptionID: 2, EventID: 8, EventType: "UserUpdated", UserID: 2, Payload: []byte(`{"id":8}`), Attempts: 1, URL: "https://example.org/hook", Secret: "whsec_2"},
+			},
+		},
+		{
+			name: "error",
+			stub: func(mockSQL sqlmock.Sqlmock) {
+				mockSQL.ExpectQuery(claimQuery).WithArgs(10, 30.0).WillReturnError(errors.New("error"))
+			},
+			wantErr: errors.New("error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, mockSQL := newWebhookMock(t)
+			tt.stub(mockSQL)
+
+			deliveries, err := w.Claim(context.Background(), 10, 30*time.Second)
+
+			assert.Equal(t, tt.want, deliveries)
+			assert.Equal(t, tt.wantErr, err)
+			assert.NoError(t, mockSQL.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_WebhookDeliveries(t *testing.T) {
+	w, mockSQL := newWebhookMock(t)
+	since := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	mockSQL.ExpectExec(regexp.QuoteMeta(`INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, user_id, payload)`)).
+		WithArgs(5, "UserCreated", 1, `{"id":5}`).WillReturnResult(sqlmock.NewResult(0, 2))
+	mockSQL.ExpectExec(regexp.QuoteMeta(`UPDATE webhook_deliveries SET status = 'delivered', attempts = attempts + 1, response_code = $2, last_error = '', last_attempt_at = now(), delivered_at = now() WHERE id = $1`)).
+		WithArgs(7, 204).WillReturnResult(sqlmock.NewResult(0, 1))
+	mockSQL.ExpectExec(regexp.QuoteMeta(`UPDATE webhook_deliveries SET attempts = attempts + 1, response_code = $2, last_error = $3, last_attempt_at = now(), next_attempt_at = now() + $4::float8 * interval '1 second' WHERE id = $1`)).
+		WithArgs(8, 503, "503 Service Unavailable", 4.0).WillReturnResult(sqlmock.NewResult(0, 1))
+	mockSQL.ExpectExec(regexp.QuoteMeta(`UPDATE webhook_deliveries SET status = 'failed', attempts = attempts + 1, response_code = $2, last_error = $3, last_attempt_at = now() WHERE id = $1`)).
+		WithArgs(9, 0, "timeout").WillReturnResult(sqlmock.NewResult(0, 1))
+	mockSQL.ExpectExec(regexp.QuoteMeta(`UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = now(), delivered_at = NULL WHERE subscription_id = $1 AND ($2::bigint = 0 OR id = $2::bigint) AND created_at >= $3`)).
+		WithArgs(1, 0, since).WillReturnResult(sqlmock.NewResult(0, 3))
+	mockSQL.ExpectExec(regexp.QuoteMeta(`DELETE FROM webhook_deliveries WHERE status <> 'pending' AND coalesce(delivered_at, last_attempt_at) < now() - $1::float8 * interval '1 second'`)).
+		WithArgs(3600.0).WillReturnResult(sqlmock.NewResult(0, 2))
+	ctx := context.Background()
+
+	enqueued, err := w.Enqueue(ctx, models.OutboxEvent{ID: 5, Type: models.OutboxUserCreated, User: models.Users{ID: 1}}, []byte(`{"id":5}`))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), enqueued)
+	assert.NoError(t, w.MarkDelivered(ctx, 7, 204))
+	assert.NoError(t, w.Retry(ctx, 8, 503, "503 Service Unavailable", 4*time.Second))
+	assert.NoError(t, w.Fail(ctx, 9, 0, "timeout"))
+	replayed, err := w.Replay(ctx, 1, 0, since)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), replayed)
+	pruned, err := w.Prune(ctx, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), pruned)
+	assert.NoError(t, mockSQL.ExpectationsWereMet())
+}
+
+func Test_TextArray(t *testing.T) {
+	assert.Equal(t, `{}`, textArray(nil))
+	assert.Equal(t, `{"UserCreated","a\"b\\c"}`, textArray([]string{"UserCreated", `a"b\c`}))
+}
@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.25.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const instrumentation = "grpc-user-service/pkg/tracing"
+
+// GormPlugin starts a span around every query GORM executes. The span is a
+// child of the span found in the statement context, so queries issued with
+// db.WithContext(ctx) show up under the RPC that triggered them. Only the SQL
+// text with its placeholders is recorded, never the bound values.
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "tracing"
+}
+
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	tracer := otel.Tracer(instrumentation)
+	before := func(db *gorm.DB) {
+		ctx, _ := tracer.Start(db.Statement.Context, "db.query", trace.WithSpanKind(trace.SpanKindClient))
+		db.Statement.Context = ctx
+	}
+	after := func(db *gorm.DB) {
+		span := trace.SpanFromContext(db.Statement.Context)
+		if !span.IsRecording() {
+			return
+		}
+		sql := db.Statement.SQL.String()
+		span.SetName(spanName(sql))
+		span.SetAttributes(semconv.DBSystemPostgreSQL, semconv.DBStatement(sql))
+		if db.Statement.RowsAffected >= 0 {
+			span.SetAttributes(attribute.Int64("db.rows_affected", db.Statement.RowsAffected))
+		}
+		if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+			span.RecordError(db.Error)
+			span.SetStatus(codes.Error, db.Error.Error())
+		}
+		span.End()
+	}
+
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("tracing:before_create", before),
+		cb.Create().After("gorm:create").Register("tracing:after_create", after),
+		cb.Query().Before("gorm:query").Register("tracing:before_query", before),
+		cb.Query().After("gorm:query").Register("tracing:after_query", after),
+		cb.Update().Before("gorm:update").Register("tracing:before_update", before),
+		cb.Update().After("gorm:update").Register("tracing:after_update", after),
+		cb.Delete().Before("gorm:delete").Register("tracing:before_delete", before),
+		cb.Delete().After("gorm:delete").Register("tracing:after_delete", after),
+		cb.Row().Before("gorm:row").Register("tracing:before_row", before),
+		cb.Row().After("gorm:row").Register("tracing:after_row", after),
+		cb.Raw().Before("gorm:raw").Register("tracing:before_raw", before),
+		cb.Raw().After("gorm:raw").Register("tracing:after_raw", after),
+	)
+}
+
+// spanName uses the SQL verb (SELECT, INSERT, ...) so span names stay low
+// cardinality while the full statement is kept as an attribute.
+func spanName(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "db.query"
+	}
+	return "db." + strings.ToUpper(fields[0])
+}
@@ -0,0 +1,44 @@
+package usecase
+
+import (
+	"context"
+	interfaces "grpc-user-service/pkg/repository/interface"
+	server "grpc-user-service/pkg/usecase/interface"
+	"grpc-user-service/pkg/utils/models"
+)
+
+type auditUseCase struct {
+	auditRepository interfaces.AuditRepository
+}
+
+func NewAuditUseCase(repository interfaces.AuditRepository) server.AuditUseCase {
+	return &auditUseCase{
+		auditRepository: repository,
+	}
+}
+
+// QueryAuditLog returns a page of the entries matching query, newest first.
+// pageToken is empty for the first page and the NextPageToken of the
+// previous page otherwise.
+func (a *auditUseCase) QueryAuditLog(ctx context.Context, query models.AuditQuery, pageSize int, pageToken string) (models.AuditPage, error) {
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	var before int64
+	if pageToken != "" {
+		var err error
+		if before, err = decodePageToken(pageToken); err != nil {
+			return models.AuditPage{}, err
+		}
+	}
+	entries, err := a.auditRepository.Query(ctx, query, before, pageSize+1)
+	if err != nil {
+		return models.AuditPage{}, err
+	}
+	page := models.AuditPage{Entries: entries}
+	if len(entries) > pageSize {
+		page.Entries = entries[:pageSize]
+		page.NextPageToken = encodePageToken(entries[pageSize-1].Seq)
+	}
+	return page, nil
+}
@@ -0,0 +1,48 @@
+package usecase
+
+import (
+	"context"
+	"grpc-user-service/pkg/domain"
+	mock_repository "grpc-user-service/pkg/repository/mock"
+	"grpc-user-service/pkg/utils/models"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_QueryAuditLog(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock_repository.NewMockAuditRepository(ctrl)
+	useCase := NewAuditUseCase(mockRepo)
+	query := models.AuditQuery{Principal: "alice"}
+
+	entries := func(seqs ...int64) []models.AuditEntry {
+		var result []models.AuditEntry
+		for _, seq := range seqs {
+			result = append(result, models.AuditEntry{Seq: seq, Principal: "alice"})
+		}
+		return result
+	}
+
+	mockRepo.EXPECT().Query(gomock.Any(), query, int64(0), 3).Return(entries(30, 20, 10), nil)
+	page, err := useCase.QueryAuditLog(context.Background(), query, 2, "")
+	assert.NoError(t, err)
+	assert.Equal(t, entries(30, 20), page.Entries)
+	assert.NotEmpty(t, page.NextPageToken)
+
+	mockRepo.EXPECT().Query(gomock.Any(), query, int64(20), 3).Return(entries(10), nil)
+	page, err = useCase.QueryAuditLog(context.Background(), query, 2, page.NextPageToken)
+	assert.NoError(t, err)
+	assert.Equal(t, entries(10), page.Entries)
+	assert.Empty(t, page.NextPageToken)
+
+	mockRepo.EXPECT().Query(gomock.Any(), query, int64(0), defaultPageSize+1).Return(nil, nil)
+	_, err = useCase.QueryAuditLog(context.Background(), query, 0, "")
+	assert.NoError(t, err)
+
+	_, err = useCase.QueryAuditLog(context.Background(), query, 2, "not base64!")
+	assert.ErrorIs(t, err, domain.ErrInvalidPageToken)
+}
@@ -0,0 +1,10 @@
+package interfaces
+
+import (
+	"context"
+	"grpc-user-service/pkg/utils/models"
+)
+
+type AuditUseCase interface {
+	QueryAuditLog(ctx context.Context, query models.AuditQuery, pageSize int, pageToken string) (models.AuditPage, error)
+}
@@ -1,10 +1,19 @@
 package interfaces
 
-import "grpc-user-service/pkg/utils/models"
+import (
+	"context"
+	"grpc-user-service/pkg/utils/models"
+	"time"
+)
 
 type UserUseCase interface {
-	GetUserByID(id int64) (models.Users, error)
-	GetUsersByIDs(ids []int64) ([]models.Users, error)
-	SearchUsers(search models.SearchUser) ([]models.Users, error)
-	AddUser(user models.User) error
+	GetUserByID(ctx context.Context, id int64) (models.Users, error)
+	GetUsersByIDs(ctx context.Context, ids []int64, strict bool) (models.UsersByIDs, error)
+	SearchUsers(ctx context.Context, search models.SearchUser) ([]models.Users, error)
+	AddUser(ctx context.Context, user models.User) error
+	UpdateUser(ctx context.Context, id int64, user models.User, version int64) (models.Users, error)
+	DeleteUser(ctx context.Context, id int64, version int64) error
+	GetUserHistory(ctx context.Context, id int64, pageSize int, pageToken string) (models.UserHistory, error)
+	GetUserAsOf(ctx context.Context, id int64, asOf time.Time) (models.Users, error)
+	WatchUsers(ctx context.Context, resumeToken string, send func(models.UserEvent) error) error
 }
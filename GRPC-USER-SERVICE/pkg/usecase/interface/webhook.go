@@ -0,0 +1,15 @@
+package interfaces
+
+import (
+	"context"
+	"grpc-user-service/pkg/utils/models"
+	"time"
+)
+
+type WebhookUseCase interface {
+	CreateWebhookSubscription(ctx context.Context, url string, eventTypes []string) (models.WebhookSubscription, error)
+	ListWebhookSubscriptions(ctx context.Context, pageSize int, pageToken string) (models.WebhookSubscriptionPage, error)
+	DeleteWebhookSubscription(ctx context.Context, id int64) error
+	ListWebhookDeliveries(ctx context.Context, subscriptionID int64, pageSize int, pageToken string) (models.WebhookDeliveryPage, error)
+	ReplayWebhookDeliveries(ctx context.Context, subscriptionID int64, deliveryID int64, since time.Time) (int64, error)
+}
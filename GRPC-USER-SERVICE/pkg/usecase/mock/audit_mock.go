@@ -0,0 +1,51 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: pkg\usecase\interface\audit.go
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	context "context"
+	models "grpc-user-service/pkg/utils/models"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockAuditUseCase is a mock of AuditUseCase interface.
+type MockAuditUseCase struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuditUseCaseMockRecorder
+}
+
+// MockAuditUseCaseMockRecorder is the mock recorder for MockAuditUseCase.
+type MockAuditUseCaseMockRecorder struct {
+	mock *MockAuditUseCase
+}
+
+// NewMockAuditUseCase creates a new mock instance.
+func NewMockAuditUseCase(ctrl *gomock.Controller) *MockAuditUseCase {
+	mock := &MockAuditUseCase{ctrl: ctrl}
+	mock.recorder = &MockAuditUseCaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAuditUseCase) EXPECT() *MockAuditUseCaseMockRecorder {
+	return m.recorder
+}
+
+// QueryAuditLog mocks base method.
+func (m *MockAuditUseCase) QueryAuditLog(ctx context.Context, query models.AuditQuery, pageSize int, pageToken string) (models.AuditPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueryAuditLog", ctx, query, pageSize, pageToken)
+	ret0, _ := ret[0].(models.AuditPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueryAuditLog indicates an expected call of QueryAuditLog.
+func (mr *MockAuditUseCaseMockRecorder) QueryAuditLog(ctx, query, pageSize, pageToken interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryAuditLog", reflect.TypeOf((*MockAuditUseCase)(nil).QueryAuditLog), ctx, query, pageSize, pageToken)
+}
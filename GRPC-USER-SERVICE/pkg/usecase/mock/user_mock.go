@@ -5,8 +5,10 @@
 package mock
 
 import (
+	context "context"
 	models "grpc-user-service/pkg/utils/models"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -35,60 +37,133 @@ func (m *MockUserUseCase) EXPECT() *MockUserUseCaseMockRecorder {
 }
 
 // AddUser mocks base method.
-func (m *MockUserUseCase) AddUser(user models.User) error {
+func (m *MockUserUseCase) AddUser(ctx context.Context, user models.User) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddUser", user)
+	ret := m.ctrl.Call(m, "AddUser", ctx, user)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // AddUser indicates an expected call of AddUser.
-func (mr *MockUserUseCaseMockRecorder) AddUser(user interface{}) *gomock.Call {
+func (mr *MockUserUseCaseMockRecorder) AddUser(ctx, user interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUser", reflect.TypeOf((*MockUserUseCase)(nil).AddUser), user)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUser", reflect.TypeOf((*MockUserUseCase)(nil).AddUser), ctx, user)
+}
+
+// DeleteUser mocks base method.
+func (m *MockUserUseCase) DeleteUser(ctx context.Context, id, version int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUser", ctx, id, version)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUser indicates an expected call of DeleteUser.
+func (mr *MockUserUseCaseMockRecorder) DeleteUser(ctx, id, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockUserUseCase)(nil).DeleteUser), ctx, id, version)
+}
+
+// GetUserAsOf mocks base method.
+func (m *MockUserUseCase) GetUserAsOf(ctx context.Context, id int64, asOf time.Time) (models.Users, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserAsOf", ctx, id, asOf)
+	ret0, _ := ret[0].(models.Users)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserAsOf indicates an expected call of GetUserAsOf.
+func (mr *MockUserUseCaseMockRecorder) GetUserAsOf(ctx, id, asOf interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserAsOf", reflect.TypeOf((*MockUserUseCase)(nil).GetUserAsOf), ctx, id, asOf)
 }
 
 // GetUserByID mocks base method.
-func (m *MockUserUseCase) GetUserByID(id int64) (models.Users, error) {
+func (m *MockUserUseCase) GetUserByID(ctx context.Context, id int64) (models.Users, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserByID", id)
+	ret := m.ctrl.Call(m, "GetUserByID", ctx, id)
 	ret0, _ := ret[0].(models.Users)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetUserByID indicates an expected call of GetUserByID.
-func (mr *MockUserUseCaseMockRecorder) GetUserByID(id interface{}) *gomock.Call {
+func (mr *MockUserUseCaseMockRecorder) GetUserByID(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockUserUseCase)(nil).GetUserByID), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockUserUseCase)(nil).GetUserByID), ctx, id)
+}
+
+// GetUserHistory mocks base method.
+func (m *MockUserUseCase) GetUserHistory(ctx context.Context, id int64, pageSize int, pageToken string) (models.UserHistory, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserHistory", ctx, id, pageSize, pageToken)
+	ret0, _ := ret[0].(models.UserHistory)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserHistory indicates an expected call of GetUserHistory.
+func (mr *MockUserUseCaseMockRecorder) GetUserHistory(ctx, id, pageSize, pageToken interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserHistory", reflect.TypeOf((*MockUserUseCase)(nil).GetUserHistory), ctx, id, pageSize, pageToken)
 }
 
 // GetUsersByIDs mocks base method.
-func (m *MockUserUseCase) GetUsersByIDs(ids []int64) ([]models.Users, error) {
+func (m *MockUserUseCase) GetUsersByIDs(ctx context.Context, ids []int64, strict bool) (models.UsersByIDs, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUsersByIDs", ids)
-	ret0, _ := ret[0].([]models.Users)
+	ret := m.ctrl.Call(m, "GetUsersByIDs", ctx, ids, strict)
+	ret0, _ := ret[0].(models.UsersByIDs)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetUsersByIDs indicates an expected call of GetUsersByIDs.
-func (mr *MockUserUseCaseMockRecorder) GetUsersByIDs(ids interface{}) *gomock.Call {
+func (mr *MockUserUseCaseMockRecorder) GetUsersByIDs(ctx, ids, strict interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsersByIDs", reflect.TypeOf((*MockUserUseCase)(nil).GetUsersByIDs), ids)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsersByIDs", reflect.TypeOf((*MockUserUseCase)(nil).GetUsersByIDs), ctx, ids, strict)
 }
 
 // SearchUsers mocks base method.
-func (m *MockUserUseCase) SearchUsers(search models.SearchUser) ([]models.Users, error) {
+func (m *MockUserUseCase) SearchUsers(ctx context.Context, search models.SearchUser) ([]models.Users, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SearchUsers", search)
+	ret := m.ctrl.Call(m, "SearchUsers", ctx, search)
 	ret0, _ := ret[0].([]models.Users)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // SearchUsers indicates an expected call of SearchUsers.
-func (mr *MockUserUseCaseMockRecorder) SearchUsers(search interface{}) *gomock.Call {
+func (mr *MockUserUseCaseMockRecorder) SearchUsers(ctx, search interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchUsers", reflect.TypeOf((*MockUserUseCase)(nil).SearchUsers), ctx, search)
+}
+
+// UpdateUser mocks base method.
+func (m *MockUserUseCase) UpdateUser(ctx context.Context, id int64, user models.User, version int64) (models.Users, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUser", ctx, id, user, version)
+	ret0, _ := ret[0].(models.Users)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUser indicates an expected call of UpdateUser.
+func (mr *MockUserUseCaseMockRecorder) UpdateUser(ctx, id, user, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockUserUseCase)(nil).UpdateUser), ctx, id, user, version)
+}
+
+// WatchUsers mocks base method.
+func (m *MockUserUseCase) WatchUsers(ctx context.Context, resumeToken string, send func(models.UserEvent) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WatchUsers", ctx, resumeToken, send)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WatchUsers indicates an expected call of WatchUsers.
+func (mr *MockUserUseCaseMockRecorder) WatchUsers(ctx, resumeToken, send interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchUsers", reflect.TypeOf((*MockUserUseCase)(nil).SearchUsers), search)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WatchUsers", reflect.TypeOf((*MockUserUseCase)(nil).WatchUsers), ctx, resumeToken, send)
 }
@@ -0,0 +1,111 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: pkg\usecase\interface\webhook.go
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	context "context"
+	models "grpc-user-service/pkg/utils/models"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockWebhookUseCase is a mock of WebhookUseCase interface.
+type MockWebhookUseCase struct {
+	ctrl     *gomock.Controller
+	recorder *MockWebhookUseCaseMockRecorder
+}
+
+// MockWebhookUseCaseMockRecorder is the mock recorder for MockWebhookUseCase.
+type MockWebhookUseCaseMockRecorder struct {
+	mock *MockWebhookUseCase
+}
+
+// NewMockWebhookUseCase creates a new mock instance.
+func NewMockWebhookUseCase(ctrl *gomock.Controller) *MockWebhookUseCase {
+	mock := &MockWebhookUseCase{ctrl: ctrl}
+	mock.recorder = &MockWebhookUseCaseMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWebhookUseCase) EXPECT() *MockWebhookUseCaseMockRecorder {
+	return m.recorder
+}
+
+// CreateWebhookSubscription mocks base method.
+func (m *MockWebhookUseCase) CreateWebhookSubscription(ctx context.Context, url string, eventTypes []string) (models.WebhookSubscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWebhookSubscription", ctx, url, eventTypes)
+	ret0, _ := ret[0].(models.WebhookSubscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateWebhookSubscription indicates an expected call of CreateWebhookSubscription.
+func (mr *MockWebhookUseCaseMockRecorder) CreateWebhookSubscription(ctx, url, eventTypes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhookSubscription", reflect.TypeOf((*MockWebhookUseCase)(nil).CreateWebhookSubscription), ctx, url, eventTypes)
+}
+
+// DeleteWebhookSubscription mocks base method.
+func (m *MockWebhookUseCase) DeleteWebhookSubscription(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhookSubscription", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhookSubscription indicates an expected call of DeleteWebhookSubscription.
+func (mr *MockWebhookUseCaseMockRecorder) DeleteWebhookSubscription(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhookSubscription", reflect.TypeOf((*MockWebhookUseCase)(nil).DeleteWebhookSubscription), ctx, id)
+}
+
+// ListWebhookDeliveries mocks base method.
+func (m *MockWebhookUseCase) ListWebhookDeliveries(ctx context.Context, subscriptionID int64, pageSize int, pageToken string) (models.WebhookDeliveryPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWebhookDeliveries", ctx, subscriptionID, pageSize, pageToken)
+	ret0, _ := ret[0].(models.WebhookDeliveryPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListWebhookDeliveries indicates an expected call of ListWebhookDeliveries.
+func (mr *MockWebhookUseCaseMockRecorder) ListWebhookDeliveries(ctx, subscriptionID, pageSize, pageToken interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWebhookDeliveries", reflect.TypeOf((*MockWebhookUseCase)(nil).ListWebhookDeliveries), ctx, subscriptionID, pageSize, pageToken)
+}
+
+// ListWebhookSubscriptions mocks base method.
+func (m *MockWebhookUseCase) ListWebhookSubscriptions(ctx context.Context, pageSize int, pageToken string) (models.WebhookSubscriptionPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWebhookSubscriptions", ctx, pageSize, pageToken)
+	ret0, _ := ret[0].(models.WebhookSubscriptionPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListWebhookSubscriptions indicates an expected call of ListWebhookSubscriptions.
+func (mr *MockWebhookUseCaseMockRecorder) ListWebhookSubscriptions(ctx, pageSize, pageToken interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWebhookSubscriptions", reflect.TypeOf((*MockWebhookUseCase)(nil).ListWebhookSubscriptions), ctx, pageSize, pageToken)
+}
+
+// ReplayWebhookDeliveries mocks base method.
+func (m *MockWebhookUseCase) ReplayWebhookDeliveries(ctx context.Context, subscriptionID, deliveryID int64, since time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplayWebhookDeliveries", ctx, subscriptionID, deliveryID, since)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReplayWebhookDeliveries indicates an expected call of ReplayWebhookDeliveries.
+func (mr *MockWebhookUseCaseMockRecorder) ReplayWebhookDeliveries(ctx, subscriptionID, deliveryID, since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplayWebhookDeliveries", reflect.TypeOf((*MockWebhookUseCase)(nil).ReplayWebhookDeliveries), ctx, subscriptionID, deliveryID, since)
+}
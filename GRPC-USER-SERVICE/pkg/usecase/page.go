@@ -0,0 +1,28 @@
+package usecase
+
+import (
+	"encoding/base64"
+	"grpc-user-service/pkg/domain"
+	"strconv"
+)
+
+// defaultPageSize is the page size of listings when the caller does not ask
+// for one.
+const defaultPageSize = 50
+
+// Page tokens carry the id of the last item of a page, opaque to clients.
+func encodePageToken(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodePageToken(token string) (int64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, domain.ErrInvalidPageToken
+	}
+	id, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, domain.ErrInvalidPageToken
+	}
+	return id, nil
+}
@@ -1,99 +1,146 @@
 package usecase
 
 import (
-	"errors"
+	"context"
+	"fmt"
+	"grpc-user-service/pkg/domain"
+	"grpc-user-service/pkg/phone"
 	interfaces "grpc-user-service/pkg/repository/interface"
 	server "grpc-user-service/pkg/usecase/interface"
 	"grpc-user-service/pkg/utils/models"
+	"time"
 )
 
 type userUseCase struct {
 	userRepository interfaces.UserRepository
+	notifier       interfaces.ChangeNotifier
+	phoneRegion    string
 }
 
-func NewUserUseCase(repository interfaces.UserRepository) server.UserUseCase {
+// NewUserUseCase returns the user use case. notifier wakes up watchers of the
+// change feed. phoneRegion is the region used to read phone numbers written
+// without a country code when the request does not carry its own region hint.
+func NewUserUseCase(repository interfaces.UserRepository, notifier interfaces.ChangeNotifier, phoneRegion string) server.UserUseCase {
 	return &userUseCase{
 		userRepository: repository,
+		notifier:       notifier,
+		phoneRegion:    phoneRegion,
 	}
 }
 
-func (u *userUseCase) GetUserByID(id int64) (models.Users, error) {
-	userExist := u.userRepository.CheckUserAvailabilityWithUserID(id)
-	if !userExist {
-		return models.Users{}, errors.New("user doesn't exist")
+func (u *userUseCase) normalizePhone(number, region string) (string, error) {
+	if region == "" {
+		region = u.phoneRegion
 	}
-	result, err := u.userRepository.GetUserByID(id)
+	return phone.Normalize(number, region)
+}
+
+func (u *userUseCase) GetUserByID(ctx context.Context, id int64) (models.Users, error) {
+	result, err := u.userRepository.GetUserByID(ctx, id)
 	if err != nil {
 		return models.Users{}, err
 	}
 	return result, nil
 }
 
-func (u *userUseCase) GetUsersByIDs(ids []int64) ([]models.Users, error) {
-	userExist := u.userRepository.CheckUserAvailabilityWithUserIDs(ids)
-	if !userExist {
-		return []models.Users{}, errors.New("user doesn't exist")
-	}
-	result, err := u.userRepository.GetUsersByIDs(ids)
+// GetUsersByIDs returns the users found for ids together with the ids that
+// do not exist. In strict mode any missing id fails the whole call.
+func (u *userUseCase) GetUsersByIDs(ctx context.Context, ids []int64, strict bool) (models.UsersByIDs, error) {
+	users, err := u.userRepository.GetUsersByIDs(ctx, ids)
 	if err != nil {
-		return []models.Users{}, err
+		return models.UsersByIDs{}, err
 	}
-	return result, nil
-}
-
-func (u *userUseCase) SearchUsers(search models.SearchUser) ([]models.Users, error) {
-	var result []models.Users
-	foundUsers := make(map[int64]bool)
-
-	if search.City != "" {
-		res, err := u.userRepository.SearchCity(search.City)
-		if err != nil {
-			return nil, err
-		}
-		for _, user := range res {
-			if !foundUsers[user.ID] {
-				result = append(result, user)
-				foundUsers[user.ID] = true
-			}
-		}
+	found := make(map[int64]bool, len(users))
+	for _, user := range users {
+		found[user.ID] = true
 	}
-
-	if search.Phone != "" {
-		res, err := u.userRepository.SearchPhone(search.Phone)
-		if err != nil {
-			return nil, err
-		}
-		for _, user := range res {
-			if !foundUsers[user.ID] {
-				result = append(result, user)
-				foundUsers[user.ID] = true
-			}
+	var notFound []int64
+	for _, id := range ids {
+		if !found[id] {
+			notFound = append(notFound, id)
+			found[id] = true
 		}
 	}
+	if strict && len(notFound) > 0 {
+		return models.UsersByIDs{}, fmt.Errorf("%w: ids %v", domain.ErrUserNotFound, notFound)
+	}
+	return models.UsersByIDs{Users: users, NotFoundIDs: notFound}, nil
+}
 
-	if search.Married || !search.Married {
-		res, err := u.userRepository.SearchMarried(search.Married)
+// SearchUsers returns the users matching search. Filtering and ordering are
+// done by the database in a single query.
+func (u *userUseCase) SearchUsers(ctx context.Context, search models.SearchUser) ([]models.Users, error) {
+	if search.Phone != "" {
+		number, err := u.normalizePhone(search.Phone, search.Region)
 		if err != nil {
 			return nil, err
 		}
-		for _, user := range res {
-			if !foundUsers[user.ID] {
-				result = append(result, user)
-				foundUsers[user.ID] = true
-			}
-		}
+		search.Phone = number
 	}
-	return result, nil
+	return u.userRepository.SearchUsers(ctx, search)
 }
 
-func (u *userUseCase) AddUser(user models.User) error {
-	phone := u.userRepository.CheckUserExistsByPhone(user.Phone)
-	if phone {
-		return errors.New("user with this phone is already exists")
+func (u *userUseCase) AddUser(ctx context.Context, user models.User) error {
+	number, err := u.normalizePhone(user.Phone, user.Region)
+	if err != nil {
+		return err
 	}
-	err := u.userRepository.AddUser(user)
+	user.Phone = number
+
+	// The unique index on phone rejects duplicates, a separate existence
+	// check would race with concurrent inserts.
+	err = u.userRepository.AddUser(ctx, user)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// UpdateUser replaces the fields of user id, provided nobody wrote it since
+// the caller read version.
+func (u *userUseCase) UpdateUser(ctx context.Context, id int64, user models.User, version int64) (models.Users, error) {
+	number, err := u.normalizePhone(user.Phone, user.Region)
+	if err != nil {
+		return models.Users{}, err
+	}
+	user.Phone = number
+	return u.userRepository.UpdateUser(ctx, id, user, version)
+}
+
+// DeleteUser deletes user id, provided nobody wrote it since the caller read
+// version.
+func (u *userUseCase) DeleteUser(ctx context.Context, id int64, version int64) error {
+	return u.userRepository.DeleteUser(ctx, id, version)
+}
+
+// GetUserHistory returns a page of the changes of user id, newest first.
+// pageToken is empty for the first page and the NextPageToken of the
+// previous page otherwise.
+func (u *userUseCase) GetUserHistory(ctx context.Context, id int64, pageSize int, pageToken string) (models.UserHistory, error) {
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	var before int64
+	if pageToken != "" {
+		var err error
+		if before, err = decodePageToken(pageToken); err != nil {
+			return models.UserHistory{}, err
+		}
+	}
+	// One change more than asked for tells whether there is another page.
+	changes, err := u.userRepository.GetUserHistory(ctx, id, before, pageSize+1)
+	if err != nil {
+		return models.UserHistory{}, err
+	}
+	history := models.UserHistory{Changes: changes}
+	if len(changes) > pageSize {
+		history.Changes = changes[:pageSize]
+		history.NextPageToken = encodePageToken(changes[pageSize-1].ID)
+	}
+	return history, nil
+}
+
+// GetUserAsOf returns user id as it was at asOf.
+func (u *userUseCase) GetUserAsOf(ctx context.Context, id int64, asOf time.Time) (models.Users, error) {
+	return u.userRepository.GetUserAsOf(ctx, id, asOf)
+}
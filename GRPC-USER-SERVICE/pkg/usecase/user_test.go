@@ -1,10 +1,15 @@
 package usecase
 
 import (
+	"context"
 	"errors"
+	"grpc-user-service/pkg/domain"
+	"grpc-user-service/pkg/phone"
 	mock_repository "grpc-user-service/pkg/repository/mock"
 	"grpc-user-service/pkg/utils/models"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
@@ -15,28 +20,26 @@ func Test_GetUserByID(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockRepo := mock_repository.NewMockUserRepository(ctrl)
-	useCase := NewUserUseCase(mockRepo)
+	useCase := NewUserUseCase(mockRepo, nil, "IN")
 
 	testID := int64(1)
 
-	mockRepo.EXPECT().CheckUserAvailabilityWithUserID(testID).Return(true)
-	mockRepo.EXPECT().GetUserByID(testID).Return(models.Users{ID: testID, Fname: "Test User", City: "Kannur", Phone: "0123456789", Height: 175.6, Married: true}, nil)
+	mockRepo.EXPECT().GetUserByID(gomock.Any(), testID).Return(models.Users{ID: testID, Fname: "Test User", City: "Kannur", Phone: "0123456789", Height: 175.6, Married: true}, nil)
 
-	result, err := useCase.GetUserByID(testID)
+	result, err := useCase.GetUserByID(context.Background(), testID)
 
 	assert.NoError(t, err)
 	assert.Equal(t, "Test User", result.Fname)
 
-	mockRepo.EXPECT().CheckUserAvailabilityWithUserID(testID).Return(false)
+	mockRepo.EXPECT().GetUserByID(gomock.Any(), testID).Return(models.Users{}, domain.ErrUserNotFound)
 
-	_, err = useCase.GetUserByID(testID)
+	_, err = useCase.GetUserByID(context.Background(), testID)
 	assert.Error(t, err)
 	assert.Equal(t, "user doesn't exist", err.Error())
 
-	mockRepo.EXPECT().CheckUserAvailabilityWithUserID(testID).Return(true)
-	mockRepo.EXPECT().GetUserByID(testID).Return(models.Users{}, errors.New("repository error"))
+	mockRepo.EXPECT().GetUserByID(gomock.Any(), testID).Return(models.Users{}, errors.New("repository error"))
 
-	_, err = useCase.GetUserByID(testID)
+	_, err = useCase.GetUserByID(context.Background(), testID)
 	assert.Error(t, err)
 	assert.Equal(t, "repository error", err.Error())
 }
@@ -46,33 +49,39 @@ func Test_GetUsersByIDs(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockRepo := mock_repository.NewMockUserRepository(ctrl)
-	useCase := NewUserUseCase(mockRepo)
+	useCase := NewUserUseCase(mockRepo, nil, "IN")
 
 	testIDs := []int64{1, 2, 3}
 
-	mockRepo.EXPECT().CheckUserAvailabilityWithUserIDs(testIDs).Return(true)
-	mockRepo.EXPECT().GetUsersByIDs(testIDs).Return([]models.Users{
+	mockRepo.EXPECT().GetUsersByIDs(gomock.Any(), testIDs).Return([]models.Users{
 		{ID: 1, Fname: "User1", City: "Kannur", Phone: "0123456789", Height: 175.6, Married: true},
 		{ID: 2, Fname: "User2", City: "Trissur", Phone: "0123456729", Height: 165.6, Married: false},
 		{ID: 3, Fname: "User3", City: "Kozhikode", Phone: "0123456589", Height: 155.6, Married: true},
 	}, nil)
 
-	result, err := useCase.GetUsersByIDs(testIDs)
+	result, err := useCase.GetUsersByIDs(context.Background(), testIDs, false)
 
 	assert.NoError(t, err)
-	assert.Len(t, result, 3)
-	assert.Equal(t, "User1", result[0].Fname)
+	assert.Len(t, result.Users, 3)
+	assert.Equal(t, "User1", result.Users[0].Fname)
+	assert.Empty(t, result.NotFoundIDs)
 
-	mockRepo.EXPECT().CheckUserAvailabilityWithUserIDs(testIDs).Return(false)
+	mockRepo.EXPECT().GetUsersByIDs(gomock.Any(), []int64{4, 2, 5, 4}).Return([]models.Users{
+		{ID: 2, Fname: "User2", City: "Trissur", Phone: "0123456729", Height: 165.6, Married: false},
+	}, nil).Times(2)
 
-	_, err = useCase.GetUsersByIDs(testIDs)
-	assert.Error(t, err)
-	assert.Equal(t, "user doesn't exist", err.Error())
+	result, err = useCase.GetUsersByIDs(context.Background(), []int64{4, 2, 5, 4}, false)
+	assert.NoError(t, err)
+	assert.Len(t, result.Users, 1)
+	assert.Equal(t, []int64{4, 5}, result.NotFoundIDs)
 
-	mockRepo.EXPECT().CheckUserAvailabilityWithUserIDs(testIDs).Return(true)
-	mockRepo.EXPECT().GetUsersByIDs(testIDs).Return([]models.Users{}, errors.New("repository error"))
+	_, err = useCase.GetUsersByIDs(context.Background(), []int64{4, 2, 5, 4}, true)
+	assert.ErrorIs(t, err, domain.ErrUserNotFound)
+	assert.Equal(t, "user doesn't exist: ids [4 5]", err.Error())
 
-	_, err = useCase.GetUsersByIDs(testIDs)
+	mockRepo.EXPECT().GetUsersByIDs(gomock.Any(), testIDs).Return(nil, errors.New("repository error"))
+
+	_, err = useCase.GetUsersByIDs(context.Background(), testIDs, false)
 	assert.Error(t, err)
 	assert.Equal(t, "repository error", err.Error())
 }
@@ -82,31 +91,64 @@ func Test_SearchUsers(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockRepo := mock_repository.NewMockUserRepository(ctrl)
-	useCase := NewUserUseCase(mockRepo)
+	useCase := NewUserUseCase(mockRepo, nil, "IN")
 
+	married := true
 	testSearch := models.SearchUser{
 		City:    "TestCity",
-		Phone:   "1234567890",
-		Married: true,
+		Phone:   "098765 43210",
+		Married: &married,
 	}
+	normalized := testSearch
+	normalized.Phone = "+919876543210"
 
-	mockRepo.EXPECT().SearchCity(testSearch.City).Return([]models.Users{
-		{ID: 1, Fname: "User1", City: testSearch.City, Phone: "0123456789", Height: 175.6, Married: true},
-	}, nil).Times(1)
-
-	mockRepo.EXPECT().SearchPhone(testSearch.Phone).Return([]models.Users{
-		{ID: 1, Fname: "User1", City: "Kannur", Phone: testSearch.Phone, Height: 175.6, Married: true},
+	mockRepo.EXPECT().SearchUsers(gomock.Any(), normalized).Return([]models.Users{
+		{ID: 1, Fname: "User1", City: testSearch.City, Phone: normalized.Phone, Height: 175.6, Married: true},
 	}, nil).Times(1)
 
-	mockRepo.EXPECT().SearchMarried(testSearch.Married).Return([]models.Users{
-		{ID: 1, Fname: "User1", City: "Kannur", Phone: testSearch.Phone, Height: 175.6, Married: testSearch.Married},
-	}, nil).Times(1)
-
-	result, err := useCase.SearchUsers(testSearch)
+	result, err := useCase.SearchUsers(context.Background(), testSearch)
 
 	assert.NoError(t, err)
 	assert.Len(t, result, 1)
 	assert.Equal(t, "User1", result[0].Fname)
+
+	_, err = useCase.SearchUsers(context.Background(), models.SearchUser{Phone: "12345"})
+	assert.ErrorIs(t, err, phone.ErrInvalid)
+}
+
+func Test_SearchUsersFilterAndSort(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock_repository.NewMockUserRepository(ctrl)
+	useCase := NewUserUseCase(mockRepo, nil, "IN")
+
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC) }
+	unmarried := false
+
+	// The bounds, order and married filter reach the repository as
+	// requested; a search without married does not filter on it.
+	tests := []struct {
+		name   string
+		search models.SearchUser
+	}{
+		{name: "by id", search: models.SearchUser{}},
+		{name: "by updated_at descending", search: models.SearchUser{SortBy: models.SortByUpdatedAt, Descending: true}},
+		{name: "created range", search: models.SearchUser{CreatedAfter: day(3), CreatedBefore: day(5)}},
+		{name: "updated range", search: models.SearchUser{UpdatedAfter: day(4), UpdatedBefore: day(6), SortBy: models.SortByCreatedAt}},
+		{name: "unmarried", search: models.SearchUser{Married: &unmarried, CreatedAfter: day(3)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo.EXPECT().SearchUsers(gomock.Any(), tt.search).Return([]models.Users{{ID: 1}}, nil)
+
+			result, err := useCase.SearchUsers(context.Background(), tt.search)
+
+			assert.NoError(t, err)
+			assert.Equal(t, []models.Users{{ID: 1}}, result)
+		})
+	}
 }
 
 func Test_AddUser(t *testing.T) {
@@ -114,33 +156,162 @@ func Test_AddUser(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockRepo := mock_repository.NewMockUserRepository(ctrl)
-	useCase := NewUserUseCase(mockRepo)
+	useCase := NewUserUseCase(mockRepo, nil, "IN")
 
 	testUser := models.User{
 		Fname:   "Test User",
-		Phone:   "1234567890",
+		Phone:   "(650) 253-0000",
 		City:    "Test City",
 		Height:  170.5,
 		Married: false,
+		Region:  "US",
 	}
+	storedUser := testUser
+	storedUser.Phone = "+16502530000"
 
-	mockRepo.EXPECT().CheckUserExistsByPhone(testUser.Phone).Return(false)
-	mockRepo.EXPECT().AddUser(testUser).Return(nil)
+	mockRepo.EXPECT().AddUser(gomock.Any(), storedUser).Return(nil)
 
-	err := useCase.AddUser(testUser)
+	err := useCase.AddUser(context.Background(), testUser)
 
 	assert.NoError(t, err)
 
-	mockRepo.EXPECT().CheckUserExistsByPhone(testUser.Phone).Return(true)
+	mockRepo.EXPECT().AddUser(gomock.Any(), storedUser).Return(domain.ErrUserAlreadyExists)
 
-	err = useCase.AddUser(testUser)
-	assert.Error(t, err)
+	err = useCase.AddUser(context.Background(), testUser)
+	assert.ErrorIs(t, err, domain.ErrUserAlreadyExists)
 	assert.Equal(t, "user with this phone is already exists", err.Error())
 
-	mockRepo.EXPECT().CheckUserExistsByPhone(testUser.Phone).Return(false)
-	mockRepo.EXPECT().AddUser(testUser).Return(errors.New("repository error"))
+	mockRepo.EXPECT().AddUser(gomock.Any(), storedUser).Return(errors.New("repository error"))
 
-	err = useCase.AddUser(testUser)
+	err = useCase.AddUser(context.Background(), testUser)
 	assert.Error(t, err)
 	assert.Equal(t, "repository error", err.Error())
+
+	invalidUser := testUser
+	invalidUser.Phone = "12345"
+	err = useCase.AddUser(context.Background(), invalidUser)
+	assert.ErrorIs(t, err, phone.ErrInvalid)
+}
+
+func Test_AddUserConcurrentSamePhone(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock_repository.NewMockUserRepository(ctrl)
+	useCase := NewUserUseCase(mockRepo, nil, "IN")
+
+	// The mock stands in for the unique index on phone.
+	var mu sync.Mutex
+	stored := make(map[string]bool)
+	mockRepo.EXPECT().AddUser(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, user models.User) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if stored[user.Phone] {
+			return domain.ErrUserAlreadyExists
+		}
+		stored[user.Phone] = true
+		return nil
+	}).Times(3)
+
+	phones := []string{"9876543210", "+91 98765 43210", "098765-43210"}
+	errs := make([]error, len(phones))
+	var wg sync.WaitGroup
+	for i, number := range phones {
+		wg.Add(1)
+		go func(i int, number string) {
+			defer wg.Done()
+			errs[i] = useCase.AddUser(context.Background(), models.User{Fname: "Akhil", City: "Kannur", Phone: number, Height: 170})
+		}(i, number)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		assert.ErrorIs(t, err, domain.ErrUserAlreadyExists)
+	}
+	assert.Equal(t, 1, succeeded)
+	assert.Equal(t, map[string]bool{"+919876543210": true}, stored)
+}
+
+func Test_UpdateUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock_repository.NewMockUserRepository(ctrl)
+	useCase := NewUserUseCase(mockRepo, nil, "IN")
+
+	testUser := models.User{Fname: "Test User", Phone: "98765 43210", City: "Kochi", Height: 170.5}
+	storedUser := testUser
+	storedUser.Phone = "+919876543210"
+	updated := models.Users{ID: 1, Fname: "Test User", Phone: "+919876543210", City: "Kochi", Height: 170.5, Version: 3}
+
+	mockRepo.EXPECT().UpdateUser(gomock.Any(), int64(1), storedUser, int64(2)).Return(updated, nil)
+	result, err := useCase.UpdateUser(context.Background(), 1, testUser, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, updated, result)
+
+	mockRepo.EXPECT().UpdateUser(gomock.Any(), int64(1), storedUser, int64(2)).Return(models.Users{}, domain.ErrVersionMismatch)
+	_, err = useCase.UpdateUser(context.Background(), 1, testUser, 2)
+	assert.ErrorIs(t, err, domain.ErrVersionMismatch)
+
+	invalidUser := testUser
+	invalidUser.Phone = "12345"
+	_, err = useCase.UpdateUser(context.Background(), 1, invalidUser, 2)
+	assert.ErrorIs(t, err, phone.ErrInvalid)
+}
+
+func Test_DeleteUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock_repository.NewMockUserRepository(ctrl)
+	useCase := NewUserUseCase(mockRepo, nil, "IN")
+
+	mockRepo.EXPECT().DeleteUser(gomock.Any(), int64(1), int64(2)).Return(nil)
+	assert.NoError(t, useCase.DeleteUser(context.Background(), 1, 2))
+
+	mockRepo.EXPECT().DeleteUser(gomock.Any(), int64(1), int64(2)).Return(domain.ErrUserNotFound)
+	assert.ErrorIs(t, useCase.DeleteUser(context.Background(), 1, 2), domain.ErrUserNotFound)
+}
+
+func Test_GetUserHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mock_repository.NewMockUserRepository(ctrl)
+	useCase := NewUserUseCase(mockRepo, nil, "IN")
+
+	changes := func(ids ...int64) []models.UserChange {
+		var result []models.UserChange
+		for _, id := range ids {
+			result = append(result, models.UserChange{ID: id, UserID: 1})
+		}
+		return result
+	}
+
+	// A full first page has a token leading to the next one.
+	mockRepo.EXPECT().GetUserHistory(gomock.Any(), int64(1), int64(0), 3).Return(changes(9, 8, 7), nil)
+	history, err := useCase.GetUserHistory(context.Background(), 1, 2, "")
+	assert.NoError(t, err)
+	assert.Equal(t, changes(9, 8), history.Changes)
+	assert.NotEmpty(t, history.NextPageToken)
+
+	mockRepo.EXPECT().GetUserHistory(gomock.Any(), int64(1), int64(8), 3).Return(changes(7), nil)
+	history, err = useCase.GetUserHistory(context.Background(), 1, 2, history.NextPageToken)
+	assert.NoError(t, err)
+	assert.Equal(t, changes(7), history.Changes)
+	assert.Empty(t, history.NextPageToken)
+
+	mockRepo.EXPECT().GetUserHistory(gomock.Any(), int64(1), int64(0), defaultPageSize+1).Return(nil, nil)
+	_, err = useCase.GetUserHistory(context.Background(), 1, 0, "")
+	assert.NoError(t, err)
+
+	for _, token := range []string{"not base64!", encodePageToken(0), "YWJj"} {
+		_, err = useCase.GetUserHistory(context.Background(), 1, 2, token)
+		assert.ErrorIs(t, err, domain.ErrInvalidPageToken, token)
+	}
 }
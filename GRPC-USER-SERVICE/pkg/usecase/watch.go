@@ -0,0 +1,114 @@
+package usecase
+
+import (
+	"context"
+	"encoding/base64"
+	"grpc-user-service/pkg/domain"
+	"grpc-user-service/pkg/utils/models"
+	"strconv"
+	"strings"
+)
+
+// watchBatch is the number of changes read from the feed at a time.
+const watchBatch = 100
+
+// eventTypes maps the recorded operations to the events of the feed.
+// Snapshots are not changes and never reach it.
+var eventTypes = map[string]string{
+	models.ChangeInsert: models.EventCreated,
+	models.ChangeUpdate: models.EventUpdated,
+	models.ChangeDelete: models.EventDeleted,
+}
+
+// WatchUsers sends every change of a user made after resumeToken to send, in
+// feed order, until ctx is done, send fails or the service stops. An empty
+// resumeToken starts at the current end of the feed.
+func (u *userUseCase) WatchUsers(ctx context.Context, resumeToken string, send func(models.UserEvent) error) error {
+	// Subscribe before reading so that no notification falls in between.
+	wake, unsubscribe := u.notifier.Subscribe()
+	defer unsubscribe()
+
+	var cursor models.ChangeCursor
+	var err error
+	if resumeToken != "" {
+		if cursor, err = decodeResumeToken(resumeToken); err != nil {
+			return err
+		}
+	} else if cursor, err = u.userRepository.GetChangeCursor(ctx); err != nil {
+		return err
+	}
+
+	for {
+		changes, err := u.userRepository.GetChanges(ctx, cursor, watchBatch)
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			cursor = models.ChangeCursor{TxID: change.TxID, ID: change.ID}
+			event, ok := userEvent(change)
+			if !ok {
+				continue
+			}
+			event.ResumeToken = encodeResumeToken(cursor)
+			if err := send(event); err != nil {
+				return err
+			}
+		}
+		if len(changes) == watchBatch {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case _, ok := <-wake:
+			if !ok {
+				return domain.ErrShuttingDown
+			}
+		}
+	}
+}
+
+func userEvent(change models.UserChange) (models.UserEvent, bool) {
+	eventType, ok := eventTypes[change.Operation]
+	if !ok {
+		return models.UserEvent{}, false
+	}
+	user := change.New
+	if change.Operation == models.ChangeDelete {
+		user = change.Old
+	}
+	if user == nil {
+		return models.UserEvent{}, false
+	}
+	return models.UserEvent{
+		Type:      eventType,
+		User:      *user,
+		Actor:     change.Actor,
+		ChangedAt: change.ChangedAt,
+	}, true
+}
+
+// Resume tokens carry the feed position of an event, opaque to clients.
+func encodeResumeToken(cursor models.ChangeCursor) string {
+	position := strconv.FormatUint(cursor.TxID, 10) + "." + strconv.FormatInt(cursor.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(position))
+}
+
+func decodeResumeToken(token string) (models.ChangeCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return models.ChangeCursor{}, domain.ErrInvalidResumeToken
+	}
+	txID, id, ok := strings.Cut(string(b), ".")
+	if !ok {
+		return models.ChangeCursor{}, domain.ErrInvalidResumeToken
+	}
+	var cursor models.ChangeCursor
+	if cursor.TxID, err = strconv.ParseUint(txID, 10, 64); err != nil || cursor.TxID == 0 {
+		return models.ChangeCursor{}, domain.ErrInvalidResumeToken
+	}
+	if cursor.ID, err = strconv.ParseInt(id, 10, 64); err != nil || cursor.ID <= 0 {
+		return models.ChangeCursor{}, domain.ErrInvalidResumeToken
+	}
+	return cursor, nil
+}
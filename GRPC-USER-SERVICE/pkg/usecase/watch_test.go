@@ -0,0 +1,119 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"grpc-user-service/pkg/domain"
+	mock_repository "grpc-user-service/pkg/repository/mock"
+	"grpc-user-service/pkg/utils/models"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_WatchUsers(t *testing.T) {
+	changedAt := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	changes := []models.UserChange{
+		{ID: 5, UserID: 1, Operation: models.ChangeInsert, New: &models.Users{ID: 1, Version: 1}, Actor: "alice", ChangedAt: changedAt, TxID: 101},
+		{ID: 7, UserID: 2, Operation: models.ChangeDelete, Old: &models.Users{ID: 2, Version: 3}, Actor: "bob", ChangedAt: changedAt, TxID: 102},
+	}
+	events := []models.UserEvent{
+		{Type: models.EventCreated, User: models.Users{ID: 1, Version: 1}, Actor: "alice", ChangedAt: changedAt, ResumeToken: encodeResumeToken(models.ChangeCursor{TxID: 101, ID: 5})},
+		{Type: models.EventDeleted, User: models.Users{ID: 2, Version: 3}, Actor: "bob", ChangedAt: changedAt, ResumeToken: encodeResumeToken(models.ChangeCursor{TxID: 102, ID: 7})},
+	}
+	end := models.ChangeCursor{TxID: 102, ID: 7}
+
+	tests := []struct {
+		name    string
+		token   string
+		stub    func(mockRepo *mock_repository.MockUserRepository, wake chan struct{}, cancel context.CancelFunc)
+		sendErr error
+		want    []models.UserEvent
+		wantErr error
+	}{
+		{
+			name: "from the end of the feed",
+			stub: func(mockRepo *mock_repository.MockUserRepository, wake chan struct{}, cancel context.CancelFunc) {
+				wake <- struct{}{}
+				mockRepo.EXPECT().GetChangeCursor(gomock.Any()).Return(models.ChangeCursor{TxID: 100}, nil)
+				gomock.InOrder(
+					mockRepo.EXPECT().GetChanges(gomock.Any(), models.ChangeCursor{TxID: 100}, watchBatch).Return(changes, nil),
+					mockRepo.EXPECT().GetChanges(gomock.Any(), end, watchBatch).DoAndReturn(
+						func(ctx context.Context, after models.ChangeCursor, limit int) ([]models.UserChange, error) {
+							cancel()
+							return nil, nil
+						}),
+				)
+			},
+			want:    events,
+			wantErr: context.Canceled,
+		},
+		{
+			name:  "resumed until shutdown",
+			token: encodeResumeToken(models.ChangeCursor{TxID: 101, ID: 5}),
+			stub: func(mockRepo *mock_repository.MockUserRepository, wake chan struct{}, cancel context.CancelFunc) {
+				close(wake)
+				mockRepo.EXPECT().GetChanges(gomock.Any(), models.ChangeCursor{TxID: 101, ID: 5}, watchBatch).Return(changes[1:], nil)
+			},
+			want:    events[1:],
+			wantErr: domain.ErrShuttingDown,
+		},
+		{
+			name: "send fails",
+			stub: func(mockRepo *mock_repository.MockUserRepository, wake chan struct{}, cancel context.CancelFunc) {
+				mockRepo.EXPECT().GetChangeCursor(gomock.Any()).Return(models.ChangeCursor{TxID: 100}, nil)
+				mockRepo.EXPECT().GetChanges(gomock.Any(), models.ChangeCursor{TxID: 100}, watchBatch).Return(changes, nil)
+			},
+			sendErr: errors.New("error"),
+			want:    events[:1],
+			wantErr: errors.New("error"),
+		},
+		{
+			name:    "invalid token",
+			token:   encodePageToken(5),
+			stub:    func(mockRepo *mock_repository.MockUserRepository, wake chan struct{}, cancel context.CancelFunc) {},
+			wantErr: domain.ErrInvalidResumeToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockRepo := mock_repository.NewMockUserRepository(ctrl)
+			mockNotifier := mock_repository.NewMockChangeNotifier(ctrl)
+			useCase := NewUserUseCase(mockRepo, mockNotifier, "IN")
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			wake := make(chan struct{}, 1)
+			unsubscribed := false
+			mockNotifier.EXPECT().Subscribe().Return((<-chan struct{})(wake), func() { unsubscribed = true })
+			tt.stub(mockRepo, wake, cancel)
+
+			var got []models.UserEvent
+			err := useCase.WatchUsers(ctx, tt.token, func(event models.UserEvent) error {
+				got = append(got, event)
+				return tt.sendErr
+			})
+
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, got)
+			assert.True(t, unsubscribed)
+		})
+	}
+}
+
+func Test_decodeResumeToken(t *testing.T) {
+	cursor := models.ChangeCursor{TxID: 18446744073709551000, ID: 42}
+	got, err := decodeResumeToken(encodeResumeToken(cursor))
+	assert.NoError(t, err)
+	assert.Equal(t, cursor, got)
+
+	for _, token := range []string{"not base64!", "MTAw", "MC40Mg", "MTAwLjA", "MTAwLjQyeA"} {
+		_, err := decodeResumeToken(token)
+		assert.ErrorIs(t, err, domain.ErrInvalidResumeToken, token)
+	}
+}
@@ -0,0 +1,146 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"grpc-user-service/pkg/domain"
+	interfaces "grpc-user-service/pkg/repository/interface"
+	server "grpc-user-service/pkg/usecase/interface"
+	"grpc-user-service/pkg/utils/models"
+	"grpc-user-service/pkg/webhook"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// secretPrefix marks webhook secrets so that they are easy to spot in
+// configuration and logs.
+const secretPrefix = "whsec_"
+
+// webhookEventTypes are the outbox event types a subscription can filter on.
+var webhookEventTypes = map[string]bool{
+	models.OutboxUserCreated: true,
+	models.OutboxUserUpdated: true,
+	models.OutboxUserDeleted: true,
+}
+
+type webhookUseCase struct {
+	webhookRepository interfaces.WebhookRepository
+}
+
+func NewWebhookUseCase(repository interfaces.WebhookRepository) server.WebhookUseCase {
+	return &webhookUseCase{
+		webhookRepository: repository,
+	}
+}
+
+// CreateWebhookSubscription subscribes rawURL to the given event types, all
+// of them when there are none, and returns the subscription with the secret
+// its deliveries are signed with. The secret cannot be read again later.
+func (w *webhookUseCase) CreateWebhookSubscription(ctx context.Context, rawURL string, types []string) (models.WebhookSubscription, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || u.User != nil {
+		return models.WebhookSubscription{}, domain.ErrInvalidWebhookURL
+	}
+	// Internal addresses written out are refused here already; names are
+	// checked when the dispatcher connects, once they are resolved.
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if addr, err := netip.ParseAddr(host); (err == nil && !webhook.PublicAddr(addr)) || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return models.WebhookSubscription{}, domain.ErrInvalidWebhookURL
+	}
+	seen := make(map[string]bool, len(types))
+	deduped := []string{}
+	for _, t := range types {
+		if !webhookEventTypes[t] {
+			return models.WebhookSubscription{}, domain.ErrInvalidEventType
+		}
+		if !seen[t] {
+			seen[t] = true
+			deduped = append(deduped, t)
+		}
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return models.WebhookSubscription{}, err
+	}
+	return w.webhookRepository.CreateSubscription(ctx, models.WebhookSubscription{
+		URL:        rawURL,
+		EventTypes: deduped,
+		Secret:     secretPrefix + hex.EncodeToString(secret),
+	})
+}
+
+// ListWebhookSubscriptions returns a page of the subscriptions, newest first,
+// without their secrets.
+func (w *webhookUseCase) ListWebhookSubscriptions(ctx context.Context, pageSize int, pageToken string) (models.WebhookSubscriptionPage, error) {
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	var before int64
+	if pageToken != "" {
+		var err error
+		if before, err = decodePageToken(pageToken); err != nil {
+			return models.WebhookSubscriptionPage{}, err
+		}
+	}
+	subs, err := w.webhookRepository.ListSubscriptions(ctx, before, pageSize+1)
+	if err != nil {
+		return models.WebhookSubscriptionPage{}, err
+	}
+	page := models.WebhookSubscriptionPage{Subscriptions: subs}
+	if len(subs) > pageSize {
+		page.Subscriptions = subs[:pageSize]
+		page.NextPageToken = encodePageToken(subs[pageSize-1].ID)
+	}
+	return page, nil
+}
+
+// DeleteWebhookSubscription stops deliveries to subscription id and drops its
+// delivery log.
+func (w *webhookUseCase) DeleteWebhookSubscription(ctx context.Context, id int64) error {
+	return w.webhookRepository.DeleteSubscription(ctx, id)
+}
+
+// ListWebhookDeliveries returns a page of the delivery log of a subscription,
+// newest first.
+func (w *webhookUseCase) ListWebhookDeliveries(ctx context.Context, subscriptionID int64, pageSize int, pageToken string) (models.WebhookDeliveryPage, error) {
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	var before int64
+	if pageToken != "" {
+		var err error
+		if before, err = decodePageToken(pageToken); err != nil {
+			return models.WebhookDeliveryPage{}, err
+		}
+	}
+	deliveries, err := w.webhookRepository.ListDeliveries(ctx, subscriptionID, before, pageSize+1)
+	if err != nil {
+		return models.WebhookDeliveryPage{}, err
+	}
+	page := models.WebhookDeliveryPage{Deliveries: deliveries}
+	if len(deliveries) > pageSize {
+		page.Deliveries = deliveries[:pageSize]
+		page.NextPageToken = encodePageToken(deliveries[pageSize-1].ID)
+	}
+	return page, nil
+}
+
+// ReplayWebhookDeliveries sends deliveries of a subscription again, whatever
+// became of them: delivery deliveryID, or when it is 0 all those created at or
+// after since. It returns how many it replayed.
+func (w *webhookUseCase) ReplayWebhookDeliveries(ctx context.Context, subscriptionID int64, deliveryID int64, since time.Time) (int64, error) {
+	if deliveryID != 0 {
+		since = time.Time{}
+	}
+	n, err := w.webhookRepository.Replay(ctx, subscriptionID, deliveryID, since)
+	if err != nil {
+		return 0, err
+	}
+	if deliveryID != 0 && n == 0 {
+		return 0, domain.ErrDeliveryNotFound
+	}
+	return n, nil
+}
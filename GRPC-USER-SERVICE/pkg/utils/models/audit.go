@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// AuditEntry is one call recorded in the audit log. Seq, PrevHash and Hash
+// are assigned when the entry is appended.
+type AuditEntry struct {
+	Seq        int64     `json:"seq"`
+	RecordedAt time.Time `json:"recorded_at"`
+	Principal  string    `json:"principal"`
+	Method     string    `json:"method"`
+	TargetIDs  []int64   `json:"target_ids"`
+	Code       string    `json:"code"`
+	RequestID  string    `json:"request_id"`
+	PrevHash   []byte    `json:"prev_hash"`
+	Hash       []byte    `json:"hash"`
+}
+
+// AuditQuery selects audit entries. Zero fields match every entry.
+type AuditQuery struct {
+	Principal string
+	Method    string
+	TargetID  int64
+	After     time.Time
+	Before    time.Time
+}
+
+// AuditPage is one page of audit entries, newest first.
+type AuditPage struct {
+	Entries       []AuditEntry `json:"entries"`
+	NextPageToken string       `json:"next_page_token"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Types of the events written to the outbox.
+const (
+	OutboxUserCreated = "UserCreated"
+	OutboxUserUpdated = "UserUpdated"
+	OutboxUserDeleted = "UserDeleted"
+)
+
+// OutboxEvent is a user lifecycle event in the outbox. User is the user after
+// the change, or before it for deletes. Its JSON form is what publishers
+// send; ID stays the same across attempts, so consumers can drop duplicates.
+type OutboxEvent struct {
+	ID         int64     `json:"id"`
+	Type       string    `json:"type"`
+	User       Users     `json:"user"`
+	Actor      string    `json:"actor"`
+	OccurredAt time.Time `json:"occurred_at"`
+	// Attempts counts the earlier attempts to publish the event and
+	// LastError is the error of the latest failed one.
+	Attempts  int    `json:"-"`
+	LastError string `json:"-"`
+}
@@ -1,17 +1,100 @@
 package models
 
+import "time"
+
 type User struct {
 	Fname   string  `json:"fname"`
 	City    string  `json:"city"`
 	Phone   string  `json:"phone"`
 	Height  float32 `json:"height"`
 	Married bool    `json:"married"`
+	Region  string  `json:"region"`
 }
 
+// SearchUser matches the users matching any of City, Phone and Married that
+// are set and all of the time bounds.
 type SearchUser struct {
-	City    string `json:"city"`
-	Phone   string `json:"phone"`
-	Married bool   `json:"married"`
+	City  string `json:"city"`
+	Phone string `json:"phone"`
+	// Nil leaves marital status out of the search.
+	Married *bool  `json:"married"`
+	Region  string `json:"region"`
+
+	// Zero times leave the range open on that side. After is inclusive,
+	// before exclusive.
+	CreatedAfter  time.Time `json:"created_after"`
+	CreatedBefore time.Time `json:"created_before"`
+	UpdatedAfter  time.Time `json:"updated_after"`
+	UpdatedBefore time.Time `json:"updated_before"`
+
+	SortBy     SortBy `json:"sort_by"`
+	Descending bool   `json:"descending"`
+}
+
+// Operations recorded in the history of a user.
+const (
+	ChangeSnapshot = "snapshot"
+	ChangeInsert   = "insert"
+	ChangeUpdate   = "update"
+	ChangeDelete   = "delete"
+)
+
+// UserChange is one recorded write of a user. Old is nil for inserts and
+// snapshots, New for deletes.
+type UserChange struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Operation string    `json:"operation"`
+	Old       *Users    `json:"old"`
+	New       *Users    `json:"new"`
+	Actor     string    `json:"actor"`
+	ChangedAt time.Time `json:"changed_at"`
+	// TxID is the transaction that made the change, set by GetChanges.
+	TxID uint64 `json:"-"`
+}
+
+// ChangeCursor is a position in the feed of user changes, which are ordered
+// by the transaction that made them and then by id.
+type ChangeCursor struct {
+	TxID uint64
+	ID   int64
+}
+
+const (
+	EventCreated = "created"
+	EventUpdated = "updated"
+	EventDeleted = "deleted"
+)
+
+// UserEvent is a change of a user in the change feed. User is the user after
+// the change, or before it for deletes. ResumeToken resumes the feed after
+// this event.
+type UserEvent struct {
+	Type        string    `json:"type"`
+	User        Users     `json:"user"`
+	Actor       string    `json:"actor"`
+	ChangedAt   time.Time `json:"changed_at"`
+	ResumeToken string    `json:"resume_token"`
+}
+
+// UserHistory is one page of the changes of a user, newest first.
+type UserHistory struct {
+	Changes       []UserChange `json:"changes"`
+	NextPageToken string       `json:"next_page_token"`
+}
+
+// SortBy names the field search results are ordered by.
+type SortBy int
+
+const (
+	SortByID SortBy = iota
+	SortByCreatedAt
+	SortByUpdatedAt
+)
+
+type UsersByIDs struct {
+	Users       []Users `json:"users"`
+	NotFoundIDs []int64 `json:"not_found_ids"`
 }
 
 type Users struct {
@@ -21,4 +104,10 @@ type Users struct {
 	Phone   string  `json:"phone"`
 	Height  float32 `json:"height"`
 	Married bool    `json:"married"`
+	Version int64   `json:"version"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	CreatedBy string    `json:"created_by"`
+	UpdatedBy string    `json:"updated_by"`
 }
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// WebhookSubscription is an endpoint told about user lifecycle events.
+// EventTypes lists the outbox event types delivered to it, all of them when
+// empty. Secret signs the deliveries and is only shown when the subscription
+// is created.
+type WebhookSubscription struct {
+	ID         int64     `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	Secret     string    `json:"secret,omitempty"`
+	CreatedBy  string    `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// WebhookSubscriptionPage is one page of subscriptions, newest first.
+type WebhookSubscriptionPage struct {
+	Subscriptions []WebhookSubscription `json:"subscriptions"`
+	NextPageToken string                `json:"next_page_token,omitempty"`
+}
+
+// Delivery statuses. A pending delivery is retried until it is delivered or
+// runs out of attempts and fails.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is an event sent, or to be sent, to a subscription.
+// Payload is the JSON body. ResponseCode and LastError describe the latest
+// attempt; ResponseCode is 0 when no response arrived.
+type WebhookDelivery struct {
+	ID             int64      `json:"id"`
+	SubscriptionID int64      `json:"subscription_id"`
+	EventID        int64      `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	UserID         int64      `json:"user_id"`
+	Payload        []byte     `json:"-"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	ResponseCode   int        `json:"response_code"`
+	LastError      string     `json:"last_error"`
+	CreatedAt      time.Time  `json:"created_at"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	// URL and Secret are those of the subscription, set on claimed
+	// deliveries only.
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+// WebhookDeliveryPage is one page of the delivery log of a subscription,
+// newest first.
+type WebhookDeliveryPage struct {
+	Deliveries    []WebhookDelivery `json:"deliveries"`
+	NextPageToken string            `json:"next_page_token,omitempty"`
+}
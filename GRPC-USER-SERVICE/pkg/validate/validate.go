@@ -0,0 +1,165 @@
+package validate
+
+import (
+	"fmt"
+	"grpc-user-service/pkg/pb"
+	"regexp"
+	"strings"
+	"sync"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Violation describes one field that broke its rules. Field is the dotted
+// path from the request, e.g. "user.phone".
+type Violation struct {
+	Field       string
+	Description string
+}
+
+// Error is returned when a message breaks one or more of its declared
+// (userservice.rules) constraints.
+type Error struct {
+	Violations []Violation
+}
+
+func (e *Error) Error() string {
+	parts := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		parts = append(parts, v.Field+": "+v.Description)
+	}
+	return "invalid request: " + strings.Join(parts, "; ")
+}
+
+// GRPCStatus converts the error into InvalidArgument with a BadRequest detail
+// listing every violation, so callers can report errors per field.
+func (e *Error) GRPCStatus() *status.Status {
+	st := status.New(codes.InvalidArgument, e.Error())
+	details := &errdetails.BadRequest{}
+	for _, v := range e.Violations {
+		details.FieldViolations = append(details.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       v.Field,
+			Description: v.Description,
+		})
+	}
+	if withDetails, err := st.WithDetails(details); err == nil {
+		return withDetails
+	}
+	return st
+}
+
+// Message checks m and all the messages it contains against the rules
+// declared on their fields. It returns nil or an *Error.
+func Message(m proto.Message) error {
+	var violations []Violation
+	check(m.ProtoReflect(), "", &violations)
+	if len(violations) == 0 {
+		return nil
+	}
+	return &Error{Violations: violations}
+}
+
+func check(m protoreflect.Message, prefix string, violations *[]Violation) {
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		path := prefix + string(fd.Name())
+		rules := fieldRules(fd)
+
+		switch {
+		case fd.IsList():
+			list := m.Get(fd).List()
+			if rules != nil {
+				if rules.Required && list.Len() == 0 {
+					*violations = append(*violations, Violation{path, "is required"})
+				}
+				if rules.MaxItems > 0 && uint32(list.Len()) > rules.MaxItems {
+					*violations = append(*violations, Violation{path, fmt.Sprintf("must contain at most %d items", rules.MaxItems)})
+				}
+			}
+			for j := 0; j < list.Len(); j++ {
+				item := fmt.Sprintf("%s[%d]", path, j)
+				if fd.Message() != nil {
+					check(list.Get(j).Message(), item+".", violations)
+				} else if rules != nil {
+					checkScalar(fd, list.Get(j), rules, item, violations)
+				}
+			}
+		case fd.IsMap():
+		case fd.Message() != nil:
+			if !m.Has(fd) {
+				if rules != nil && rules.Required {
+					*violations = append(*violations, Violation{path, "is required"})
+				}
+				continue
+			}
+			check(m.Get(fd).Message(), path+".", violations)
+		case rules != nil:
+			if rules.Required && fd.Kind() == protoreflect.StringKind && strings.TrimSpace(m.Get(fd).String()) == "" {
+				*violations = append(*violations, Violation{path, "is required"})
+				continue
+			}
+			checkScalar(fd, m.Get(fd), rules, path, violations)
+		}
+	}
+}
+
+func checkScalar(fd protoreflect.FieldDescriptor, v protoreflect.Value, rules *pb.FieldRules, path string, violations *[]Violation) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		s := v.String()
+		if s == "" && !rules.Required {
+			return
+		}
+		length := uint32(len([]rune(s)))
+		if rules.MinLen > 0 && length < rules.MinLen {
+			*violations = append(*violations, Violation{path, fmt.Sprintf("must be at least %d characters", rules.MinLen)})
+		}
+		if rules.MaxLen > 0 && length > rules.MaxLen {
+			*violations = append(*violations, Violation{path, fmt.Sprintf("must be at most %d characters", rules.MaxLen)})
+		}
+		if rules.Pattern != "" && !pattern(rules.Pattern).MatchString(s) {
+			*violations = append(*violations, Violation{path, "has an invalid format"})
+		}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		checkNumber(float64(v.Int()), rules, path, violations)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		checkNumber(float64(v.Uint()), rules, path, violations)
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		checkNumber(v.Float(), rules, path, violations)
+	}
+}
+
+func checkNumber(n float64, rules *pb.FieldRules, path string, violations *[]Violation) {
+	if rules.Gt != nil && !(n > rules.GetGt()) {
+		*violations = append(*violations, Violation{path, fmt.Sprintf("must be greater than %v", rules.GetGt())})
+	}
+	if rules.Lte != nil && !(n <= rules.GetLte()) {
+		*violations = append(*violations, Violation{path, fmt.Sprintf("must be at most %v", rules.GetLte())})
+	}
+}
+
+func fieldRules(fd protoreflect.FieldDescriptor) *pb.FieldRules {
+	opts := fd.Options()
+	if opts == nil || !proto.HasExtension(opts, pb.E_Rules) {
+		return nil
+	}
+	rules, _ := proto.GetExtension(opts, pb.E_Rules).(*pb.FieldRules)
+	return rules
+}
+
+var patterns sync.Map
+
+func pattern(expr string) *regexp.Regexp {
+	if re, ok := patterns.Load(expr); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(expr)
+	patterns.Store(expr, re)
+	return re
+}
@@ -0,0 +1,107 @@
+package validate
+
+import (
+	"testing"
+
+	"grpc-user-service/pkg/pb"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func Test_Message(t *testing.T) {
+	validUser := func() *pb.Users {
+		return &pb.Users{Fname: "Akhil", City: "Kannur", Phone: "9087678564", Height: 170.5, Married: true}
+	}
+
+	tests := []struct {
+		name string
+		args proto.Message
+		want []Violation
+	}{
+		{
+			name: "valid add user",
+			args: &pb.AddUserRequest{User: validUser()},
+			want: nil,
+		},
+		{
+			name: "missing user",
+			args: &pb.AddUserRequest{},
+			want: []Violation{{"user", "is required"}},
+		},
+		{
+			name: "invalid user fields",
+			args: &pb.AddUserRequest{User: &pb.Users{Fname: "  ", City: "Kannur", Phone: "", Height: -1, Region: "IND"}},
+			want: []Violation{
+				{"user.fname", "is required"},
+				{"user.phone", "is required"},
+				{"user.height", "must be greater than 0"},
+				{"user.region", "has an invalid format"},
+			},
+		},
+		{
+			name: "height too large",
+			args: &pb.AddUserRequest{User: func() *pb.Users { u := validUser(); u.Height = 301; return u }()},
+			want: []Violation{{"user.height", "must be at most 300"}},
+		},
+		{
+			name: "invalid id",
+			args: &pb.UserIDRequest{Id: -1},
+			want: []Violation{{"id", "must be greater than 0"}},
+		},
+		{
+			name: "empty ids",
+			args: &pb.UserIDsRequest{},
+			want: []Violation{{"ids", "is required"}},
+		},
+		{
+			name: "invalid id in list",
+			args: &pb.UserIDsRequest{Ids: []int64{1, 0}},
+			want: []Violation{{"ids[1]", "must be greater than 0"}},
+		},
+		{
+			name: "too many ids",
+			args: &pb.UserIDsRequest{Ids: make101IDs()},
+			want: []Violation{{"ids", "must contain at most 100 items"}},
+		},
+		{
+			name: "empty search",
+			args: &pb.SearchRequest{},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Message(tt.args)
+			if tt.want == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, &Error{Violations: tt.want}, err)
+		})
+	}
+}
+
+func make101IDs() []int64 {
+	ids := make([]int64, 101)
+	for i := range ids {
+		ids[i] = int64(i + 1)
+	}
+	return ids
+}
+
+func Test_ErrorGRPCStatus(t *testing.T) {
+	err := Message(&pb.AddUserRequest{})
+
+	st, ok := status.FromError(err)
+	assert.True(t, ok)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	assert.Len(t, st.Details(), 1)
+	details := st.Details()[0].(*errdetails.BadRequest)
+	assert.Equal(t, "user", details.FieldViolations[0].Field)
+	assert.Equal(t, "is required", details.FieldViolations[0].Description)
+}
@@ -0,0 +1,46 @@
+package webhook
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_PublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "93.184.216.34", want: true},
+		{addr: "2606:4700:4700::1111", want: true},
+		{addr: "127.0.0.1"},
+		{addr: "::1"},
+		{addr: "::ffff:127.0.0.1"},
+		{addr: "10.1.2.3"},
+		{addr: "172.16.0.1"},
+		{addr: "192.168.1.1"},
+		{addr: "169.254.169.254"},
+		{addr: "fd00:ec2::254"},
+		{addr: "fe80::1"},
+		{addr: "100.100.100.200"},
+		{addr: "0.0.0.0"},
+		{addr: "::"},
+		{addr: "224.0.0.1"},
+		{addr: "255.255.255.255"},
+		{addr: "64:ff9b::a00:1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			assert.Equal(t, tt.want, PublicAddr(netip.MustParseAddr(tt.addr)))
+		})
+	}
+}
+
+func Test_publicOnly(t *testing.T) {
+	assert.NoError(t, publicOnly("tcp4", "93.184.216.34:443", nil))
+	assert.True(t, errors.Is(publicOnly("tcp4", "169.254.169.254:80", nil), ErrForbiddenAddress))
+	assert.True(t, errors.Is(publicOnly("tcp6", "[::1]:8080", nil), ErrForbiddenAddress))
+}
@@ -0,0 +1,234 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"grpc-user-service/pkg/metrics"
+	"grpc-user-service/pkg/outbox"
+	interfaces "grpc-user-service/pkg/repository/interface"
+	"grpc-user-service/pkg/utils/models"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// markTimeout bounds recording the outcome of an attempt.
+	markTimeout = 10 * time.Second
+	// pruneInterval is how often settled deliveries past their retention are
+	// deleted.
+	pruneInterval = time.Hour
+	// maxDrainBody bounds the part of a response read so that its connection
+	// can be reused. Response bodies are never stored.
+	maxDrainBody = 64 << 10
+)
+
+// Options tune a Dispatcher.
+type Options struct {
+	// BatchSize bounds the deliveries claimed at once.
+	BatchSize int
+	// Lease is how long claimed deliveries stay with the dispatcher.
+	Lease time.Duration
+	// Timeout bounds each request.
+	Timeout time.Duration
+	// MaxAttempts is the number of attempts after which a delivery fails.
+	MaxAttempts int
+	// MinBackoff is the delay before the first retry, doubled for each
+	// further one up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Retention is how long settled deliveries are kept in the delivery log,
+	// 0 keeps them.
+	Retention time.Duration
+
+	// dialControl replaces the check that endpoints are public, for tests
+	// sending to local servers.
+	dialControl func(network, address string, c syscall.RawConn) error
+}
+
+// Dispatcher sends the pending deliveries to the subscribed endpoints, signed
+// with the secret of each subscription. Any 2xx response delivers the event,
+// anything else is retried with exponential backoff until the attempts run
+// out. Endpoints resolving to internal addresses are never connected to and
+// redirects are not followed. Like the outbox relay it looks for due
+// deliveries whenever the notifier or Wake wakes it up, and delivers at least
+// once.
+type Dispatcher struct {
+	repo     interfaces.WebhookRepository
+	notifier interfaces.ChangeNotifier
+	client   *http.Client
+	opts     Options
+	wake     chan struct{}
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewDispatcher starts a dispatcher sending the deliveries of repo.
+func NewDispatcher(repo interfaces.WebhookRepository, notifier interfaces.ChangeNotifier, opts Options) *Dispatcher {
+	control := opts.dialControl
+	if control == nil {
+		control = publicOnly
+	}
+	d := &Dispatcher{
+		repo:     repo,
+		notifier: notifier,
+		client:   newClient(opts.Timeout, control),
+		opts:     opts,
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	d.wg.Add(1)
+	go d.run()
+	return d
+}
+
+// Wake makes the dispatcher look for due deliveries without waiting for the
+// notifier.
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops the dispatcher after the batch being sent.
+func (d *Dispatcher) Close() error {
+	close(d.done)
+	d.wg.Wait()
+	d.client.CloseIdleConnections()
+	return nil
+}
+
+func (d *Dispatcher) run() {
+	defer d.wg.Done()
+	notified, unsubscribe := d.notifier.Subscribe()
+	defer unsubscribe()
+	prune := time.NewTicker(pruneInterval)
+	defer prune.Stop()
+	for {
+		n, err := d.dispatch()
+		if err != nil {
+			slog.Warn("dispatching webhooks failed", "error", err)
+		}
+		if err == nil && n == d.opts.BatchSize {
+			select {
+			case <-d.done:
+				return
+			default:
+				continue
+			}
+		}
+		select {
+		case <-d.done:
+			return
+		case <-d.wake:
+		case _, ok := <-notified:
+			if !ok {
+				return
+			}
+		case <-prune.C:
+			d.prune()
+		}
+	}
+}
+
+// dispatch claims a batch and sends its deliveries concurrently. A batch
+// holds at most one delivery of each user to a subscription, so this keeps
+// them in order.
+func (d *Dispatcher) dispatch() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.opts.Lease)
+	defer cancel()
+	deliveries, err := d.repo.Claim(ctx, d.opts.BatchSize, d.opts.Lease)
+	if err != nil {
+		return 0, err
+	}
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func(delivery models.WebhookDelivery) {
+			defer wg.Done()
+			d.deliver(ctx, delivery)
+		}(delivery)
+	}
+	wg.Wait()
+	return len(deliveries), nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery models.WebhookDelivery) {
+	code, err := d.send(ctx, delivery)
+	if err != nil && ctx.Err() != nil {
+		// The lease ran out, so another dispatcher may be sending the
+		// delivery already. Leave it to be claimed again instead of counting
+		// the cut short attempt against MaxAttempts.
+		slog.Warn("webhook lease expired while delivering", "delivery_id", delivery.ID, "subscription_id", delivery.SubscriptionID, "error", err)
+		metrics.ObserveWebhook("expired")
+		return
+	}
+	markCtx, cancel := context.WithTimeout(context.Background(), markTimeout)
+	defer cancel()
+	var markErr error
+	switch {
+	case err == nil:
+		markErr = d.repo.MarkDelivered(markCtx, delivery.ID, code)
+		metrics.ObserveWebhook("delivered")
+	case delivery.Attempts+1 >= d.opts.MaxAttempts:
+		slog.Error("giving up on webhook delivery", "delivery_id", delivery.ID, "subscription_id", delivery.SubscriptionID, "attempts", delivery.Attempts+1, "error", err)
+		markErr = d.repo.Fail(markCtx, delivery.ID, code, err.Error())
+		metrics.ObserveWebhook("failed")
+	default:
+		delay := outbox.Backoff(delivery.Attempts, d.opts.MinBackoff, d.opts.MaxBackoff)
+		slog.Warn("webhook delivery failed, retrying", "delivery_id", delivery.ID, "subscription_id", delivery.SubscriptionID, "attempts", delivery.Attempts+1, "retry_in", delay, "error", err)
+		markErr = d.repo.Retry(markCtx, delivery.ID, code, err.Error(), delay)
+		metrics.ObserveWebhook("retried")
+	}
+	if markErr != nil {
+		slog.Warn("recording webhook attempt failed", "delivery_id", delivery.ID, "error", markErr)
+	}
+}
+
+// send posts delivery and returns the response status, 0 when no response
+// arrived.
+func (d *Dispatcher) send(ctx context.Context, delivery models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderEventID, strconv.FormatInt(delivery.EventID, 10))
+	req.Header.Set(HeaderEventType, delivery.EventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, now, delivery.Payload))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainBody))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, nil
+	}
+	// Only the code is kept: the body and reason phrase are the endpoint's
+	// to choose and end up in the delivery log.
+	return resp.StatusCode, fmt.Errorf("webhook responded %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+}
+
+func (d *Dispatcher) prune() {
+	if d.opts.Retention <= 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), markTimeout)
+	defer cancel()
+	n, err := d.repo.Prune(ctx, d.opts.Retention)
+	if err != nil {
+		slog.Warn("pruning webhook deliveries failed", "error", err)
+		return
+	}
+	slog.Debug("pruned webhook deliveries", "deleted", n)
+}
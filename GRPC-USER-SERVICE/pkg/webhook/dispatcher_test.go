@@ -0,0 +1,262 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	mock_repository "grpc-user-service/pkg/repository/mock"
+	"grpc-user-service/pkg/utils/models"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+var dispatcherOptions = Options{
+	BatchSize:   10,
+	Lease:       time.Minute,
+	Timeout:     time.Second,
+	MaxAttempts: 5,
+	MinBackoff:  time.Second,
+	MaxBackoff:  time.Minute,
+	// The receivers are local.
+	dialControl: func(network, address string, c syscall.RawConn) error { return nil },
+}
+
+// receiver is an endpoint checking the signature of every delivery and
+// answering with the status set for its path.
+type receiver struct {
+	*httptest.Server
+	statuses map[string]int
+
+	mu       sync.Mutex
+	received map[string]http.Header
+	verified map[string]error
+}
+
+func newReceiver(t *testing.T, secret string, statuses map[string]int) *receiver {
+	r := &receiver{
+		statuses: statuses,
+		received: map[string]http.Header{},
+		verified: map[string]error{},
+	}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		r.received[req.URL.Path] = req.Header
+		r.verified[req.URL.Path] = Verify(secret, req.Header.Get(HeaderSignature), req.Header.Get(HeaderTimestamp), body, time.Minute)
+		r.mu.Unlock()
+		w.WriteHeader(r.statuses[req.URL.Path])
+		io.WriteString(w, "try later")
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func Test_DispatcherOutcomes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := mock_repository.NewMockWebhookRepository(ctrl)
+	mockNotifier := mock_repository.NewMockChangeNotifier(ctrl)
+	notified := make(chan struct{})
+	mockNotifier.EXPECT().Subscribe().Return((<-chan struct{})(notified), func() {})
+	endpoint := newReceiver(t, "whsec_test", map[string]int{
+		"/ok":      http.StatusNoContent,
+		"/down":    http.StatusServiceUnavailable,
+		"/gone":    http.StatusGone,
+		"/missing": http.StatusNotFound,
+	})
+	delivery := func(id int64, path string, attempts int) models.WebhookDelivery {
+		return models.WebhookDelivery{
+			ID:             id,
+			SubscriptionID: 1,
+			EventID:        40 + id,
+			EventType:      models.OutboxUserCreated,
+			UserID:         id,
+			Payload:        []byte(`{"id":42}`),
+			Attempts:       attempts,
+			URL:            endpoint.URL + path,
+			Secret:         "whsec_test",
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(4)
+	mockRepo.EXPECT().Claim(gomock.Any(), 10, time.Minute).Return([]models.WebhookDelivery{
+		delivery(1, "/ok", 0),
+		delivery(2, "/down", 2),
+		delivery(3, "/gone", 0),
+		delivery(4, "/missing", 4),
+	}, nil)
+	mockRepo.EXPECT().MarkDelivered(gomock.Any(), int64(1), http.StatusNoContent).
+		Do(func(ctx context.Context, id int64, responseCode int) { wg.Done() })
+	retry := func(ctx context.Context, id int64, responseCode int, lastErr string, delay time.Duration) { wg.Done() }
+	mockRepo.EXPECT().Retry(gomock.Any(), int64(2), http.StatusServiceUnavailable, "webhook responded 503 Service Unavailable", 4*time.Second).Do(retry)
+	mockRepo.EXPECT().Retry(gomock.Any(), int64(3), http.StatusGone, "webhook responded 410 Gone", time.Second).Do(retry)
+	mockRepo.EXPECT().Fail(gomock.Any(), int64(4), http.StatusNotFound, "webhook responded 404 Not Found").
+		Do(func(ctx context.Context, id int64, responseCode int, lastErr string) { wg.Done() })
+
+	d := NewDispatcher(mockRepo, mockNotifier, dispatcherOptions)
+	wg.Wait()
+	assert.NoError(t, d.Close())
+
+	endpoint.mu.Lock()
+	defer endpoint.mu.Unlock()
+	assert.Len(t, endpoint.received, 4)
+	for path, err := range endpoint.verified {
+		assert.NoError(t, err, path)
+	}
+	headers := endpoint.received["/ok"]
+	assert.Equal(t, "application/json", headers.Get("Content-Type"))
+	assert.Equal(t, "1", headers.Get(HeaderID))
+	assert.Equal(t, "41", headers.Get(HeaderEventID))
+	assert.Equal(t, "UserCreated", headers.Get(HeaderEventType))
+}
+
+func Test_DispatcherUnreachable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := mock_repository.NewMockWebhookRepository(ctrl)
+	mockNotifier := mock_repository.NewMockChangeNotifier(ctrl)
+	notified := make(chan struct{})
+	mockNotifier.EXPECT().Subscribe().Return((<-chan struct{})(notified), func() {})
+	endpoint := httptest.NewServer(http.NotFoundHandler())
+	endpoint.Close()
+
+	retried := make(chan string, 1)
+	mockRepo.EXPECT().Claim(gomock.Any(), 10, time.Minute).Return([]models.WebhookDelivery{
+		{ID: 1, URL: endpoint.URL, Secret: "whsec_test", Payload: []byte(`{}`)},
+	}, nil)
+	mockRepo.EXPECT().Retry(gomock.Any(), int64(1), 0, gomock.Any(), time.Second).
+		Do(func(ctx context.Context, id int64, responseCode int, lastErr string, delay time.Duration) {
+			retried <- lastErr
+		})
+
+	d := NewDispatcher(mockRepo, mockNotifier, dispatcherOptions)
+	assert.NotEmpty(t, <-retried)
+	assert.NoError(t, d.Close())
+}
+
+func Test_DispatcherLeaseExpired(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := mock_repository.NewMockWebhookRepository(ctrl)
+	mockNotifier := mock_repository.NewMockChangeNotifier(ctrl)
+	notified := make(chan struct{})
+	mockNotifier.EXPECT().Subscribe().Return((<-chan struct{})(notified), func() {})
+	// The endpoint answers only once the request is given up.
+	called := make(chan struct{})
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.Copy(io.Discard, req.Body)
+		close(called)
+		<-req.Context().Done()
+	}))
+	t.Cleanup(endpoint.Close)
+	opts := dispatcherOptions
+	opts.Lease = 20 * time.Millisecond
+
+	// Neither Retry nor Fail: the delivery is claimed again after its lease
+	// without an attempt recorded.
+	mockRepo.EXPECT().Claim(gomock.Any(), 10, opts.Lease).Return([]models.WebhookDelivery{
+		{ID: 1, URL: endpoint.URL, Secret: "whsec_test", Payload: []byte(`{}`), Attempts: 4},
+	}, nil)
+
+	d := NewDispatcher(mockRepo, mockNotifier, opts)
+	<-called
+	assert.NoError(t, d.Close())
+}
+
+func Test_DispatcherRefusesRedirects(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := mock_repository.NewMockWebhookRepository(ctrl)
+	mockNotifier := mock_repository.NewMockChangeNotifier(ctrl)
+	notified := make(chan struct{})
+	mockNotifier.EXPECT().Subscribe().Return((<-chan struct{})(notified), func() {})
+	var followed bool
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/internal" {
+			followed = true
+		}
+		http.Redirect(w, req, "/internal", http.StatusFound)
+	}))
+	t.Cleanup(endpoint.Close)
+
+	retried := make(chan string, 1)
+	mockRepo.EXPECT().Claim(gomock.Any(), 10, time.Minute).Return([]models.WebhookDelivery{
+		{ID: 1, URL: endpoint.URL + "/hook", Secret: "whsec_test", Payload: []byte(`{}`)},
+	}, nil)
+	mockRepo.EXPECT().Retry(gomock.Any(), int64(1), http.StatusFound, gomock.Any(), time.Second).
+		Do(func(ctx context.Context, id int64, responseCode int, lastErr string, delay time.Duration) {
+			retried <- lastErr
+		})
+
+	d := NewDispatcher(mockRepo, mockNotifier, dispatcherOptions)
+	assert.Equal(t, "webhook responded 302 Found", <-retried)
+	assert.NoError(t, d.Close())
+	assert.False(t, followed)
+}
+
+func Test_DispatcherRefusesInternalAddresses(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := mock_repository.NewMockWebhookRepository(ctrl)
+	mockNotifier := mock_repository.NewMockChangeNotifier(ctrl)
+	notified := make(chan struct{})
+	mockNotifier.EXPECT().Subscribe().Return((<-chan struct{})(notified), func() {})
+	var reached bool
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		reached = true
+	}))
+	t.Cleanup(endpoint.Close)
+
+	retried := make(chan string, 1)
+	mockRepo.EXPECT().Claim(gomock.Any(), 10, time.Minute).Return([]models.WebhookDelivery{
+		{ID: 1, URL: strings.Replace(endpoint.URL, "127.0.0.1", "localhost", 1), Secret: "whsec_test", Payload: []byte(`{}`)},
+	}, nil)
+	mockRepo.EXPECT().Retry(gomock.Any(), int64(1), 0, gomock.Any(), time.Second).
+		Do(func(ctx context.Context, id int64, responseCode int, lastErr string, delay time.Duration) {
+			retried <- lastErr
+		})
+
+	opts := dispatcherOptions
+	opts.dialControl = nil
+	d := NewDispatcher(mockRepo, mockNotifier, opts)
+	assert.Contains(t, <-retried, ErrForbiddenAddress.Error())
+	assert.NoError(t, d.Close())
+	assert.False(t, reached)
+}
+
+func Test_DispatcherWaits(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := mock_repository.NewMockWebhookRepository(ctrl)
+	mockNotifier := mock_repository.NewMockChangeNotifier(ctrl)
+	notified := make(chan struct{})
+	mockNotifier.EXPECT().Subscribe().Return((<-chan struct{})(notified), func() {})
+
+	claimed := make(chan struct{}, 2)
+	claim := func(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+		claimed <- struct{}{}
+		return nil, nil
+	}
+	gomock.InOrder(
+		mockRepo.EXPECT().Claim(gomock.Any(), 10, time.Minute).Return(nil, errors.New("error")),
+		// Woken up by the notifier, then by Wake.
+		mockRepo.EXPECT().Claim(gomock.Any(), 10, time.Minute).DoAndReturn(claim),
+		mockRepo.EXPECT().Claim(gomock.Any(), 10, time.Minute).DoAndReturn(claim),
+	)
+
+	d := NewDispatcher(mockRepo, mockNotifier, dispatcherOptions)
+	select {
+	case <-claimed:
+		t.Fatal("claimed without a wake-up")
+	case <-time.After(50 * time.Millisecond):
+	}
+	notified <- struct{}{}
+	<-claimed
+	d.Wake()
+	<-claimed
+	assert.NoError(t, d.Close())
+}
@@ -0,0 +1,46 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"grpc-user-service/pkg/outbox"
+	interfaces "grpc-user-service/pkg/repository/interface"
+	"grpc-user-service/pkg/utils/models"
+)
+
+type publisher struct {
+	repo       interfaces.WebhookRepository
+	dispatcher *Dispatcher
+}
+
+// NewPublisher returns the outbox publisher feeding webhooks: it adds a
+// delivery of every event for each subscription to its type, with the JSON
+// form of the event as payload, and wakes dispatcher when there is any. An
+// event published again adds no deliveries, so the outbox may retry it
+// freely.
+func NewPublisher(repo interfaces.WebhookRepository, dispatcher *Dispatcher) outbox.Publisher {
+	return &publisher{
+		repo:       repo,
+		dispatcher: dispatcher,
+	}
+}
+
+func (p *publisher) Publish(ctx context.Context, event models.OutboxEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return outbox.Permanent(err)
+	}
+	n, err := p.repo.Enqueue(ctx, event, payload)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		p.dispatcher.Wake()
+	}
+	return nil
+}
+
+// Close leaves the dispatcher running, it is closed on its own.
+func (p *publisher) Close() error {
+	return nil
+}
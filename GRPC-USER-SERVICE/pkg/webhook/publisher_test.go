@@ -0,0 +1,65 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	mock_repository "grpc-user-service/pkg/repository/mock"
+	"grpc-user-service/pkg/utils/models"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Publisher(t *testing.T) {
+	event := models.OutboxEvent{
+		ID:         42,
+		Type:       models.OutboxUserCreated,
+		User:       models.Users{ID: 7, Fname: "Akhil", Version: 1},
+		Actor:      "alice",
+		OccurredAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		Attempts:   3,
+	}
+
+	tests := []struct {
+		name     string
+		enqueued int64
+		err      error
+		wantWake bool
+	}{
+		{name: "enqueued", enqueued: 2, wantWake: true},
+		{name: "no subscriptions", enqueued: 0},
+		{name: "error", err: errors.New("error")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockRepo := mock_repository.NewMockWebhookRepository(ctrl)
+			var payload []byte
+			mockRepo.EXPECT().Enqueue(gomock.Any(), event, gomock.Any()).
+				DoAndReturn(func(ctx context.Context, event models.OutboxEvent, body []byte) (int64, error) {
+					payload = body
+					return tt.enqueued, tt.err
+				})
+			// A dispatcher that is not running, to see whether it was woken up.
+			dispatcher := &Dispatcher{wake: make(chan struct{}, 1)}
+			publisher := NewPublisher(mockRepo, dispatcher)
+
+			err := publisher.Publish(context.Background(), event)
+
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, tt.wantWake, len(dispatcher.wake) == 1)
+			var got models.OutboxEvent
+			require.NoError(t, json.Unmarshal(payload, &got))
+			assert.Equal(t, event.ID, got.ID)
+			assert.Equal(t, event.User, got.User)
+			assert.Zero(t, got.Attempts)
+			assert.NoError(t, publisher.Close())
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers of every delivery.
+const (
+	HeaderID        = "X-Webhook-Id"
+	HeaderEventID   = "X-Webhook-Event-Id"
+	HeaderEventType = "X-Webhook-Event-Type"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// signatureVersion prefixes signatures so that the scheme can change without
+// breaking receivers.
+const signatureVersion = "v1="
+
+var (
+	ErrInvalidSignature = errors.New("webhook signature doesn't match")
+	ErrStaleTimestamp   = errors.New("webhook timestamp is too old")
+)
+
+// Sign returns the X-Webhook-Signature of body sent at timestamp, the
+// hex-encoded HMAC-SHA256 with secret of the timestamp in unix seconds, a
+// dot and the body. Signing the timestamp keeps a captured delivery from
+// being replayed later.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signatureVersion + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the X-Webhook-Signature and X-Webhook-Timestamp headers of a
+// delivery with body, for receivers written in Go. A timestamp further than
+// tolerance from now is rejected, 0 accepts any.
+func Verify(secret, signature, timestamp string, body []byte, tolerance time.Duration) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	sent := time.Unix(seconds, 0)
+	if tolerance > 0 {
+		if age := time.Since(sent); age > tolerance || age < -tolerance {
+			return ErrStaleTimestamp
+		}
+	}
+	if !strings.HasPrefix(signature, signatureVersion) || !hmac.Equal([]byte(signature), []byte(Sign(secret, sent, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package webhook
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Sign(t *testing.T) {
+	sent := time.Unix(1714557600, 0)
+
+	// echo -n '1714557600.{"id":1}' | openssl dgst -sha256 -hmac whsec_test
+	assert.Equal(t, "v1=d10bad98e048504ebe79e6a1e412ce5bf086c26bc0f025b023aecd204a5ba3b3", Sign("whsec_test", sent, []byte(`{"id":1}`)))
+}
+
+func Test_Verify(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	now := time.Now()
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := Sign("whsec_test", now, body)
+	old := now.Add(-time.Hour)
+
+	tests := []struct {
+		name      string
+		secret    string
+		signature string
+		timestamp string
+		body      []byte
+		tolerance time.Duration
+		want      error
+	}{
+		{name: "valid", secret: "whsec_test", signature: signature, timestamp: timestamp, body: body, tolerance: time.Minute},
+		{name: "other secret", secret: "whsec_other", signature: signature, timestamp: timestamp, body: body, tolerance: time.Minute, want: ErrInvalidSignature},
+		{name: "altered body", secret: "whsec_test", signature: signature, timestamp: timestamp, body: []byte(`{"id":2}`), tolerance: time.Minute, want: ErrInvalidSignature},
+		{name: "other timestamp", secret: "whsec_test", signature: signature, timestamp: strconv.FormatInt(now.Unix()+1, 10), body: body, tolerance: time.Minute, want: ErrInvalidSignature},
+		{name: "bad timestamp", secret: "whsec_test", signature: signature, timestamp: "now", body: body, tolerance: time.Minute, want: ErrInvalidSignature},
+		{name: "unknown version", secret: "whsec_test", signature: "v0" + signature[2:], timestamp: timestamp, body: body, tolerance: time.Minute, want: ErrInvalidSignature},
+		{name: "stale", secret: "whsec_test", signature: Sign("whsec_test", old, body), timestamp: strconv.FormatInt(old.Unix(), 10), body: body, tolerance: time.Minute, want: ErrStaleTimestamp},
+		{name: "no tolerance", secret: "whsec_test", signature: Sign("whsec_test", old, body), timestamp: strconv.FormatInt(old.Unix(), 10), body: body},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Verify(tt.secret, tt.signature, tt.timestamp, tt.body, tt.tolerance))
+		})
+	}
+}